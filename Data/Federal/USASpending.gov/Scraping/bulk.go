@@ -0,0 +1,478 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Directory at the output root holding downloaded bulk archives
+const bulkDir = "bulk"
+
+// Default bulk date range: the fiscal years before the search endpoint's
+// 2007-10-01 cutoff that custom award downloads still cover
+const (
+	defaultBulkStart = "2000-10-01"
+	defaultBulkEnd   = "2007-09-30"
+)
+
+// BulkDownloadRequest is the body of a /api/v2/bulk_download/awards/ call.
+type BulkDownloadRequest struct {
+	Filters    BulkFilters `json:"filters"`
+	FileFormat string      `json:"file_format"`
+}
+
+type BulkFilters struct {
+	PrimeAwardTypes             []string             `json:"prime_award_types"`
+	DateType                    string               `json:"date_type"`
+	DateRange                   BulkDateRange        `json:"date_range"`
+	Agencies                    []AgencyFilter       `json:"agencies"`
+	Keywords                    []string             `json:"keywords,omitempty"`
+	PlaceOfPerformanceLocations []PlaceOfPerformance `json:"place_of_performance_locations,omitempty"`
+}
+
+type BulkDateRange struct {
+	StartDate string `json:"start_date"`
+	EndDate   string `json:"end_date"`
+}
+
+type BulkDownloadResponse struct {
+	FileName  string `json:"file_name"`
+	StatusURL string `json:"status_url"`
+	FileURL   string `json:"file_url"`
+}
+
+// DownloadStatus is a /api/v2/download/status/ response. Status is "ready"
+// or "running" while the job is queued or generating the file.
+type DownloadStatus struct {
+	Status    string `json:"status"`
+	Message   string `json:"message"`
+	FileURL   string `json:"file_url"`
+	TotalRows int    `json:"total_rows"`
+}
+
+// bulkOptions holds the bulk command's settings.
+type bulkOptions struct {
+	start, end string        // Action date range
+	poll       time.Duration // Pause between status checks
+	file       string        // Local archive to read instead of requesting one
+}
+
+// bulkRequest builds the download request for the groups from the search
+// profile's filters.
+func (s *Scraper) bulkRequest(groups []string, opts bulkOptions) BulkDownloadRequest {
+	var types []string
+	for _, groupName := range groups {
+		types = append(types, awardTypeGroups[groupName]...)
+	}
+	agencies := s.profile.Filters.Agencies
+	if len(agencies) == 0 {
+		agencies = []AgencyFilter{{Type: "awarding", Tier: "toptier", Name: "All"}}
+	}
+	keywords := s.profile.Filters.Keywords
+	if len(keywords) == 0 {
+		keywords = s.profile.Filters.RecipientSearchText
+	}
+	return BulkDownloadRequest{
+		Filters: BulkFilters{
+			PrimeAwardTypes:             types,
+			DateType:                    "action_date",
+			DateRange:                   BulkDateRange{StartDate: opts.start, EndDate: opts.end},
+			Agencies:                    agencies,
+			Keywords:                    keywords,
+			PlaceOfPerformanceLocations: s.profile.Filters.PlaceOfPerformanceLocations,
+		},
+		FileFormat: "csv",
+	}
+}
+
+// requestBulkDownload submits a bulk download job, waits for it to finish
+// and saves the archive under <out>/bulk/. It returns the archive's path.
+func (s *Scraper) requestBulkDownload(ctx context.Context, request BulkDownloadRequest, poll time.Duration) (string, error) {
+	var job BulkDownloadResponse
+	if err := s.postJSON(ctx, s.bulkDownloadURL, request, &job); err != nil {
+		return "", fmt.Errorf("error requesting bulk download: %w", err)
+	}
+	if job.FileName == "" {
+		return "", errors.New("bulk download response has no file_name")
+	}
+	log.Printf("Requested bulk download %s", job.FileName)
+
+	statusURL := s.downloadStatusURL + "?file_name=" + url.QueryEscape(job.FileName)
+	var status DownloadStatus
+	for {
+		if err := s.getJSON(ctx, statusURL, &status); err != nil {
+			return "", fmt.Errorf("error checking bulk download %s: %w", job.FileName, err)
+		}
+		if status.Status == "finished" {
+			break
+		}
+		if status.Status == "failed" {
+			return "", fmt.Errorf("bulk download %s failed: %s", job.FileName, status.Message)
+		}
+		log.Printf("Bulk download %s is %s; checking again in %s", job.FileName, status.Status, poll)
+		if err := sleepContext(ctx, poll); err != nil {
+			return "", err
+		}
+	}
+
+	fileURL := status.FileURL
+	if fileURL == "" {
+		fileURL = job.FileURL
+	}
+	path := filepath.Join(s.outputRoot, bulkDir, filepath.Base(job.FileName))
+	if err := s.downloadFile(ctx, fileURL, path); err != nil {
+		return "", fmt.Errorf("error downloading %s: %w", fileURL, err)
+	}
+	log.Printf("Downloaded %d rows to %s", status.TotalRows, path)
+	return path, nil
+}
+
+// downloadFile streams a file to path. Archives can be large, so the request
+// has no overall timeout; cancelling ctx stops it.
+func (s *Scraper) downloadFile(ctx context.Context, fileURL, path string) error {
+	if err := s.limiter.Wait(ctx); err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", fileURL, nil)
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("User-Agent", "UC-Holdings-Scraper/1.0")
+
+	client := *s.client
+	client.Timeout = 0
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("API returned status %d", resp.StatusCode)
+	}
+
+	if err := ensureDirectoryExists(filepath.Dir(path)); err != nil {
+		return fmt.Errorf("error creating directory: %w", err)
+	}
+	return writeFileAtomic(path, func(w io.Writer) error {
+		_, err := io.Copy(w, resp.Body)
+		return err
+	})
+}
+
+// bulkRow is one CSV row keyed by column name.
+type bulkRow map[string]string
+
+// get returns the first non-empty value among the columns. Contract and
+// assistance files name the same value differently.
+func (r bulkRow) get(columns ...string) string {
+	for _, column := range columns {
+		if value := strings.TrimSpace(r[column]); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func (r bulkRow) money(columns ...string) Money {
+	m, err := ParseMoney(r.get(columns...))
+	if err != nil {
+		return Money{}
+	}
+	return m
+}
+
+// date parses a date column, which may carry a time after the date.
+func (r bulkRow) date(columns ...string) Date {
+	value := r.get(columns...)
+	if len(value) > len(dateLayout) {
+		value = value[:len(dateLayout)]
+	}
+	d, err := ParseDate(value)
+	if err != nil {
+		return Date{}
+	}
+	return d
+}
+
+// bulkAward gathers the transaction rows of one award.
+type bulkAward struct {
+	group string
+	rows  []bulkRow
+}
+
+// bulkGroup returns the award group of a row from its unique award key and
+// assistance type, or "" when it fits none.
+func bulkGroup(key string, row bulkRow) string {
+	switch {
+	case strings.HasPrefix(key, "CONT_IDV_"):
+		return "idvs"
+	case strings.HasPrefix(key, "CONT_AWD_"):
+		return "contracts"
+	}
+	code := row.get("assistance_type_code")
+	for groupName, codes := range awardTypeGroups {
+		for _, c := range codes {
+			if c == code {
+				return groupName
+			}
+		}
+	}
+	return ""
+}
+
+// bulkArchiveStats counts what readBulkArchive read.
+type bulkArchiveStats struct {
+	files, rows, notUC, otherGroup int
+}
+
+// readBulkArchive streams every CSV in the archive and keeps the rows of UC
+// awards in the selected groups, keyed by unique award key.
+func readBulkArchive(path string, groups []string, resolver *RecipientResolver) (map[string]*bulkAward, bulkArchiveStats, error) {
+	var stats bulkArchiveStats
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, stats, fmt.Errorf("error opening %s: %w", path, err)
+	}
+	defer archive.Close()
+
+	selected := make(map[string]bool)
+	for _, groupName := range groups {
+		selected[groupName] = true
+	}
+
+	awards := make(map[string]*bulkAward)
+	for _, file := range archive.File {
+		if !strings.EqualFold(filepath.Ext(file.Name), ".csv") {
+			continue
+		}
+		stats.files++
+		err := readBulkCSV(file, func(row bulkRow) {
+			stats.rows++
+			key := row.get("contract_award_unique_key", "assistance_award_unique_key")
+			group := bulkGroup(key, row)
+			if key == "" || !selected[group] {
+				stats.otherGroup++
+				return
+			}
+			ref := recipientRef{
+				awardID:   row.get("award_id_piid", "award_id_fain", "award_id_uri"),
+				name:      row.get("recipient_name"),
+				uei:       row.get("recipient_uei"),
+				parentUEI: row.get("recipient_parent_uei"),
+				city:      row.get("recipient_city_name"),
+			}
+			if !resolver.resolve(ref).Entity.IsUC() {
+				stats.notUC++
+				return
+			}
+			award := awards[key]
+			if award == nil {
+				award = &bulkAward{group: group}
+				awards[key] = award
+			}
+			award.rows = append(award.rows, row)
+		})
+		if err != nil {
+			return nil, stats, fmt.Errorf("error reading %s in %s: %w", file.Name, path, err)
+		}
+	}
+	return awards, stats, nil
+}
+
+func readBulkCSV(file *zip.File, fn func(row bulkRow)) error {
+	rc, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	reader := csv.NewReader(rc)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		row := make(bulkRow, len(header))
+		for i, column := range header {
+			if i < len(record) {
+				row[column] = record[i]
+			}
+		}
+		fn(row)
+	}
+}
+
+// enhancedAward converts the rows of an award into the saved record: award
+// level values from the latest transaction, and every row as a transaction.
+// The award has no detail, which enrich can add later by its ID.
+func (b *bulkAward) enhancedAward(key string) (EnhancedAward, error) {
+	sort.SliceStable(b.rows, func(i, j int) bool {
+		return b.rows[i].date("action_date").Before(b.rows[j].date("action_date"))
+	})
+	first, latest := b.rows[0], b.rows[len(b.rows)-1]
+
+	award := Award{
+		GeneratedInternalID: key,
+		AwardID:             latest.get("award_id_piid", "award_id_fain", "award_id_uri"),
+		RecipientName:       latest.get("recipient_name"),
+		RecipientUEI:        latest.get("recipient_uei"),
+		AwardAmount:         latest.money("total_dollars_obligated", "total_obligated_amount"),
+		TotalOutlays:        latest.money("total_outlayed_amount_for_overall_award"),
+		Description:         first.get("prime_award_base_transaction_description", "award_description", "transaction_description"),
+		ContractAwardType:   latest.get("award_type", "idv_type"),
+		AwardingAgency:      latest.get("awarding_agency_name"),
+		AwardingSubAgency:   latest.get("awarding_sub_agency_name"),
+		FundingAgency:       latest.get("funding_agency_name"),
+		StartDate:           first.date("period_of_performance_start_date"),
+		EndDate:             latest.date("period_of_performance_current_end_date"),
+
+		RecipientLocationCityName:     latest.get("recipient_city_name"),
+		RecipientLocationStateCode:    latest.get("recipient_state_code"),
+		RecipientLocationCountryName:  latest.get("recipient_country_name"),
+		RecipientLocationAddressLine1: latest.get("recipient_address_line_1"),
+		POPCityName:                   latest.get("primary_place_of_performance_city_name"),
+		POPStateCode:                  latest.get("primary_place_of_performance_state_code"),
+		POPCountryName:                latest.get("primary_place_of_performance_country_name"),
+	}
+	award.RecipientLocation = AwardLocation{
+		Location: Location{
+			CityName:     award.RecipientLocationCityName,
+			StateCode:    award.RecipientLocationStateCode,
+			CountryName:  award.RecipientLocationCountryName,
+			AddressLine1: award.RecipientLocationAddressLine1,
+		},
+		Flattened: true,
+	}
+	award.PrimaryPlaceOfPerformance = AwardLocation{
+		Location: Location{
+			CityName:    award.POPCityName,
+			StateCode:   award.POPStateCode,
+			CountryName: award.POPCountryName,
+		},
+		Flattened: true,
+	}
+
+	enhanced := EnhancedAward{BasicData: award, Raw: &RawPayload{}}
+	var obligated int64
+	for _, row := range b.rows {
+		transaction := Transaction{
+			ID:                      row.get("contract_transaction_unique_key", "assistance_transaction_unique_key"),
+			ActionDate:              row.date("action_date"),
+			ActionType:              row.get("action_type_code"),
+			ActionTypeDescription:   row.get("action_type_description"),
+			ModificationNumber:      row.get("modification_number", "award_modification_amendme"),
+			Description:             row.get("transaction_description"),
+			FederalActionObligation: row.money("federal_action_obligation"),
+			FaceValueLoanGuarantee:  row.money("face_value_of_loan"),
+			OriginalLoanSubsidyCost: row.money("original_loan_subsidy_cost"),
+			AssistanceListingNumber: row.get("assistance_listing_number", "cfda_number"),
+			IsFPDS:                  strings.HasPrefix(key, "CONT_"),
+		}
+		enhanced.Transactions = append(enhanced.Transactions, transaction)
+		if cents, ok := transaction.FederalActionObligation.Cents(); ok {
+			obligated += cents
+		}
+
+		data, err := json.Marshal(row)
+		if err != nil {
+			return enhanced, fmt.Errorf("error encoding row of %s: %w", key, err)
+		}
+		enhanced.Raw.BulkRows = append(enhanced.Raw.BulkRows, data)
+	}
+	if !enhanced.BasicData.AwardAmount.Valid() {
+		enhanced.BasicData.AwardAmount = MoneyFromCents(obligated)
+	}
+	return enhanced, nil
+}
+
+// runBulkDownload requests or opens a bulk archive and saves its UC awards
+// in the tree. Awards already saved are left alone, since a file from the
+// API has the detail a bulk row lacks.
+func (s *Scraper) runBulkDownload(ctx context.Context, groups []string, opts bulkOptions) error {
+	path := opts.file
+	if path == "" {
+		request := s.bulkRequest(groups, opts)
+		if s.dryRun {
+			return s.printDryRunRequest(s.bulkDownloadURL, request)
+		}
+		var err error
+		if path, err = s.requestBulkDownload(ctx, request, opts.poll); err != nil {
+			return err
+		}
+	}
+
+	awards, stats, err := readBulkArchive(path, groups, s.resolver)
+	if err != nil {
+		return err
+	}
+	log.Printf("Read %d rows from %d CSV files: %d UC awards, %d rows not UC, %d rows outside the selected groups",
+		stats.rows, stats.files, len(awards), stats.notUC, stats.otherGroup)
+
+	keys := make([]string, 0, len(awards))
+	for key := range awards {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	existing := 0
+	for _, key := range keys {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		group := awards[key].group
+		award, err := awards[key].enhancedAward(key)
+		if err != nil {
+			return err
+		}
+		entity := s.resolver.Resolve(&award).Entity
+		filePath, err := s.layout.awardPath(s.outputRoot, group, &award, entity)
+		if err != nil {
+			log.Printf("Leaving out %s: %v", key, err)
+			if err := s.undated.add(newUndatedAward(group, &award, err)); err != nil {
+				return fmt.Errorf("error recording undated award %s: %w", key, err)
+			}
+			s.summary.recordUndated(group)
+			continue
+		}
+		if _, err := os.Stat(filePath); err == nil {
+			existing++
+			continue
+		}
+		if err := saveEnhancedAwardToJSON(award, filePath); err != nil {
+			log.Printf("Error saving award %s: %v", key, err)
+			s.summary.recordFailed()
+			continue
+		}
+		s.summary.recordSaved(group, false)
+	}
+	if existing > 0 {
+		log.Printf("Left %d awards that were already saved", existing)
+	}
+	return nil
+}
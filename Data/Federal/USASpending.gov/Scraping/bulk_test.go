@@ -0,0 +1,149 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const bulkContractsCSV = `contract_transaction_unique_key,contract_award_unique_key,award_id_piid,modification_number,federal_action_obligation,total_dollars_obligated,action_date,action_type_code,period_of_performance_start_date,period_of_performance_current_end_date,awarding_agency_name,awarding_sub_agency_name,funding_agency_name,recipient_name,recipient_uei,recipient_city_name,recipient_state_code,recipient_country_name,transaction_description,prime_award_base_transaction_description
+9700_-NONE-_N0001403C0001_P00001_-NONE-_0,CONT_AWD_N0001403C0001_9700_-NONE-_-NONE-,N0001403C0001,P00001,-500.00,1500.00,2004-02-01 00:00:00,B,2003-01-15,2005-01-14,Department of Defense,Department of the Navy,Department of Defense,UNIVERSITY OF CALIFORNIA SAN DIEGO,,LA JOLLA,CA,UNITED STATES,FUNDING REDUCTION,OCEAN ACOUSTICS RESEARCH
+9700_-NONE-_N0001403C0001_0_-NONE-_0,CONT_AWD_N0001403C0001_9700_-NONE-_-NONE-,N0001403C0001,0,2000.00,1500.00,2003-01-15 00:00:00,A,2003-01-15,2004-01-14,Department of Defense,Department of the Navy,Department of Defense,UNIVERSITY OF CALIFORNIA SAN DIEGO,,LA JOLLA,CA,UNITED STATES,"OCEAN ACOUSTICS RESEARCH, PHASE I",OCEAN ACOUSTICS RESEARCH
+9700_-NONE-_N0001403C0002_0_-NONE-_0,CONT_AWD_N0001403C0002_9700_-NONE-_-NONE-,N0001403C0002,0,100.00,100.00,2003-03-01 00:00:00,A,2003-03-01,2004-03-01,Department of Defense,Department of the Navy,Department of Defense,STANFORD UNIVERSITY,,STANFORD,CA,UNITED STATES,NOT UC,NOT UC
+`
+
+const bulkAssistanceCSV = "\ufeff" + `assistance_transaction_unique_key,assistance_award_unique_key,award_id_fain,modification_number,federal_action_obligation,total_obligated_amount,action_date,action_type_code,assistance_type_code,period_of_performance_start_date,period_of_performance_current_end_date,awarding_agency_name,recipient_name,recipient_city_name,cfda_number,transaction_description
+4900_0349812_-NONE-_0_0,ASST_NON_0349812_4900,0349812,0,250000,250000,2004-06-01,A,04,2004-07-01,2007-06-30,National Science Foundation,REGENTS OF THE UNIVERSITY OF CALIFORNIA AT BERKELEY,BERKELEY,47.050,OCEAN DRILLING
+`
+
+// bulkArchive builds a zip holding the named files.
+func bulkArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestBulkDownload(t *testing.T) {
+	archive := bulkArchive(t, map[string]string{
+		"All_PrimeTransactionsAndSubawards_Contracts_1.csv":  bulkContractsCSV,
+		"All_PrimeTransactionsAndSubawards_Assistance_1.csv": bulkAssistanceCSV,
+		"Data_Dictionary_Crosswalk.txt":                      "not a CSV",
+	})
+	var request BulkDownloadRequest
+	statusChecks := 0
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v2/bulk_download/awards/":
+			json.NewDecoder(r.Body).Decode(&request)
+			fmt.Fprint(w, `{"file_name":"All_PrimeTransactions_2024.zip","status_url":"unused"}`)
+		case "/api/v2/download/status/":
+			if r.URL.Query().Get("file_name") != "All_PrimeTransactions_2024.zip" {
+				t.Errorf("status requested for %q", r.URL.Query().Get("file_name"))
+			}
+			statusChecks++
+			if statusChecks < 2 {
+				fmt.Fprint(w, `{"status":"running"}`)
+				return
+			}
+			fmt.Fprintf(w, `{"status":"finished","total_rows":4,"file_url":"%s/files/All_PrimeTransactions_2024.zip"}`, server.URL)
+		case "/files/All_PrimeTransactions_2024.zip":
+			w.Write(archive)
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	s := newTestScraper(server.URL, 1)
+	s.outputRoot = t.TempDir()
+	opts := bulkOptions{start: defaultBulkStart, end: defaultBulkEnd, poll: time.Millisecond}
+	if err := s.runBulkDownload(context.Background(), []string{"contracts", "grants"}, opts); err != nil {
+		t.Fatalf("runBulkDownload: %v", err)
+	}
+
+	if statusChecks != 2 {
+		t.Errorf("%d status checks, want 2", statusChecks)
+	}
+	if request.Filters.DateRange.StartDate != defaultBulkStart || request.Filters.DateType != "action_date" || request.FileFormat != "csv" ||
+		len(request.Filters.PrimeAwardTypes) != len(awardTypeGroups["contracts"])+len(awardTypeGroups["grants"]) {
+		t.Errorf("request = %+v", request)
+	}
+	if _, err := os.Stat(filepath.Join(s.outputRoot, bulkDir, "All_PrimeTransactions_2024.zip")); err != nil {
+		t.Errorf("archive not kept: %v", err)
+	}
+
+	awards := make(map[string]*EnhancedAward)
+	for _, group := range []string{"contracts", "grants"} {
+		err := walkEnhancedAwards(s.outputRoot, group, func(path string, award *EnhancedAward) error {
+			awards[award.BasicData.GeneratedInternalID] = award
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(awards) != 2 {
+		t.Fatalf("saved %d awards, want 2", len(awards))
+	}
+
+	contract := awards["CONT_AWD_N0001403C0001_9700_-NONE-_-NONE-"]
+	if contract == nil {
+		t.Fatalf("contract not saved")
+	}
+	basic := contract.BasicData
+	if basic.AwardID != "N0001403C0001" || basic.AwardAmount.String() != "1500.00" || basic.AwardingSubAgency != "Department of the Navy" ||
+		basic.StartDate != (Date{2003, time.January, 15}) || basic.EndDate != (Date{2005, time.January, 14}) ||
+		basic.Description != "OCEAN ACOUSTICS RESEARCH" || basic.RecipientLocation.CityName != "LA JOLLA" {
+		t.Errorf("contract = %+v", basic)
+	}
+	if len(contract.Transactions) != 2 || contract.Transactions[0].ModificationNumber != "0" ||
+		contract.Transactions[1].FederalActionObligation.String() != "-500.00" || !contract.Transactions[1].IsFPDS {
+		t.Errorf("contract transactions = %+v", contract.Transactions)
+	}
+	if contract.Raw == nil || len(contract.Raw.BulkRows) != 2 {
+		t.Fatalf("raw rows = %+v", contract.Raw)
+	}
+	var row map[string]string
+	if err := json.Unmarshal(contract.Raw.BulkRows[0], &row); err != nil || row["transaction_description"] != "OCEAN ACOUSTICS RESEARCH, PHASE I" {
+		t.Errorf("first raw row = %v, %v", row, err)
+	}
+
+	grant := awards["ASST_NON_0349812_4900"]
+	if grant == nil {
+		t.Fatalf("grant not saved")
+	}
+	if grant.BasicData.AwardAmount.String() != "250000" || grant.BasicData.AwardID != "0349812" ||
+		len(grant.Transactions) != 1 || grant.Transactions[0].AssistanceListingNumber != "47.050" || grant.Transactions[0].IsFPDS {
+		t.Errorf("grant = %+v, transactions %+v", grant.BasicData, grant.Transactions)
+	}
+
+	// Rerunning from the kept archive leaves the saved files alone
+	opts.file = filepath.Join(s.outputRoot, bulkDir, "All_PrimeTransactions_2024.zip")
+	saved := s.summary.saved["contracts"] + s.summary.saved["grants"]
+	if err := s.runBulkDownload(context.Background(), []string{"contracts", "grants"}, opts); err != nil {
+		t.Fatalf("runBulkDownload(-file): %v", err)
+	}
+	if statusChecks != 2 || s.summary.saved["contracts"]+s.summary.saved["grants"] != saved || saved != 2 {
+		t.Errorf("rerun requested a download or saved again")
+	}
+}
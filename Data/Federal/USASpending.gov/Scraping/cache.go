@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Default response cache directory under the output root
+const defaultCacheDir = ".cache"
+
+// cacheEndpoint names the API paths that share a cache TTL. The first
+// matching prefix wins.
+type cacheEndpoint struct {
+	name   string
+	prefix string // Path below /api/v2/
+	ttl    time.Duration
+}
+
+// Default TTLs. Award documents change rarely; searches pick up new awards
+// daily; download jobs must always be asked afresh.
+var cacheEndpoints = []cacheEndpoint{
+	{"funding", "awards/funding/", 7 * 24 * time.Hour},
+	{"awards", "awards/", 7 * 24 * time.Hour},
+	{"transactions", "transactions/", 7 * 24 * time.Hour},
+	{"subawards", "subawards/", 7 * 24 * time.Hour},
+	{"idvs", "idvs/", 7 * 24 * time.Hour},
+	{"recipient", "recipient/", 30 * 24 * time.Hour},
+	{"search", "search/", 24 * time.Hour},
+	{"download", "download/", 0},
+	{"bulk_download", "bulk_download/", 0},
+}
+
+// Name of the TTL for paths no endpoint matches
+const cacheDefaultEndpoint = "default"
+
+// defaultCacheTTLs returns the TTL of every endpoint by name.
+func defaultCacheTTLs() map[string]time.Duration {
+	ttls := map[string]time.Duration{cacheDefaultEndpoint: 24 * time.Hour}
+	for _, endpoint := range cacheEndpoints {
+		ttls[endpoint.name] = endpoint.ttl
+	}
+	return ttls
+}
+
+// parseCacheTTLs applies a comma-separated list of endpoint=duration
+// overrides, such as "awards=720h,search=0", to the default TTLs.
+func parseCacheTTLs(spec string) (map[string]time.Duration, error) {
+	ttls := defaultCacheTTLs()
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid cache TTL %q: want endpoint=duration", item)
+		}
+		name = strings.TrimSpace(name)
+		if _, known := ttls[name]; !known {
+			return nil, fmt.Errorf("unknown cache endpoint %q (known: %s)", name, strings.Join(cacheEndpointNames(), ", "))
+		}
+		ttl, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid cache TTL for %s: %w", name, err)
+		}
+		ttls[name] = ttl
+	}
+	return ttls, nil
+}
+
+func cacheEndpointNames() []string {
+	names := []string{cacheDefaultEndpoint}
+	for _, endpoint := range cacheEndpoints {
+		names = append(names, endpoint.name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// endpointName returns the TTL name for a request path.
+func endpointName(path string) string {
+	if i := strings.Index(path, "/api/v2/"); i >= 0 {
+		path = path[i+len("/api/v2/"):]
+	}
+	for _, endpoint := range cacheEndpoints {
+		if strings.HasPrefix(path, endpoint.prefix) {
+			return endpoint.name
+		}
+	}
+	return cacheDefaultEndpoint
+}
+
+// ResponseCache is an http.RoundTripper that keeps successful API responses
+// on disk, keyed by a hash of the method, URL and request body. A response
+// younger than its endpoint's TTL is served without a request. An older one
+// that carried an ETag or Last-Modified is revalidated with a conditional
+// GET and served again on 304 Not Modified. Offline, every request is served
+// from the cache regardless of age, and a miss is an error.
+type ResponseCache struct {
+	dir     string
+	ttls    map[string]time.Duration
+	offline bool
+	next    http.RoundTripper
+	now     func() time.Time
+
+	hits, revalidated, misses atomic.Int64
+}
+
+// cacheEntry is one stored response.
+type cacheEntry struct {
+	cassetteEpisode
+	StoredAt     time.Time `json:"stored_at"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+}
+
+// NewResponseCache returns a cache over dir. A nil ttls uses the defaults,
+// and next defaults to http.DefaultTransport.
+func NewResponseCache(dir string, ttls map[string]time.Duration, offline bool, next http.RoundTripper) *ResponseCache {
+	if ttls == nil {
+		ttls = defaultCacheTTLs()
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &ResponseCache{dir: dir, ttls: ttls, offline: offline, next: next, now: time.Now}
+}
+
+func (c *ResponseCache) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Only API responses are cached; file downloads such as the bulk zip on
+	// files.usaspending.gov pass straight through
+	if !strings.Contains(req.URL.Path, "/api/v2/") {
+		if c.offline {
+			return nil, fmt.Errorf("%s %s is not an API request and cannot be served offline", req.Method, req.URL)
+		}
+		return c.next.RoundTrip(req)
+	}
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error reading request body: %w", err)
+		}
+	}
+	ttl := c.ttls[endpointName(req.URL.Path)]
+	key := requestHash(req.Method, req.URL.String(), body)
+	path := filepath.Join(c.dir, key[:2], key+".json")
+
+	entry, err := readCacheEntry(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("Warning: ignoring cached response %s: %v", path, err)
+		entry = nil
+	}
+	if entry != nil && (c.offline || c.now().Sub(entry.StoredAt) < ttl) {
+		c.hits.Add(1)
+		return entry.response(req), nil
+	}
+	if c.offline {
+		c.misses.Add(1)
+		return nil, fmt.Errorf("%s %s is not in the response cache (offline)", req.Method, req.URL)
+	}
+
+	out := req.Clone(req.Context())
+	out.Body = io.NopCloser(bytes.NewReader(body))
+	out.ContentLength = int64(len(body))
+	if entry != nil && req.Method == http.MethodGet {
+		if entry.ETag != "" {
+			out.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			out.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+	resp, err := c.next.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		resp.Body.Close()
+		c.revalidated.Add(1)
+		entry.StoredAt = c.now()
+		if err := writeJSONFile(path, entry); err != nil {
+			log.Printf("Warning: could not update cached response %s: %v", path, err)
+		}
+		return entry.response(req), nil
+	}
+	c.misses.Add(1)
+	if resp.StatusCode != http.StatusOK || ttl <= 0 {
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	entry = &cacheEntry{
+		cassetteEpisode: cassetteEpisode{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Status: resp.StatusCode,
+			Header: make(http.Header),
+		},
+		StoredAt:     c.now(),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+	if len(body) > 0 {
+		entry.RequestBody = jsonOrString(body)
+	}
+	if contentType := resp.Header.Get("Content-Type"); contentType != "" {
+		entry.Header.Set("Content-Type", contentType)
+	}
+	entry.setBody(respBody)
+	if err := ensureDirectoryExists(filepath.Dir(path)); err != nil {
+		return nil, fmt.Errorf("error creating cache directory: %w", err)
+	}
+	if err := writeJSONFile(path, entry); err != nil {
+		log.Printf("Warning: could not cache %s %s: %v", req.Method, req.URL, err)
+	}
+	return entry.response(req), nil
+}
+
+// logStats reports how many requests the cache answered.
+func (c *ResponseCache) logStats() {
+	log.Printf("  API responses: %d from the cache, %d revalidated, %d fetched",
+		c.hits.Load(), c.revalidated.Load(), c.misses.Load())
+}
+
+func readCacheEntry(path string) (*cacheEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("error decoding %s: %w", path, err)
+	}
+	return &entry, nil
+}
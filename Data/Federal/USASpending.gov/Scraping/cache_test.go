@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestResponseCache(t *testing.T) {
+	var mu sync.Mutex
+	var served []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		served = append(served, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+		if r.URL.Path == "/api/v2/awards/CONT_AWD_1/" {
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+		if r.URL.Path == "/api/v2/awards/MISSING/" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Write([]byte(`{"path":"` + r.URL.Path + `","request":"` + strings.ReplaceAll(string(body), `"`, `'`) + `"}`))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	ttls, err := parseCacheTTLs("awards=1h, search=10m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, time.May, 1, 12, 0, 0, 0, time.UTC)
+	cache := NewResponseCache(dir, ttls, false, nil)
+	cache.now = func() time.Time { return now }
+	client := &http.Client{Transport: cache}
+
+	fetch := func(t *testing.T, client *http.Client, method, path, body string) (int, string, error) {
+		t.Helper()
+		req, _ := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		resp, err := client.Do(req)
+		if err != nil {
+			return 0, "", err
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		var compact bytes.Buffer
+		if json.Compact(&compact, data) == nil {
+			data = compact.Bytes()
+		}
+		return resp.StatusCode, string(data), nil
+	}
+	count := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(served)
+	}
+
+	_, first, _ := fetch(t, client, "GET", "/api/v2/awards/CONT_AWD_1/", "")
+	_, second, _ := fetch(t, client, "GET", "/api/v2/awards/CONT_AWD_1/", "")
+	if count() != 1 || first != second {
+		t.Errorf("fresh response refetched: %d requests, %q then %q", count(), first, second)
+	}
+
+	// Past the TTL the ETag is sent and a 304 serves the stored body
+	now = now.Add(2 * time.Hour)
+	if _, body, _ := fetch(t, client, "GET", "/api/v2/awards/CONT_AWD_1/", ""); count() != 2 || body != first {
+		t.Errorf("revalidation: %d requests, body %q", count(), body)
+	}
+	if _, body, _ := fetch(t, client, "GET", "/api/v2/awards/CONT_AWD_1/", ""); count() != 2 || body != first {
+		t.Errorf("revalidated response refetched: %d requests, body %q", count(), body)
+	}
+
+	// Search bodies are part of the key; download jobs and errors are never stored
+	fetch(t, client, "POST", "/api/v2/search/spending_by_award/", `{"page":1}`)
+	fetch(t, client, "POST", "/api/v2/search/spending_by_award/", `{"page":2}`)
+	fetch(t, client, "POST", "/api/v2/search/spending_by_award/", `{"page":1}`)
+	fetch(t, client, "GET", "/api/v2/download/status/", "")
+	fetch(t, client, "GET", "/api/v2/download/status/", "")
+	fetch(t, client, "GET", "/api/v2/awards/MISSING/", "")
+	if status, _, _ := fetch(t, client, "GET", "/api/v2/awards/MISSING/", ""); count() != 8 || status != http.StatusNotFound {
+		t.Errorf("%d requests after searches, downloads and 404s, want 8 (last status %d)", count(), status)
+	}
+
+	// Files outside the API, such as a bulk download zip, bypass the cache
+	fetch(t, client, "GET", "/generated_downloads/awards.zip", "")
+	fetch(t, client, "GET", "/generated_downloads/awards.zip", "")
+	if count() != 10 {
+		t.Errorf("%d requests after two zip downloads, want 10", count())
+	}
+	if cache.hits.Load() != 3 || cache.revalidated.Load() != 1 {
+		t.Errorf("%d hits, %d revalidated", cache.hits.Load(), cache.revalidated.Load())
+	}
+
+	// Offline, stale entries are served and misses fail without a request
+	offline := &http.Client{Transport: NewResponseCache(dir, ttls, true, nil)}
+	now = now.Add(24 * time.Hour)
+	if _, body, err := fetch(t, offline, "POST", "/api/v2/search/spending_by_award/", `{"page":2}`); err != nil || !strings.Contains(body, "'page':2") {
+		t.Errorf("offline hit = %q, %v", body, err)
+	}
+	if _, _, err := fetch(t, offline, "GET", "/api/v2/awards/CONT_AWD_2/", ""); err == nil || !strings.Contains(err.Error(), "not in the response cache") {
+		t.Errorf("offline miss: %v", err)
+	}
+	if _, _, err := fetch(t, offline, "GET", "/generated_downloads/awards.zip", ""); err == nil || !strings.Contains(err.Error(), "not an API request") {
+		t.Errorf("offline download: %v", err)
+	}
+	if count() != 10 {
+		t.Errorf("offline run sent %d requests", count()-10)
+	}
+
+	if _, err := parseCacheTTLs("award=1h"); err == nil {
+		t.Error("unknown endpoint accepted")
+	}
+}
+
+func TestOfflineRunMatchesCachedRun(t *testing.T) {
+	server := newFakeAPI(t)
+	cacheDir := t.TempDir()
+
+	online := NewScraper(ScraperOptions{
+		OutputRoot: t.TempDir(),
+		APIBase:    server.URL,
+		Transport:  NewResponseCache(cacheDir, nil, false, nil),
+	})
+	if _, err := online.scrapeAndSaveEnhancedData(context.Background(), []string{"contracts", "grants"}); err != nil {
+		t.Fatalf("online run: %v", err)
+	}
+	requests := len(server.Requests())
+	server.Close()
+
+	offline := NewScraper(ScraperOptions{
+		OutputRoot: t.TempDir(),
+		APIBase:    server.URL,
+		Transport:  NewResponseCache(cacheDir, nil, true, nil),
+	})
+	if _, err := offline.scrapeAndSaveEnhancedData(context.Background(), []string{"contracts", "grants"}); err != nil {
+		t.Fatalf("offline run: %v", err)
+	}
+
+	// The detail 404 was not cached, so the offline run saves that award
+	// without detail too, and every other response comes from the cache
+	cache := offline.client.Transport.(*ResponseCache)
+	if int(cache.hits.Load()) != requests-1 || cache.misses.Load() != 1 {
+		t.Errorf("offline run: %d hits, %d misses; online run sent %d requests", cache.hits.Load(), cache.misses.Load(), requests)
+	}
+	cached, replayed := savedTree(t, online.outputRoot), savedTree(t, offline.outputRoot)
+	if strings.Join(sortedKeys(cached), "\n") != strings.Join(sortedKeys(replayed), "\n") {
+		t.Errorf("online run saved %v, offline run %v", sortedKeys(cached), sortedKeys(replayed))
+	}
+}
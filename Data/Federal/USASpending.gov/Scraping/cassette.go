@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CassetteMode selects whether a Cassette records or replays.
+type CassetteMode int
+
+const (
+	CassetteRecord CassetteMode = iota // Send requests and save each response
+	CassetteReplay                     // Answer requests from saved responses only
+)
+
+// Cassette is an http.RoundTripper that records API responses to a directory
+// and replays them later, so a run can be repeated or tested without the
+// network. Each distinct method, URL and request body is one episode file;
+// when the same request is sent again while recording, the last response
+// wins.
+type Cassette struct {
+	dir  string
+	mode CassetteMode
+	next http.RoundTripper
+}
+
+// cassetteEpisode is one recorded exchange. JSON bodies are stored as is so
+// the files can be read and edited; anything else is base64.
+type cassetteEpisode struct {
+	Method      string          `json:"method"`
+	URL         string          `json:"url"`
+	RequestBody json.RawMessage `json:"request_body,omitempty"`
+	Status      int             `json:"status"`
+	Header      http.Header     `json:"header,omitempty"`
+	Body        json.RawMessage `json:"body,omitempty"`
+	BodyBytes   []byte          `json:"body_base64,omitempty"`
+}
+
+// Response headers worth keeping; the rest vary from run to run
+var cassetteHeaders = []string{"Content-Type", "Retry-After"}
+
+// NewCassette returns a Cassette over dir. next sends the requests being
+// recorded and defaults to http.DefaultTransport.
+func NewCassette(dir string, mode CassetteMode, next http.RoundTripper) *Cassette {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Cassette{dir: dir, mode: mode, next: next}
+}
+
+func (c *Cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error reading request body: %w", err)
+		}
+	}
+	path := filepath.Join(c.dir, cassetteKey(req.Method, req.URL.String(), body)+".json")
+
+	if c.mode == CassetteReplay {
+		episode, err := readCassetteEpisode(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("cassette %s has no response for %s %s", c.dir, req.Method, req.URL)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return episode.response(req), nil
+	}
+
+	out := req.Clone(req.Context())
+	out.Body = io.NopCloser(bytes.NewReader(body))
+	out.ContentLength = int64(len(body))
+	resp, err := c.next.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	episode := cassetteEpisode{
+		Method: req.Method,
+		URL:    req.URL.String(),
+		Status: resp.StatusCode,
+		Header: make(http.Header),
+	}
+	if len(body) > 0 {
+		episode.RequestBody = jsonOrString(body)
+	}
+	for _, name := range cassetteHeaders {
+		if value := resp.Header.Get(name); value != "" {
+			episode.Header.Set(name, value)
+		}
+	}
+	episode.setBody(respBody)
+	if err := ensureDirectoryExists(c.dir); err != nil {
+		return nil, fmt.Errorf("error creating cassette directory: %w", err)
+	}
+	if err := writeJSONFile(path, episode); err != nil {
+		return nil, fmt.Errorf("error recording %s %s: %w", req.Method, req.URL, err)
+	}
+	return episode.response(req), nil
+}
+
+// cassetteKey names the episode for a request.
+func cassetteKey(method, url string, body []byte) string {
+	return strings.ToLower(method) + "-" + requestHash(method, url, body)[:16]
+}
+
+// requestHash identifies a request by its method, URL and body.
+func requestHash(method, url string, body []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s\n", method, url)
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func readCassetteEpisode(path string) (*cassetteEpisode, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var episode cassetteEpisode
+	if err := json.Unmarshal(data, &episode); err != nil {
+		return nil, fmt.Errorf("error decoding %s: %w", path, err)
+	}
+	return &episode, nil
+}
+
+// setBody stores a response body, as JSON when it is JSON.
+func (e *cassetteEpisode) setBody(body []byte) {
+	if json.Valid(body) {
+		e.Body = body
+	} else {
+		e.BodyBytes = body
+	}
+}
+
+func (e *cassetteEpisode) response(req *http.Request) *http.Response {
+	body := []byte(e.Body)
+	if e.BodyBytes != nil {
+		body = e.BodyBytes
+	}
+	header := e.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.Status, http.StatusText(e.Status)),
+		StatusCode:    e.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// jsonOrString keeps a JSON request body readable and quotes anything else.
+func jsonOrString(data []byte) json.RawMessage {
+	if json.Valid(data) {
+		return data
+	}
+	quoted, _ := json.Marshal(string(data))
+	return quoted
+}
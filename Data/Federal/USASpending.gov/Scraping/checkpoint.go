@@ -0,0 +1,406 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default checkpoint directory name, created under the output root
+const defaultCheckpointDir = ".checkpoint"
+
+const checkpointFile = "checkpoint.json"
+
+// Checkpoint records run progress so an interrupted run can continue where it
+// stopped. The state file holds per-group phases and pagination cursors;
+// fetched search rows and enriched award IDs go to append-only files next to
+// it so progress is not lost between state saves. All methods are safe on a
+// nil *Checkpoint, which disables checkpointing.
+type Checkpoint struct {
+	mu  sync.Mutex
+	dir string
+
+	ProfileHash string                      `json:"profile_hash"`
+	StartedAt   string                      `json:"started_at"`
+	UpdatedAt   string                      `json:"updated_at"`
+	Groups      map[string]*GroupCheckpoint `json:"groups"`
+
+	enriched map[string]map[string]bool
+	pending  bool // A group finished with awards still missing detail
+}
+
+// GroupCheckpoint is the progress of one award group.
+type GroupCheckpoint struct {
+	SearchComplete bool                     `json:"search_complete"`
+	EnrichComplete bool                     `json:"enrich_complete"`
+	Windows        map[string]*WindowCursor `json:"windows,omitempty"` // Keyed by time window label
+}
+
+// WindowCursor is the last page fetched for one search time window.
+type WindowCursor struct {
+	Page                int    `json:"page"`
+	Fetched             int    `json:"fetched"`
+	LastRecordUniqueID  int    `json:"last_record_unique_id,omitempty"`
+	LastRecordSortValue string `json:"last_record_sort_value,omitempty"`
+	Done                bool   `json:"done"`
+}
+
+// newCheckpoint starts a fresh checkpoint in dir, discarding any old one.
+// Only the files a checkpoint writes are deleted; a non-empty directory that
+// does not hold a checkpoint is refused so a mistyped -checkpoint path cannot
+// wipe unrelated data.
+func newCheckpoint(dir, profileHash string) (*Checkpoint, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("error reading checkpoint directory: %w", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, checkpointFile)); len(entries) > 0 && err != nil {
+		return nil, fmt.Errorf("refusing to use %s as a checkpoint directory: it is not empty and holds no %s", dir, checkpointFile)
+	}
+
+	c := &Checkpoint{dir: dir}
+	if err := c.removeFiles(); err != nil {
+		return nil, fmt.Errorf("error clearing checkpoint: %w", err)
+	}
+	if err := ensureDirectoryExists(dir); err != nil {
+		return nil, fmt.Errorf("error creating checkpoint directory: %w", err)
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	c.ProfileHash = profileHash
+	c.StartedAt = now
+	c.UpdatedAt = now
+	c.Groups = make(map[string]*GroupCheckpoint)
+	c.enriched = make(map[string]map[string]bool)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.save(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// loadCheckpoint reopens the checkpoint in dir. The profile hash must match
+// the one the checkpoint was started with.
+func loadCheckpoint(dir, profileHash string) (*Checkpoint, error) {
+	data, err := os.ReadFile(filepath.Join(dir, checkpointFile))
+	if err != nil {
+		return nil, fmt.Errorf("error reading checkpoint: %w", err)
+	}
+
+	c := &Checkpoint{dir: dir, enriched: make(map[string]map[string]bool)}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("error decoding checkpoint: %w", err)
+	}
+	if c.ProfileHash != profileHash {
+		return nil, fmt.Errorf("checkpoint in %s was written for a different search profile (hash %.12s, now %.12s)",
+			dir, c.ProfileHash, profileHash)
+	}
+	if c.Groups == nil {
+		c.Groups = make(map[string]*GroupCheckpoint)
+	}
+
+	for groupName := range awardTypeGroups {
+		ids, err := readLines(c.enrichedLog(groupName))
+		if err != nil {
+			return nil, err
+		}
+		set := make(map[string]bool, len(ids))
+		for _, id := range ids {
+			set[id] = true
+		}
+		c.enriched[groupName] = set
+	}
+	return c, nil
+}
+
+func (c *Checkpoint) searchLog(groupName string) string {
+	return filepath.Join(c.dir, groupName+".search.jsonl")
+}
+
+func (c *Checkpoint) enrichedLog(groupName string) string {
+	return filepath.Join(c.dir, groupName+".enriched.txt")
+}
+
+// group returns the progress entry for a group; the caller holds c.mu.
+func (c *Checkpoint) group(groupName string) *GroupCheckpoint {
+	g := c.Groups[groupName]
+	if g == nil {
+		g = &GroupCheckpoint{Windows: make(map[string]*WindowCursor)}
+		c.Groups[groupName] = g
+	}
+	if g.Windows == nil {
+		g.Windows = make(map[string]*WindowCursor)
+	}
+	return g
+}
+
+// save writes the state file through a temp file and rename; the caller
+// holds c.mu.
+func (c *Checkpoint) save() error {
+	c.UpdatedAt = time.Now().Format(time.RFC3339)
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding checkpoint: %w", err)
+	}
+
+	final := filepath.Join(c.dir, checkpointFile)
+	tmp := final + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("error writing checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp, final); err != nil {
+		return fmt.Errorf("error writing checkpoint: %w", err)
+	}
+	return nil
+}
+
+func (c *Checkpoint) searchComplete(groupName string) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.group(groupName).SearchComplete
+}
+
+func (c *Checkpoint) enrichComplete(groupName string) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.group(groupName).EnrichComplete
+}
+
+// windowCursor returns a copy of the saved cursor for a window, or nil.
+func (c *Checkpoint) windowCursor(groupName, window string) *WindowCursor {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cursor := c.group(groupName).Windows[window]
+	if cursor == nil {
+		return nil
+	}
+	copied := *cursor
+	return &copied
+}
+
+// recordPage appends a page of results to the group's search log and moves
+// the window cursor past it.
+func (c *Checkpoint) recordPage(groupName, window string, cursor WindowCursor, results []Award) error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := appendJSONLines(c.searchLog(groupName), results); err != nil {
+		return err
+	}
+	c.group(groupName).Windows[window] = &cursor
+	return c.save()
+}
+
+func (c *Checkpoint) markWindowDone(groupName, window string) error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	g := c.group(groupName)
+	cursor := g.Windows[window]
+	if cursor == nil {
+		cursor = &WindowCursor{}
+		g.Windows[window] = cursor
+	}
+	cursor.Done = true
+	return c.save()
+}
+
+func (c *Checkpoint) markSearchComplete(groupName string) error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.group(groupName).SearchComplete = true
+	return c.save()
+}
+
+func (c *Checkpoint) markEnrichComplete(groupName string) error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.group(groupName).EnrichComplete = true
+	return c.save()
+}
+
+// markEnrichPending records that a group finished enrichment with awards
+// still missing detail, so the checkpoint is kept for -resume to retry them.
+func (c *Checkpoint) markEnrichPending() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pending = true
+}
+
+// hasPending reports whether any group was left open by markEnrichPending.
+func (c *Checkpoint) hasPending() bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.pending
+}
+
+// searchAwards returns every search row logged for a group so far.
+func (c *Checkpoint) searchAwards(groupName string) ([]Award, error) {
+	if c == nil {
+		return nil, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	lines, err := readLines(c.searchLog(groupName))
+	if err != nil {
+		return nil, err
+	}
+
+	awards := make([]Award, 0, len(lines))
+	for i, line := range lines {
+		var award Award
+		if err := json.Unmarshal([]byte(line), &award); err != nil {
+			// A crash can cut the last line short; everything before it is intact
+			if i == len(lines)-1 {
+				break
+			}
+			return nil, fmt.Errorf("error decoding %s line %d: %w", c.searchLog(groupName), i+1, err)
+		}
+		awards = append(awards, award)
+	}
+	return awards, nil
+}
+
+func (c *Checkpoint) isEnriched(groupName, generatedInternalID string) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.enriched[groupName][generatedInternalID]
+}
+
+// markEnriched records that an award's detail has been saved.
+func (c *Checkpoint) markEnriched(groupName, generatedInternalID string) error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.enriched[groupName] == nil {
+		c.enriched[groupName] = make(map[string]bool)
+	}
+	c.enriched[groupName][generatedInternalID] = true
+
+	file, err := os.OpenFile(c.enrichedLog(groupName), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("error opening enriched log: %w", err)
+	}
+	defer file.Close()
+	_, err = fmt.Fprintln(file, generatedInternalID)
+	return err
+}
+
+// remove deletes the checkpoint once a run has finished. The directory
+// itself is removed only if nothing else was put in it.
+func (c *Checkpoint) remove() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.removeFiles(); err != nil {
+		return err
+	}
+	if entries, err := os.ReadDir(c.dir); err == nil && len(entries) == 0 {
+		return os.Remove(c.dir)
+	}
+	return nil
+}
+
+// removeFiles deletes the files a checkpoint writes in its directory.
+func (c *Checkpoint) removeFiles() error {
+	files := []string{filepath.Join(c.dir, checkpointFile), filepath.Join(c.dir, checkpointFile+".tmp")}
+	for groupName := range awardTypeGroups {
+		files = append(files, c.searchLog(groupName), c.enrichedLog(groupName))
+	}
+	for _, file := range files {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func appendJSONLines(filename string, awards []Award) error {
+	file, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("error opening %s: %w", filename, err)
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	for i := range awards {
+		data, err := awardRow(&awards[i])
+		if err != nil {
+			return fmt.Errorf("error encoding award: %w", err)
+		}
+		writer.Write(data)
+		writer.WriteByte('\n')
+	}
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("error writing %s: %w", filename, err)
+	}
+	return file.Sync()
+}
+
+// readLines returns the non-empty lines of a file, or nil if it does not exist.
+func readLines(filename string) ([]string, error) {
+	file, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s: %w", filename, err)
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", filename, err)
+	}
+	return lines, nil
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// pagedServer serves three pages of grants and fails page 2 until healed.
+// Detail requests for IDs in brokenDetail always fail.
+type pagedServer struct {
+	*httptest.Server
+	mu           sync.Mutex
+	healed       bool
+	brokenDetail map[string]bool
+	pagesServed  []int
+	detailsFetch []string
+}
+
+func newPagedServer(t *testing.T) *pagedServer {
+	t.Helper()
+	p := &pagedServer{}
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/spending_by_award_count/"):
+			fmt.Fprint(w, `{"results":{"grants":6}}`)
+		case strings.HasSuffix(r.URL.Path, "/spending_by_award/"):
+			var req APIRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			if req.Page == 2 && !p.healed {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			p.pagesServed = append(p.pagesServed, req.Page)
+			resp := APIResponse{PageMetadata: PageMetadata{Page: req.Page, HasNext: req.Page < 3}}
+			for i := 0; i < 2; i++ {
+				resp.Results = append(resp.Results, Award{
+					GeneratedInternalID: fmt.Sprintf("ASST_NON_P%dR%d_075", req.Page, i),
+					RecipientName:       "UNIVERSITY OF CALIFORNIA, DAVIS",
+					StartDate:           Date{2020, time.January, 1},
+				})
+			}
+			json.NewEncoder(w).Encode(resp)
+		default:
+			id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/v2/awards/"), "/")
+			p.detailsFetch = append(p.detailsFetch, id)
+			if p.brokenDetail[id] {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			fmt.Fprintf(w, `{"generated_unique_award_id":%q}`, id)
+		}
+	}))
+	t.Cleanup(p.Close)
+	return p
+}
+
+func TestResumeContinuesSearchAndEnrichment(t *testing.T) {
+	server := newPagedServer(t)
+	outputRoot := t.TempDir()
+	checkpointDir := filepath.Join(outputRoot, defaultCheckpointDir)
+	hash := defaultSearchProfile().Hash()
+
+	checkpoint, err := newCheckpoint(checkpointDir, hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestScraper(server.URL, 1)
+	s.outputRoot = outputRoot
+	s.checkpoint = checkpoint
+
+	if _, err := s.scrapeAndSaveEnhancedData(context.Background(), []string{"grants"}); err == nil {
+		t.Fatal("expected the first run to fail on page 2")
+	}
+
+	// Pretend one award from page 1 was already enriched before the crash
+	if err := checkpoint.markEnriched("grants", "ASST_NON_P1R0_075"); err != nil {
+		t.Fatal(err)
+	}
+
+	server.mu.Lock()
+	server.healed = true
+	server.mu.Unlock()
+
+	resumed, err := loadCheckpoint(checkpointDir, hash)
+	if err != nil {
+		t.Fatalf("loadCheckpoint: %v", err)
+	}
+	s.checkpoint = resumed
+
+	saved, err := s.scrapeAndSaveEnhancedData(context.Background(), []string{"grants"})
+	if err != nil {
+		t.Fatalf("resumed run: %v", err)
+	}
+	if saved != 5 {
+		t.Errorf("resumed run saved %d awards, want 5", saved)
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if want := []int{1, 2, 3}; fmt.Sprint(server.pagesServed) != fmt.Sprint(want) {
+		t.Errorf("pages served = %v, want %v (page 1 must not be refetched)", server.pagesServed, want)
+	}
+	for _, id := range server.detailsFetch {
+		if id == "ASST_NON_P1R0_075" {
+			t.Errorf("already enriched award %s was fetched again", id)
+		}
+	}
+	if !resumed.enrichComplete("grants") {
+		t.Error("grants not marked complete in checkpoint")
+	}
+}
+
+func TestLoadCheckpointRejectsDifferentProfile(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), defaultCheckpointDir)
+	if _, err := newCheckpoint(dir, "aaaa"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadCheckpoint(dir, "bbbb"); err == nil {
+		t.Error("expected an error for a mismatched profile hash")
+	}
+}
+
+func TestFailedDetailLeavesGroupOpenForResume(t *testing.T) {
+	server := newPagedServer(t)
+	outputRoot := t.TempDir()
+	checkpointDir := filepath.Join(outputRoot, defaultCheckpointDir)
+	hash := defaultSearchProfile().Hash()
+
+	checkpoint, err := newCheckpoint(checkpointDir, hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestScraper(server.URL, 1)
+	s.outputRoot = outputRoot
+	s.checkpoint = checkpoint
+
+	if _, err := s.scrapeAndSaveEnhancedData(context.Background(), []string{"grants"}); err == nil {
+		t.Fatal("expected the first run to fail on page 2")
+	}
+	server.mu.Lock()
+	server.healed = true
+	server.brokenDetail = map[string]bool{"ASST_NON_P3R0_075": true}
+	server.mu.Unlock()
+
+	resumed, err := loadCheckpoint(checkpointDir, hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.checkpoint = resumed
+	if _, err := s.scrapeAndSaveEnhancedData(context.Background(), []string{"grants"}); err != nil {
+		t.Fatal(err)
+	}
+	if resumed.enrichComplete("grants") {
+		t.Error("grants marked complete although a detail fetch failed")
+	}
+	if !resumed.hasPending() {
+		t.Error("checkpoint does not report the group as pending")
+	}
+	finish(s)
+	if _, err := os.Stat(filepath.Join(checkpointDir, checkpointFile)); err != nil {
+		t.Fatalf("checkpoint was removed with awards still missing detail: %v", err)
+	}
+
+	// The next resume fetches only the award that failed
+	server.mu.Lock()
+	server.brokenDetail = nil
+	server.detailsFetch = nil
+	server.mu.Unlock()
+	again, err := loadCheckpoint(checkpointDir, hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.checkpoint = again
+	if _, err := s.scrapeAndSaveEnhancedData(context.Background(), []string{"grants"}); err != nil {
+		t.Fatal(err)
+	}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if want := []string{"ASST_NON_P3R0_075"}; fmt.Sprint(server.detailsFetch) != fmt.Sprint(want) {
+		t.Errorf("details fetched on resume = %v, want %v", server.detailsFetch, want)
+	}
+	if !again.enrichComplete("grants") {
+		t.Error("grants not marked complete once every detail was fetched")
+	}
+}
+
+func TestNewCheckpointKeepsUnrelatedFiles(t *testing.T) {
+	dir := t.TempDir()
+	notes := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(notes, []byte("keep me"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newCheckpoint(dir, "aaaa"); err == nil {
+		t.Fatal("expected a non-empty directory without a checkpoint to be refused")
+	}
+
+	// A directory that already holds a checkpoint is reused; only its own
+	// files are replaced
+	checkpoint, err := newCheckpoint(filepath.Join(dir, "run"), "aaaa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkpoint.markEnriched("grants", "ASST_NON_1_075"); err != nil {
+		t.Fatal(err)
+	}
+	other := filepath.Join(dir, "run", "other.txt")
+	if err := os.WriteFile(other, []byte("keep me"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fresh, err := newCheckpoint(filepath.Join(dir, "run"), "aaaa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(fresh.enrichedLog("grants")); !os.IsNotExist(err) {
+		t.Errorf("old enriched log survived a fresh checkpoint: %v", err)
+	}
+
+	if err := fresh.remove(); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{notes, other} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%s was deleted: %v", path, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "run", checkpointFile)); !os.IsNotExist(err) {
+		t.Errorf("checkpoint file survived remove: %v", err)
+	}
+}
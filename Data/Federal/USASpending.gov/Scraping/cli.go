@@ -0,0 +1,717 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// command is a single CLI subcommand such as "search" or "stats".
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, args []string) error
+}
+
+func commandList() []command {
+	return []command{
+		{"search", "collect basic award listings and save one dump per group", runSearch},
+		{"enrich", "fetch award details for previously saved search dumps", runEnrich},
+		{"scrape", "search and enrich in one pass", runScrape},
+		{"update", "refresh awards modified since the last successful run", runUpdate},
+		{"stats", "summarize the saved award tree", runStats},
+		{"verify", "check that every saved file parses", runVerify},
+		{"schema-drift", "compare saved raw API payloads with the declared fields", runSchemaDrift},
+		{"recipients", "resolve saved awards to canonical UC entities and list the rest", runRecipients},
+		{"relayout", "move saved awards into a new directory layout", runRelayout},
+		{"subawards", "report subaward flows from UC primes and to UC subrecipients", runSubawards},
+		{"funding", "roll up saved award funding by campus and federal account", runFunding},
+		{"graph", "export the IDV parent/child award graph as JSON and DOT", runGraph},
+		{"hierarchy", "fetch the recipient profiles behind saved awards and their parent tree", runHierarchy},
+		{"bulk", "request a bulk award download and save the UC awards in it", runBulk},
+		{"export", "export the saved awards as a SQLite database or Parquet files (export sqlite|parquet)", runExport},
+	}
+}
+
+// run dispatches to a subcommand. Cancelling ctx stops the command once its
+// in-flight writes have finished, and run then returns errInterrupted.
+func run(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		printUsage(os.Stderr)
+		return fmt.Errorf("no command given")
+	}
+
+	name := args[0]
+	if name == "help" || name == "-h" || name == "--help" {
+		printUsage(os.Stdout)
+		return nil
+	}
+
+	for _, cmd := range commandList() {
+		if cmd.name == name {
+			err := cmd.run(ctx, args[1:])
+			if err != nil && ctx.Err() != nil && errors.Is(err, context.Canceled) {
+				return errInterrupted
+			}
+			return err
+		}
+	}
+
+	printUsage(os.Stderr)
+	return fmt.Errorf("unknown command %q", name)
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: usaspending-scraper <command> [flags]\n\nCommands:\n")
+	for _, cmd := range commandList() {
+		fmt.Fprintf(w, "  %-13s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(w, "\nRun 'usaspending-scraper <command> -h' for the flags of a command.\n")
+}
+
+// commonFlags are shared by every subcommand that talks to the API or reads
+// the output tree.
+type commonFlags struct {
+	groups       string
+	outputRoot   string
+	delay        time.Duration
+	dryRun       bool
+	configFile   string
+	profileName  string
+	aliases      string
+	maxAttempts  int
+	retryDelay   time.Duration
+	workers      int
+	rateLimit    float64
+	burst        int
+	resume       bool
+	checkpoint   string
+	transactions bool
+	subawards    bool
+	funding      bool
+	apiBase      string
+	record       string
+	replay       string
+	cache        bool
+	cacheTTL     string
+	offline      bool
+	years        string
+
+	noCheckpoint bool // Set by commands that do not support -resume
+
+	profile  *SearchProfile     // Resolved from configFile and profileName
+	resolver *RecipientResolver // Loaded from aliases
+}
+
+func (c *commonFlags) register(fs *flag.FlagSet, network bool) {
+	fs.StringVar(&c.groups, "groups", "", "comma-separated award groups ("+strings.Join(awardTypeGroupOrder, ", ")+") or \"all\" (default: the profile's groups, or all)")
+	fs.StringVar(&c.outputRoot, "out", defaultOutputRoot, "output root that holds the Contracts/, Grants/, ... directories (overrides the profile's output_dir)")
+	fs.StringVar(&c.configFile, "config", "", "JSON file of named search profiles")
+	fs.StringVar(&c.profileName, "profile", defaultProfileName, "search profile to use")
+	fs.StringVar(&c.aliases, "aliases", "", "JSON alias table mapping recipient UEIs, names and cities to UC entities (default: the built-in table)")
+	if network {
+		fs.DurationVar(&c.delay, "delay", 0, "extra pause between search pages and groups, on top of -rps")
+		fs.BoolVar(&c.dryRun, "dry-run", false, "print the API requests instead of sending them")
+
+		retry := DefaultRetryPolicy()
+		fs.IntVar(&c.maxAttempts, "max-attempts", retry.MaxAttempts, "attempts per API call before giving up on 429/502/503/504 and timeouts")
+		fs.DurationVar(&c.retryDelay, "retry-delay", retry.BaseDelay, "initial retry backoff, doubled after each failed attempt")
+
+		fs.IntVar(&c.workers, "workers", 4, "concurrent award detail fetches")
+		fs.Float64Var(&c.rateLimit, "rps", 2, "API requests per second shared by search and detail calls (0 = unlimited)")
+		fs.IntVar(&c.burst, "burst", 4, "API requests allowed back to back before -rps applies")
+
+		fs.BoolVar(&c.resume, "resume", false, "continue an interrupted run from its checkpoint")
+		fs.StringVar(&c.checkpoint, "checkpoint", "", "checkpoint directory (default: <out>/"+defaultCheckpointDir+")")
+
+		fs.BoolVar(&c.transactions, "transactions", false, "also page through each award's transaction history")
+		fs.BoolVar(&c.subawards, "subawards", false, "also page through the subawards of awards that report any")
+		fs.BoolVar(&c.funding, "funding", false, "also page through each award's federal account funding")
+
+		fs.StringVar(&c.apiBase, "api", defaultAPIBase, "base URL of the USAspending API")
+		fs.StringVar(&c.record, "record", "", "save every API response to this cassette directory")
+		fs.StringVar(&c.replay, "replay", "", "answer API requests from this cassette directory instead of the network")
+
+		fs.BoolVar(&c.cache, "cache", false, "reuse API responses saved in <out>/"+defaultCacheDir+" while they are within their TTL")
+		fs.StringVar(&c.cacheTTL, "cache-ttl", "", "comma-separated endpoint=duration TTL overrides, e.g. awards=720h,search=0 (endpoints: "+strings.Join(cacheEndpointNames(), ", ")+")")
+		fs.BoolVar(&c.offline, "offline", false, "serve every API request from the response cache and fail on a miss (implies -cache)")
+
+		fs.StringVar(&c.years, "years", "", "year bucketing of {year} and {fiscal_year} for a tree with no saved awards yet: federal, uc or calendar (default: the one in layout.json, or calendar)")
+	}
+}
+
+// resolve loads the selected profile and applies its defaults to any flags
+// that were not given explicitly.
+func (c *commonFlags) resolve(fs *flag.FlagSet) error {
+	profile, err := resolveSearchProfile(c.configFile, c.profileName)
+	if err != nil {
+		return err
+	}
+	c.profile = profile
+
+	resolver, err := loadRecipientResolver(c.aliases)
+	if err != nil {
+		return err
+	}
+	c.resolver = resolver
+
+	outputSet := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "out" {
+			outputSet = true
+		}
+	})
+	if !outputSet && profile.OutputDir != "" {
+		c.outputRoot = profile.OutputDir
+	}
+
+	if strings.TrimSpace(c.groups) == "" && len(profile.Groups) > 0 {
+		c.groups = strings.Join(profile.Groups, ",")
+	}
+	return nil
+}
+
+// groupList resolves the -groups flag into award groups in processing order.
+func (c *commonFlags) groupList() ([]string, error) {
+	return parseGroups(c.groups)
+}
+
+// scraper builds a Scraper from the flags, opening a fresh checkpoint or, with
+// -resume, the existing one. Dry runs are not checkpointed.
+func (c *commonFlags) scraper() (*Scraper, error) {
+	var checkpoint *Checkpoint
+	if c.noCheckpoint && c.resume {
+		return nil, fmt.Errorf("-resume is not supported by this command")
+	}
+	layout, err := runLayout(c.outputRoot, c.years, !c.dryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	var transport http.RoundTripper
+	switch {
+	case c.record != "" && c.replay != "":
+		return nil, fmt.Errorf("-record and -replay cannot be used together")
+	case c.record != "":
+		transport = NewCassette(c.record, CassetteRecord, nil)
+	case c.replay != "":
+		transport = NewCassette(c.replay, CassetteReplay, nil)
+	}
+	if c.cache || c.offline {
+		ttls, err := parseCacheTTLs(c.cacheTTL)
+		if err != nil {
+			return nil, err
+		}
+		transport = NewResponseCache(filepath.Join(c.outputRoot, defaultCacheDir), ttls, c.offline, transport)
+	}
+
+	// Offline runs send nothing, so there is nothing to pace
+	rateLimit := c.rateLimit
+	if c.offline {
+		rateLimit = 0
+	}
+
+	if !c.dryRun && !c.noCheckpoint {
+		dir := c.checkpoint
+		if dir == "" {
+			dir = filepath.Join(c.outputRoot, defaultCheckpointDir)
+		}
+
+		if c.resume {
+			checkpoint, err = loadCheckpoint(dir, c.profile.Hash())
+			if err == nil {
+				log.Printf("Resuming from checkpoint %s (started %s)", dir, checkpoint.StartedAt)
+			}
+		} else {
+			checkpoint, err = newCheckpoint(dir, c.profile.Hash())
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return NewScraper(ScraperOptions{
+		OutputRoot: c.outputRoot,
+		Delay:      c.delay,
+		DryRun:     c.dryRun,
+		Profile:    c.profile,
+		Retry: RetryPolicy{
+			MaxAttempts: c.maxAttempts,
+			BaseDelay:   c.retryDelay,
+			MaxDelay:    DefaultRetryPolicy().MaxDelay,
+		},
+		Workers:      c.workers,
+		RateLimit:    rateLimit,
+		Burst:        c.burst,
+		Checkpoint:   checkpoint,
+		Resolver:     c.resolver,
+		Layout:       layout,
+		Transactions: c.transactions,
+		Subawards:    c.subawards,
+		Funding:      c.funding,
+		APIBase:      c.apiBase,
+		Transport:    transport,
+	}), nil
+}
+
+// finish clears the checkpoint after a run completed without errors. It is
+// kept when some awards are still missing detail so -resume can retry them.
+func finish(s *Scraper) {
+	if s.checkpoint.hasPending() {
+		log.Printf("Keeping checkpoint %s: rerun with -resume to retry awards whose detail could not be fetched", s.checkpoint.dir)
+		return
+	}
+	if err := s.checkpoint.remove(); err != nil {
+		log.Printf("Warning: could not remove checkpoint: %v", err)
+	}
+}
+
+// recordProfile saves the profile name and hash next to the output.
+func (c *commonFlags) recordProfile() error {
+	if c.dryRun {
+		return nil
+	}
+	if err := writeProfileRecord(c.outputRoot, c.configFile, c.profile); err != nil {
+		return fmt.Errorf("error recording search profile: %w", err)
+	}
+	log.Printf("Using search profile %s (%s)", c.profile.Name, c.profile.Hash()[:12])
+	return nil
+}
+
+func parseGroups(value string) ([]string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || value == "all" {
+		return append([]string(nil), awardTypeGroupOrder...), nil
+	}
+
+	requested := make(map[string]bool)
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if _, ok := awardTypeGroups[name]; !ok {
+			return nil, fmt.Errorf("unknown award group %q (valid: %s)", name, strings.Join(awardTypeGroupOrder, ", "))
+		}
+		requested[name] = true
+	}
+
+	var groups []string
+	for _, name := range awardTypeGroupOrder {
+		if requested[name] {
+			groups = append(groups, name)
+		}
+	}
+	if len(groups) == 0 {
+		return nil, fmt.Errorf("no award groups selected")
+	}
+	return groups, nil
+}
+
+// parseCommandFlags parses args for a subcommand and returns the selected groups.
+func parseCommandFlags(name string, args []string, flags *commonFlags, network bool) ([]string, error) {
+	return parseFlagSet(flag.NewFlagSet(name, flag.ContinueOnError), args, flags, network)
+}
+
+// parseFlagSet is parseCommandFlags for a flag set that already carries
+// command-specific flags.
+func parseFlagSet(fs *flag.FlagSet, args []string, flags *commonFlags, network bool) ([]string, error) {
+	flags.register(fs, network)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+	if network && flags.maxAttempts < 1 {
+		return nil, fmt.Errorf("-max-attempts must be at least 1")
+	}
+	if network && flags.workers < 1 {
+		return nil, fmt.Errorf("-workers must be at least 1")
+	}
+	if err := flags.resolve(fs); err != nil {
+		return nil, err
+	}
+	return flags.groupList()
+}
+
+func runSearch(ctx context.Context, args []string) error {
+	var flags commonFlags
+	groups, err := parseCommandFlags("search", args, &flags, true)
+	if err != nil {
+		return err
+	}
+	if err := flags.recordProfile(); err != nil {
+		return err
+	}
+
+	scraper, err := flags.scraper()
+	if err != nil {
+		return err
+	}
+	defer scraper.printSummary(ctx)
+
+	total, err := scraper.scrapeAndSaveAllData(ctx, groups)
+	if err != nil {
+		return fmt.Errorf("error scraping award listings: %w", err)
+	}
+	finish(scraper)
+
+	log.Printf("Successfully collected %d awards", total)
+	return nil
+}
+
+func runEnrich(ctx context.Context, args []string) error {
+	var flags commonFlags
+	groups, err := parseCommandFlags("enrich", args, &flags, true)
+	if err != nil {
+		return err
+	}
+	if err := flags.recordProfile(); err != nil {
+		return err
+	}
+
+	scraper, err := flags.scraper()
+	if err != nil {
+		return err
+	}
+	defer scraper.printSummary(ctx)
+
+	total, err := scraper.enrichSavedData(ctx, groups)
+	if err != nil {
+		return fmt.Errorf("error enriching saved awards: %w", err)
+	}
+	finish(scraper)
+
+	log.Printf("Successfully enriched and saved %d awards", total)
+	return nil
+}
+
+func runScrape(ctx context.Context, args []string) error {
+	var flags commonFlags
+	groups, err := parseCommandFlags("scrape", args, &flags, true)
+	if err != nil {
+		return err
+	}
+	if err := flags.recordProfile(); err != nil {
+		return err
+	}
+
+	started := time.Now()
+	log.Printf("Starting USASpending.gov Enhanced Scraper")
+	log.Printf("This will collect basic award data and detailed information for each award")
+	log.Printf("Awards will be organized by: [Award Type]/[Entity]/[Year]/[Agency]/[Award ID].json")
+
+	scraper, err := flags.scraper()
+	if err != nil {
+		return err
+	}
+	defer scraper.printSummary(ctx)
+
+	totalAwards, err := scraper.scrapeAndSaveEnhancedData(ctx, groups)
+	if err != nil {
+		return fmt.Errorf("error scraping enhanced data: %w", err)
+	}
+	finish(scraper)
+	if err := flags.recordLastRun("scrape", started); err != nil {
+		return err
+	}
+
+	log.Printf("Successfully scraped and saved %d enhanced awards", totalAwards)
+	log.Printf("Data organized in hierarchical directory structure:")
+	for _, groupName := range groups {
+		log.Printf("  %s -> %s/[Entity]/[Year]/[Agency]/", groupName, groupDirectory(flags.outputRoot, groupName))
+	}
+	return nil
+}
+
+func runUpdate(ctx context.Context, args []string) error {
+	var flags commonFlags
+	flags.noCheckpoint = true
+
+	fs := flag.NewFlagSet("update", flag.ContinueOnError)
+	since := fs.String("since", "", "refetch awards modified on or after this date (default: the last successful run)")
+	checkVanished := fs.Bool("vanished", false, "also list every award again to find saved awards the API no longer returns")
+	changelogFile := fs.String("changelog", "", "also write the changelog as JSON to this file")
+	groups, err := parseFlagSet(fs, args, &flags, true)
+	if err != nil {
+		return err
+	}
+	if *since != "" {
+		if _, err := time.Parse("2006-01-02", *since); err != nil {
+			return fmt.Errorf("invalid -since date %q", *since)
+		}
+	}
+	if err := flags.recordProfile(); err != nil {
+		return err
+	}
+
+	// Without -since, start from the last successful run of this profile
+	if *since == "" {
+		lastRun, err := readLastRun(flags.outputRoot)
+		if err != nil {
+			return err
+		}
+		if lastRun != nil && lastRun.ProfileHash == flags.profile.Hash() {
+			*since = lastRun.Date
+		}
+	}
+
+	scraper, err := flags.scraper()
+	if err != nil {
+		return err
+	}
+	defer scraper.printSummary(ctx)
+
+	started := time.Now()
+	var changes []*GroupChanges
+	for _, groupName := range groups {
+		groupChanges, err := scraper.updateGroup(ctx, groupName, *since, *checkVanished)
+		if err != nil {
+			return fmt.Errorf("error updating %s: %w", groupName, err)
+		}
+		changes = append(changes, groupChanges)
+	}
+
+	printChangelog(os.Stdout, changes)
+
+	if *changelogFile != "" {
+		if err := writeJSONFile(*changelogFile, changes); err != nil {
+			return fmt.Errorf("error writing changelog: %w", err)
+		}
+	}
+
+	return flags.recordLastRun("update", started)
+}
+
+// recordLastRun saves the start of a successful run so the next update only
+// asks for awards modified since then.
+func (c *commonFlags) recordLastRun(command string, started time.Time) error {
+	if c.dryRun {
+		return nil
+	}
+	run := LastRun{
+		Date:        started.Format("2006-01-02"),
+		Command:     command,
+		ProfileHash: c.profile.Hash(),
+	}
+	if err := writeLastRun(c.outputRoot, run); err != nil {
+		return fmt.Errorf("error recording last run: %w", err)
+	}
+	return nil
+}
+
+func runStats(ctx context.Context, args []string) error {
+	var flags commonFlags
+	groups, err := parseCommandFlags("stats", args, &flags, false)
+	if err != nil {
+		return err
+	}
+
+	return printStats(flags.outputRoot, groups)
+}
+
+func runVerify(ctx context.Context, args []string) error {
+	var flags commonFlags
+	groups, err := parseCommandFlags("verify", args, &flags, false)
+	if err != nil {
+		return err
+	}
+
+	return verifyTree(os.Stdout, flags.outputRoot, groups)
+}
+
+func runSchemaDrift(ctx context.Context, args []string) error {
+	var flags commonFlags
+	groups, err := parseCommandFlags("schema-drift", args, &flags, false)
+	if err != nil {
+		return err
+	}
+
+	return printSchemaDrift(os.Stdout, flags.outputRoot, groups, flags.profile)
+}
+
+func runRecipients(ctx context.Context, args []string) error {
+	var flags commonFlags
+	fs := flag.NewFlagSet("recipients", flag.ContinueOnError)
+	record := fs.Bool("quarantine", false, "add saved awards that are not UC to "+quarantineFile)
+	groups, err := parseFlagSet(fs, args, &flags, false)
+	if err != nil {
+		return err
+	}
+
+	return printRecipients(os.Stdout, flags.outputRoot, groups, flags.resolver, *record)
+}
+
+func runRelayout(ctx context.Context, args []string) error {
+	var flags commonFlags
+	fs := flag.NewFlagSet("relayout", flag.ContinueOnError)
+	template := fs.String("layout", defaultLayoutTemplate, "path template for award files; placeholders: "+layoutFieldList())
+	years := fs.String("years", "", "year bucketing of {year} and {fiscal_year}: federal, uc or calendar (default: the one in layout.json, which scrape and update use)")
+	dryRun := fs.Bool("dry-run", false, "list the moves without changing anything")
+	groups, err := parseFlagSet(fs, args, &flags, false)
+	if err != nil {
+		return err
+	}
+
+	layout, err := targetLayout(flags.outputRoot, *template, *years)
+	if err != nil {
+		return err
+	}
+	return relayoutTree(ctx, os.Stdout, flags.outputRoot, groups, layout, flags.resolver, *dryRun)
+}
+
+func runSubawards(ctx context.Context, args []string) error {
+	var flags commonFlags
+	flags.noCheckpoint = true
+
+	fs := flag.NewFlagSet("subawards", flag.ContinueOnError)
+	inbound := fs.Bool("inbound", true, "also search the API for subawards other primes made to UC")
+	groups, err := parseFlagSet(fs, args, &flags, true)
+	if err != nil {
+		return err
+	}
+
+	var scraper *Scraper
+	if *inbound {
+		if scraper, err = flags.scraper(); err != nil {
+			return err
+		}
+	}
+	return printSubawardFlows(ctx, os.Stdout, scraper, flags.outputRoot, groups, flags.resolver)
+}
+
+func runFunding(ctx context.Context, args []string) error {
+	var flags commonFlags
+	fs := flag.NewFlagSet("funding", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage of funding:\n\n"+
+			"Rolls up saved awards/funding rows by campus and federal account (e.g. 075-0846).\n"+
+			"The funding endpoint does not return the treasury account symbol (TAS), so the\n"+
+			"rollup cannot be broken down by TAS; one federal account may cover several.\n\n")
+		fs.PrintDefaults()
+	}
+	groups, err := parseFlagSet(fs, args, &flags, false)
+	if err != nil {
+		return err
+	}
+	return printFundingRollup(os.Stdout, flags.outputRoot, groups, flags.resolver)
+}
+
+func runGraph(ctx context.Context, args []string) error {
+	var flags commonFlags
+	fs := flag.NewFlagSet("graph", flag.ContinueOnError)
+	idv := fs.String("idv", "", "list the awards under this IDV (PIID or generated award ID) instead of every IDV")
+	groups, err := parseFlagSet(fs, args, &flags, false)
+	if err != nil {
+		return err
+	}
+	return printAwardGraph(os.Stdout, flags.outputRoot, groups, flags.resolver, *idv)
+}
+
+func runHierarchy(ctx context.Context, args []string) error {
+	var flags commonFlags
+	flags.noCheckpoint = true
+
+	fs := flag.NewFlagSet("hierarchy", flag.ContinueOnError)
+	groups, err := parseFlagSet(fs, args, &flags, true)
+	if err != nil {
+		return err
+	}
+	scraper, err := flags.scraper()
+	if err != nil {
+		return err
+	}
+	return runRecipientStage(ctx, os.Stdout, scraper, groups, flags.resolver)
+}
+
+func runBulk(ctx context.Context, args []string) error {
+	var flags commonFlags
+	flags.noCheckpoint = true
+
+	var opts bulkOptions
+	fs := flag.NewFlagSet("bulk", flag.ContinueOnError)
+	fs.StringVar(&opts.start, "start", defaultBulkStart, "first action date to download")
+	fs.StringVar(&opts.end, "end", defaultBulkEnd, "last action date to download")
+	fs.DurationVar(&opts.poll, "poll", 30*time.Second, "time between download status checks")
+	fs.StringVar(&opts.file, "file", "", "read this previously downloaded archive instead of requesting one")
+	groups, err := parseFlagSet(fs, args, &flags, true)
+	if err != nil {
+		return err
+	}
+	for _, date := range []string{opts.start, opts.end} {
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return fmt.Errorf("invalid bulk date %q", date)
+		}
+	}
+
+	scraper, err := flags.scraper()
+	if err != nil {
+		return err
+	}
+	defer scraper.printSummary(ctx)
+	return scraper.runBulkDownload(ctx, groups, opts)
+}
+
+// exportFormats are the formats of the export command.
+var exportFormats = map[string]func(ctx context.Context, args []string) error{
+	"sqlite":  runExportSQLite,
+	"parquet": runExportParquet,
+}
+
+func runExport(ctx context.Context, args []string) error {
+	if len(args) == 0 || exportFormats[args[0]] == nil {
+		return fmt.Errorf("usage: export sqlite|parquet [flags]")
+	}
+	return exportFormats[args[0]](ctx, args[1:])
+}
+
+func runExportSQLite(ctx context.Context, args []string) error {
+	var flags commonFlags
+	fs := flag.NewFlagSet("export sqlite", flag.ContinueOnError)
+	db := fs.String("db", "", "database file (default <out>/"+defaultSQLiteFile+")")
+	sqlite3 := fs.String("sqlite3", "sqlite3", "sqlite3 shell to load the database with")
+	groups, err := parseFlagSet(fs, args, &flags, false)
+	if err != nil {
+		return err
+	}
+	if *db == "" {
+		*db = filepath.Join(flags.outputRoot, defaultSQLiteFile)
+	}
+
+	return exportSQLite(ctx, flags.outputRoot, groups, flags.resolver, *db, *sqlite3)
+}
+
+func runExportParquet(ctx context.Context, args []string) error {
+	var flags commonFlags
+	fs := flag.NewFlagSet("export parquet", flag.ContinueOnError)
+	dir := fs.String("dir", "", "directory for the <group>.parquet files (default <out>/"+defaultParquetDir+")")
+	groups, err := parseFlagSet(fs, args, &flags, false)
+	if err != nil {
+		return err
+	}
+	if *dir == "" {
+		*dir = filepath.Join(flags.outputRoot, defaultParquetDir)
+	}
+
+	return exportParquet(flags.outputRoot, groups, flags.resolver, *dir)
+}
+
+// printDryRunRequest writes the request body that would have been sent.
+func (s *Scraper) printDryRunRequest(url string, body interface{}) error {
+	jsonData, err := json.MarshalIndent(body, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling request: %w", err)
+	}
+	fmt.Fprintf(s.out, "POST %s\n%s\n", url, jsonData)
+	return nil
+}
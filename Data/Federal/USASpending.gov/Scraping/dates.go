@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// YearBucketing decides which year directory an award date falls in.
+type YearBucketing string
+
+const (
+	// Federal fiscal year, October to September, named by the year it ends
+	yearsFederal YearBucketing = "federal"
+	// UC fiscal year, July to June, named by the year it ends
+	yearsUC YearBucketing = "uc"
+	// Calendar year, as trees saved before bucketing used
+	yearsCalendar YearBucketing = "calendar"
+)
+
+// Award dates outside these years are placeholders such as 0001-01-01 or
+// typos, not real dates
+const (
+	minAwardYear = 1900
+	maxAwardYear = 2100
+)
+
+// ParseYearBucketing accepts "federal", "uc" or "calendar". An empty string
+// is the calendar year, for layouts recorded before bucketing was selectable.
+func ParseYearBucketing(s string) (YearBucketing, error) {
+	switch b := YearBucketing(strings.ToLower(strings.TrimSpace(s))); b {
+	case yearsFederal, yearsUC, yearsCalendar:
+		return b, nil
+	case "":
+		return yearsCalendar, nil
+	}
+	return "", fmt.Errorf("unknown year bucketing %q (valid: federal, uc, calendar)", s)
+}
+
+// Year returns the year d is bucketed in. Fiscal years are named by the
+// calendar year they end in, so 2023-11-01 is federal FY2024 and UC FY2024.
+func (b YearBucketing) Year(d Date) int {
+	switch b {
+	case yearsFederal:
+		if d.Month >= time.October {
+			return d.Year + 1
+		}
+	case yearsUC:
+		if d.Month >= time.July {
+			return d.Year + 1
+		}
+	}
+	return d.Year
+}
+
+// Label names a bucket year for the {fiscal_year} placeholder.
+func (b YearBucketing) Label(year int) string {
+	switch b {
+	case yearsFederal:
+		return fmt.Sprintf("FY%d", year)
+	case yearsUC:
+		return fmt.Sprintf("UCFY%d", year)
+	}
+	return fmt.Sprintf("CY%d", year)
+}
+
+// awardDate returns the date an award is bucketed by: its start date, or
+// for loans, whose search rows have no start date, the issued date. Without
+// either it falls back to the signing date from the detail.
+func awardDate(enhancedAward *EnhancedAward) (Date, error) {
+	date := enhancedAward.BasicData.StartDate
+	source := "start date"
+	if date.IsZero() && !enhancedAward.BasicData.IssuedDate.IsZero() {
+		date, source = enhancedAward.BasicData.IssuedDate, "issued date"
+	}
+	if date.IsZero() {
+		if enhancedAward.DetailedData == nil {
+			return Date{}, fmt.Errorf("no start date and no detail to take the signing date from")
+		}
+		signed := enhancedAward.DetailedData.Common().DateSigned
+		if signed == "" {
+			return Date{}, fmt.Errorf("no start date or signing date")
+		}
+		parsed, err := ParseDate(signed)
+		if err != nil {
+			return Date{}, fmt.Errorf("no start date, and the signing date is unusable: %w", err)
+		}
+		date, source = parsed, "signing date"
+	}
+	if date.Year < minAwardYear || date.Year > maxAwardYear {
+		return Date{}, fmt.Errorf("%s %s is outside %d-%d", source, date, minAwardYear, maxAwardYear)
+	}
+	return date, nil
+}
+
+// File at the output root listing awards left out of the tree because they
+// have no usable date
+const undatedFile = "undated.json"
+
+// UndatedAward is an award whose year directory could not be worked out.
+type UndatedAward struct {
+	Group               string `json:"group"`
+	GeneratedInternalID string `json:"generated_internal_id"`
+	AwardID             string `json:"award_id"`
+	RecipientName       string `json:"recipient_name"`
+	StartDate           string `json:"start_date"`
+	DateSigned          string `json:"date_signed"`
+	Reason              string `json:"reason"`
+	Path                string `json:"path,omitempty"` // Saved file, for awards found in the tree
+}
+
+// UndatedReport is the layout of undated.json.
+type UndatedReport = AwardReport[UndatedAward]
+
+func (a UndatedAward) reportKey() (string, string) {
+	return a.Group, a.GeneratedInternalID
+}
+
+func newUndatedAward(groupName string, award *EnhancedAward, reason error) UndatedAward {
+	entry := UndatedAward{
+		Group:               groupName,
+		GeneratedInternalID: award.BasicData.GeneratedInternalID,
+		AwardID:             award.BasicData.AwardID,
+		RecipientName:       award.BasicData.RecipientName,
+		StartDate:           award.BasicData.StartDate.String(),
+		Reason:              reason.Error(),
+	}
+	if award.DetailedData != nil {
+		entry.DateSigned = award.DetailedData.Common().DateSigned
+	}
+	return entry
+}
+
+func readUndatedReport(path string) (*UndatedReport, error) {
+	return readAwardReport[UndatedAward](path)
+}
+
+// undatedAwards keeps undated.json up to date as awards are left out.
+type undatedAwards = awardReportWriter[UndatedAward]
+
+func newUndatedAwards(outputRoot string) *undatedAwards {
+	return &undatedAwards{path: filepath.Join(outputRoot, undatedFile)}
+}
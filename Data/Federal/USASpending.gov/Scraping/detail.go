@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// AwardDetail is an awards/{id} response decoded for its award category.
+// Every variant embeds DetailedAwardResponse, which holds the fields all
+// categories share, and reaches it through Common.
+type AwardDetail interface {
+	Common() *DetailedAwardResponse
+}
+
+func (d *DetailedAwardResponse) Common() *DetailedAwardResponse {
+	return d
+}
+
+// ContractAwardDetail is the detail of a definitive contract, purchase order,
+// delivery order or BPA call. The contract-specific fields predate the
+// variants and live on DetailedAwardResponse.
+type ContractAwardDetail struct {
+	DetailedAwardResponse
+}
+
+// IDVAwardDetail is the detail of an indefinite delivery vehicle. IDVs carry
+// the same contract fields as contracts; their orders are separate awards.
+type IDVAwardDetail struct {
+	DetailedAwardResponse
+}
+
+// AssistanceAwardDetail is the detail of a grant, loan, direct payment or
+// other financial assistance award. The contract fields of the embedded
+// response stay empty.
+type AssistanceAwardDetail struct {
+	DetailedAwardResponse
+
+	FAIN               string              `json:"fain,omitempty"`
+	URI                *string             `json:"uri,omitempty"`
+	RecordType         int                 `json:"record_type,omitempty"` // 1 aggregate, 2 non-aggregate, 3 aggregate by individual
+	CFDAInfo           []CFDAInfo          `json:"cfda_info,omitempty"`
+	FundingOpportunity *FundingOpportunity `json:"funding_opportunity,omitempty"`
+	NonFederalFunding  *Money              `json:"non_federal_funding,omitempty"`
+	TotalFunding       *Money              `json:"total_funding,omitempty"`
+	TotalSubsidyCost   *Money              `json:"total_subsidy_cost,omitempty"` // Loans only
+	TotalLoanValue     *Money              `json:"total_loan_value,omitempty"`   // Loans only
+}
+
+// CFDAInfo is one Assistance Listing (formerly CFDA program) that funds an
+// assistance award.
+type CFDAInfo struct {
+	CFDANumber                    string  `json:"cfda_number"`
+	CFDATitle                     string  `json:"cfda_title"`
+	CFDAPopularName               *string `json:"cfda_popular_name"`
+	CFDAObjectives                *string `json:"cfda_objectives"`
+	CFDAFederalAgency             *string `json:"cfda_federal_agency"`
+	CFDAWebsite                   *string `json:"cfda_website"`
+	SAMWebsite                    *string `json:"sam_website"`
+	ApplicantEligibility          *string `json:"applicant_eligibility"`
+	BeneficiaryEligibility        *string `json:"beneficiary_eligibility"`
+	FederalActionObligationAmount Money   `json:"federal_action_obligation_amount"`
+	NonFederalFundingAmount       Money   `json:"non_federal_funding_amount"`
+	TotalFundingAmount            Money   `json:"total_funding_amount"`
+}
+
+// FundingOpportunity is the notice of funding opportunity an assistance
+// award was made under.
+type FundingOpportunity struct {
+	Number *string `json:"number"`
+	Goals  *string `json:"goals"`
+}
+
+// AssistanceListings returns the Assistance Listing numbers of the award.
+func (d *AssistanceAwardDetail) AssistanceListings() []string {
+	var numbers []string
+	for _, info := range d.CFDAInfo {
+		if info.CFDANumber != "" {
+			numbers = append(numbers, info.CFDANumber)
+		}
+	}
+	return numbers
+}
+
+// decodeAwardDetail picks the variant from the category of an awards/{id}
+// response. Unknown categories fall back to the shared fields only.
+func decodeAwardDetail(data []byte) (AwardDetail, error) {
+	var header struct {
+		Category string `json:"category"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, fmt.Errorf("error decoding detail response: %w", err)
+	}
+
+	var detail AwardDetail
+	switch header.Category {
+	case "contract":
+		detail = &ContractAwardDetail{}
+	case "idv":
+		detail = &IDVAwardDetail{}
+	case "grant", "loans", "direct payment", "other", "insurance":
+		detail = &AssistanceAwardDetail{}
+	default:
+		detail = &DetailedAwardResponse{}
+	}
+
+	if err := json.Unmarshal(data, detail); err != nil {
+		if err := tolerateTypeError(err, header.Category+" detail "+detail.Common().GeneratedUniqueAwardID, &detail.Common().mismatch); err != nil {
+			return nil, fmt.Errorf("error decoding %s detail response: %w", header.Category, err)
+		}
+	}
+	detail.Common().raw = captureRaw(data)
+	return detail, nil
+}
+
+// UnmarshalJSON decodes detailed_data into the variant for its category.
+// Saved files are written from the structs, so unlike an API response a
+// value whose JSON type does not match its field is an error.
+func (e *EnhancedAward) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		BasicData    Award           `json:"basic_data"`
+		DetailedData json.RawMessage `json:"detailed_data"`
+		Raw          *RawPayload     `json:"raw"`
+		Transactions []Transaction   `json:"transactions"`
+		Subawards    []Subaward      `json:"subawards"`
+		Funding      []Funding       `json:"funding"`
+		IDVChildren  *IDVChildren    `json:"idv_children"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	e.BasicData = raw.BasicData
+	e.Raw = raw.Raw
+	e.Transactions = raw.Transactions
+	e.Subawards = raw.Subawards
+	e.Funding = raw.Funding
+	e.IDVChildren = raw.IDVChildren
+	e.DetailedData = nil
+	if len(raw.DetailedData) > 0 && string(raw.DetailedData) != "null" {
+		detail, err := decodeAwardDetail(raw.DetailedData)
+		if err != nil {
+			return err
+		}
+		e.DetailedData = detail
+	}
+	return e.typeMismatch()
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const grantDetail = `{
+  "id": 54321,
+  "generated_unique_award_id": "ASST_NON_R01NS013560_075",
+  "fain": "R01NS013560",
+  "uri": null,
+  "category": "grant",
+  "type": "04",
+  "type_description": "PROJECT GRANT (B)",
+  "description": "NEURAL CONTROL OF LOCOMOTION",
+  "total_obligation": 2517302.0,
+  "record_type": 2,
+  "non_federal_funding": 0,
+  "total_funding": 2517302.25,
+  "cfda_info": [
+    {
+      "cfda_number": "93.853",
+      "cfda_title": "Extramural Research Programs in the Neurosciences and Neurological Disorders",
+      "cfda_popular_name": null,
+      "federal_action_obligation_amount": 2517302.25,
+      "non_federal_funding_amount": 0,
+      "total_funding_amount": 2517302.25
+    }
+  ],
+  "funding_opportunity": {"number": "PA-20-185", "goals": null},
+  "period_of_performance": {"start_date": "1978-07-01", "end_date": "2026-06-30", "last_modified_date": "2025-08-09"}
+}`
+
+func TestDecodeAwardDetailVariants(t *testing.T) {
+	tests := []struct {
+		body string
+		want string
+	}{
+		{grantDetail, "*main.AssistanceAwardDetail"},
+		{`{"category":"loans","total_subsidy_cost":1234.5,"total_loan_value":150000}`, "*main.AssistanceAwardDetail"},
+		{`{"category":"contract","piid":"DEAC0205CH11231"}`, "*main.ContractAwardDetail"},
+		{`{"category":"idv","piid":"N0014006G0051"}`, "*main.IDVAwardDetail"},
+		{`{"category":"something new"}`, "*main.DetailedAwardResponse"},
+	}
+	for _, tt := range tests {
+		detail, err := decodeAwardDetail([]byte(tt.body))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.body, err)
+		}
+		if got := fmt.Sprintf("%T", detail); got != tt.want {
+			t.Errorf("%.40s decoded as %s, want %s", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestAssistanceDetailKeepsAssistanceFields(t *testing.T) {
+	detail, err := decodeAwardDetail([]byte(grantDetail))
+	if err != nil {
+		t.Fatal(err)
+	}
+	grant := detail.(*AssistanceAwardDetail)
+
+	if grant.FAIN != "R01NS013560" || grant.RecordType != 2 {
+		t.Errorf("fain/record_type = %q/%d", grant.FAIN, grant.RecordType)
+	}
+	if listings := grant.AssistanceListings(); len(listings) != 1 || listings[0] != "93.853" {
+		t.Errorf("assistance listings = %v", listings)
+	}
+	if grant.TotalFunding == nil || grant.TotalFunding.String() != "2517302.25" {
+		t.Errorf("total_funding = %v", grant.TotalFunding)
+	}
+	if grant.FundingOpportunity == nil || *grant.FundingOpportunity.Number != "PA-20-185" {
+		t.Errorf("funding_opportunity = %+v", grant.FundingOpportunity)
+	}
+	if grant.Common().PeriodOfPerformance.LastModifiedDate != "2025-08-09" {
+		t.Errorf("shared fields not decoded: %+v", grant.Common().PeriodOfPerformance)
+	}
+
+	// A saved EnhancedAward loads back into the same variant
+	data, err := json.Marshal(EnhancedAward{BasicData: Award{GeneratedInternalID: "ASST_NON_R01NS013560_075"}, DetailedData: grant})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loaded EnhancedAward
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, ok := loaded.DetailedData.(*AssistanceAwardDetail)
+	if !ok || reloaded.CFDAInfo[0].TotalFundingAmount.String() != "2517302.25" {
+		t.Errorf("reloaded detail = %#v", loaded.DetailedData)
+	}
+
+	var basicOnly EnhancedAward
+	if err := json.Unmarshal([]byte(`{"basic_data":{"generated_internal_id":"X"}}`), &basicOnly); err != nil || basicOnly.DetailedData != nil {
+		t.Errorf("basic-only award = %#v, %v", basicOnly.DetailedData, err)
+	}
+}
+
+func TestTypeMismatchToleratedOnlyInAPIResponses(t *testing.T) {
+	body := `{"category":"grant","generated_unique_award_id":"ASST_NON_R01NS013560_075","description":["not","text"],"fain":"R01NS013560"}`
+	detail, err := decodeAwardDetail([]byte(body))
+	if err != nil {
+		t.Fatalf("API detail with a wrong-typed field was rejected: %v", err)
+	}
+	if assistance := detail.(*AssistanceAwardDetail); assistance.FAIN != "R01NS013560" {
+		t.Errorf("FAIN = %q; the other fields should still decode", assistance.FAIN)
+	}
+
+	saved := `{"basic_data":{"generated_internal_id":"ASST_NON_R01NS013560_075"},"detailed_data":` + body + `}`
+	var award EnhancedAward
+	if err := json.Unmarshal([]byte(saved), &award); err == nil || !strings.Contains(err.Error(), "description") {
+		t.Errorf("saved award with a wrong-typed field decoded with error %v, want a type error", err)
+	}
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// JSON kinds as reported by schema-drift
+const (
+	kindString = "string"
+	kindNumber = "number"
+	kindBool   = "bool"
+	kindObject = "object"
+	kindArray  = "array"
+	kindNull   = "null"
+	kindAny    = "any"
+)
+
+// kindSet is the set of JSON kinds a declared field accepts.
+type kindSet map[string]bool
+
+func (k kindSet) accepts(kind string) bool {
+	return k[kindAny] || k[kind] || kind == kindNull // encoding/json accepts null for any field
+}
+
+func (k kindSet) String() string {
+	var kinds []string
+	for kind := range k {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return strings.Join(kinds, "|")
+}
+
+var (
+	moneyType         = reflect.TypeOf(Money{})
+	dateType          = reflect.TypeOf(Date{})
+	awardLocationType = reflect.TypeOf(AwardLocation{})
+	codeType          = reflect.TypeOf(CodeDescription{})
+	rawMessageType    = reflect.TypeOf(json.RawMessage{})
+)
+
+// declaredSchema maps every JSON key path a struct declares to the kinds it
+// accepts. Nested objects use "parent.child" and array elements "parent[]".
+func declaredSchema(t reflect.Type) map[string]kindSet {
+	schema := make(map[string]kindSet)
+	addStructFields(t, "", schema)
+	return schema
+}
+
+func addStructFields(t reflect.Type, prefix string, schema map[string]kindSet) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			addStructFields(field.Type, prefix, schema)
+			continue
+		}
+		if field.PkgPath != "" {
+			continue // Unexported
+		}
+
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		addType(field.Type, prefix+name, schema)
+	}
+}
+
+func addType(t reflect.Type, path string, schema map[string]kindSet) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == moneyType:
+		schema[path] = kindSet{kindNumber: true, kindString: true}
+		return
+	case t == dateType:
+		schema[path] = kindSet{kindString: true}
+		return
+	case t == awardLocationType:
+		schema[path] = kindSet{kindObject: true}
+		addStructFields(reflect.TypeOf(Location{}), path+".", schema)
+		return
+	case t == codeType:
+		schema[path] = kindSet{kindObject: true, kindString: true}
+		addStructFields(t, path+".", schema)
+		return
+	case t == rawMessageType:
+		schema[path] = kindSet{kindAny: true}
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		schema[path] = kindSet{kindString: true}
+	case reflect.Bool:
+		schema[path] = kindSet{kindBool: true}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		schema[path] = kindSet{kindNumber: true}
+	case reflect.Struct:
+		schema[path] = kindSet{kindObject: true}
+		addStructFields(t, path+".", schema)
+	case reflect.Slice, reflect.Array:
+		schema[path] = kindSet{kindArray: true}
+		addType(t.Elem(), path+"[]", schema)
+	case reflect.Map:
+		schema[path] = kindSet{kindObject: true}
+	default:
+		schema[path] = kindSet{kindAny: true}
+	}
+}
+
+// observedKey is what the raw payloads of one kind showed for a key path.
+type observedKey struct {
+	count   int
+	kinds   map[string]int
+	example string // A file the key was seen in
+}
+
+// payloadSurvey accumulates the key paths of raw payloads that share one
+// declared schema, e.g. all grant detail responses.
+type payloadSurvey struct {
+	name     string
+	schema   map[string]kindSet
+	payloads int
+	keys     map[string]*observedKey
+
+	// Top-level keys the payloads are expected to carry; nil means every
+	// declared key. Search rows only carry the fields that were requested.
+	expected map[string]bool
+}
+
+func newPayloadSurvey(name string, t reflect.Type) *payloadSurvey {
+	return &payloadSurvey{
+		name:   name,
+		schema: declaredSchema(t),
+		keys:   make(map[string]*observedKey),
+	}
+}
+
+func (s *payloadSurvey) add(path string, raw json.RawMessage) error {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return fmt.Errorf("error decoding raw payload in %s: %w", path, err)
+	}
+
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("raw payload in %s is not an object", path)
+	}
+	s.payloads++
+	s.observeObject(object, "", path)
+	return nil
+}
+
+func (s *payloadSurvey) observeObject(object map[string]interface{}, prefix, file string) {
+	for key, value := range object {
+		s.observe(value, prefix+key, file)
+	}
+}
+
+func (s *payloadSurvey) observe(value interface{}, path, file string) {
+	key := s.keys[path]
+	if key == nil {
+		key = &observedKey{kinds: make(map[string]int), example: file}
+		s.keys[path] = key
+	}
+	key.count++
+
+	switch v := value.(type) {
+	case nil:
+		key.kinds[kindNull]++
+	case string:
+		key.kinds[kindString]++
+	case json.Number:
+		key.kinds[kindNumber]++
+	case bool:
+		key.kinds[kindBool]++
+	case []interface{}:
+		key.kinds[kindArray]++
+		for _, elem := range v {
+			s.observe(elem, path+"[]", file)
+		}
+	case map[string]interface{}:
+		key.kinds[kindObject]++
+		if allowed := s.schema[path]; allowed == nil || !allowed[kindAny] {
+			s.observeObject(v, path+".", file)
+		}
+	}
+}
+
+// schemaDrift is the difference between a survey and its declared schema.
+type schemaDrift struct {
+	added   []string // Keys the API sent that no struct declares
+	missing []string // Declared keys absent from every payload
+	changed []string // Keys seen with a kind their field does not accept
+}
+
+func (s *payloadSurvey) drift() schemaDrift {
+	var d schemaDrift
+	for path, key := range s.keys {
+		allowed, declared := s.schema[path]
+		if !declared {
+			if parent := parentPath(path); parent != "" && s.schema[parent] == nil {
+				continue // Reported with its parent
+			}
+			d.added = append(d.added, fmt.Sprintf("%s (%s) in %d of %d payloads, e.g. %s",
+				path, kindList(key.kinds), key.count, s.payloads, key.example))
+			continue
+		}
+
+		for kind, count := range key.kinds {
+			if !allowed.accepts(kind) {
+				d.changed = append(d.changed, fmt.Sprintf("%s: declared %s, seen %s %d times, e.g. %s",
+					path, allowed, kind, count, key.example))
+			}
+		}
+	}
+
+	for path := range s.schema {
+		if _, seen := s.keys[path]; seen || strings.HasSuffix(path, "[]") {
+			continue
+		}
+		parent := parentPath(path)
+		if parent == "" {
+			if s.expected == nil || s.expected[path] {
+				d.missing = append(d.missing, path)
+			}
+			continue
+		}
+		// Nested keys count only where their enclosing object was seen
+		if enclosing := s.keys[parent]; enclosing != nil && enclosing.kinds[kindObject] > 0 {
+			d.missing = append(d.missing, path)
+		}
+	}
+
+	sort.Strings(d.added)
+	sort.Strings(d.missing)
+	sort.Strings(d.changed)
+	return d
+}
+
+// parentPath returns the enclosing object or array path of a key path, or
+// "" for a top-level key.
+func parentPath(path string) string {
+	if strings.HasSuffix(path, "[]") {
+		return strings.TrimSuffix(path, "[]")
+	}
+	if i := strings.LastIndex(path, "."); i >= 0 {
+		return path[:i]
+	}
+	return ""
+}
+
+func kindList(kinds map[string]int) string {
+	var parts []string
+	for kind := range kinds {
+		parts = append(parts, kind)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, "|")
+}
+
+// surveyRawPayloads walks the saved awards of the given groups and groups
+// their raw payloads by the struct that decodes them. It returns the surveys
+// in a stable order and the number of awards without raw payloads.
+func surveyRawPayloads(outputRoot string, groups []string, profile *SearchProfile) ([]*payloadSurvey, int, error) {
+	surveys := make(map[string]*payloadSurvey)
+	var order []string
+	survey := func(name string, t reflect.Type) *payloadSurvey {
+		if surveys[name] == nil {
+			surveys[name] = newPayloadSurvey(name, t)
+			order = append(order, name)
+		}
+		return surveys[name]
+	}
+
+	withoutRaw := 0
+	for _, groupName := range groups {
+		err := walkEnhancedAwards(outputRoot, groupName, func(path string, award *EnhancedAward) error {
+			if award.Raw == nil {
+				withoutRaw++
+				return nil
+			}
+			if award.Raw.SearchRow != nil {
+				rows := survey("search row "+groupName, reflect.TypeOf(Award{}))
+				if rows.expected == nil {
+					rows.expected = make(map[string]bool)
+					for _, field := range profile.groupConfig(groupName).Fields {
+						rows.expected[field] = true
+					}
+				}
+				if err := rows.add(path, award.Raw.SearchRow); err != nil {
+					return err
+				}
+			}
+			if award.Raw.Detail != nil {
+				detail, err := decodeAwardDetail(award.Raw.Detail)
+				if err != nil {
+					return fmt.Errorf("error decoding raw detail in %s: %w", path, err)
+				}
+				name := "detail " + detail.Common().Category
+				if err := survey(name, reflect.TypeOf(detail).Elem()).add(path, award.Raw.Detail); err != nil {
+					return err
+				}
+			}
+			for _, row := range award.Raw.Transactions {
+				if err := survey("transaction", reflect.TypeOf(Transaction{})).add(path, row); err != nil {
+					return err
+				}
+			}
+			for _, row := range award.Raw.Subawards {
+				if err := survey("subaward", reflect.TypeOf(Subaward{})).add(path, row); err != nil {
+					return err
+				}
+			}
+			for _, row := range award.Raw.Funding {
+				if err := survey("funding", reflect.TypeOf(Funding{})).add(path, row); err != nil {
+					return err
+				}
+			}
+			for _, row := range award.Raw.IDVChildren {
+				if err := survey("idv child", reflect.TypeOf(IDVChild{})).add(path, row); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	sort.Strings(order)
+	list := make([]*payloadSurvey, len(order))
+	for i, name := range order {
+		list[i] = surveys[name]
+	}
+	return list, withoutRaw, nil
+}
+
+// printSchemaDrift compares the saved raw payloads with the struct
+// definitions and lists new (+), missing (-) and type-changed (~) keys.
+func printSchemaDrift(w io.Writer, outputRoot string, groups []string, profile *SearchProfile) error {
+	surveys, withoutRaw, err := surveyRawPayloads(outputRoot, groups, profile)
+	if err != nil {
+		return err
+	}
+	if withoutRaw > 0 {
+		log.Printf("%d saved awards have no raw payload; they were saved before raw capture and are skipped", withoutRaw)
+	}
+	if len(surveys) == 0 {
+		log.Printf("No raw payloads found; scrape or update to capture them")
+		return nil
+	}
+
+	for _, s := range surveys {
+		d := s.drift()
+		fmt.Fprintf(w, "[%s] %d payloads: %d new, %d missing, %d type-changed keys\n",
+			s.name, s.payloads, len(d.added), len(d.missing), len(d.changed))
+		for _, line := range d.added {
+			fmt.Fprintf(w, "  + %s\n", line)
+		}
+		for _, line := range d.missing {
+			fmt.Fprintf(w, "  - %s\n", line)
+		}
+		for _, line := range d.changed {
+			fmt.Fprintf(w, "  ~ %s\n", line)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSchemaDriftReportsRawPayloadKeys(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"category":"grant","generated_unique_award_id":"ASST_NON_R01_075","fain":"R01",`+
+			`"brand_new_field":{"nested":1},"total_obligation":"2500.00",`+
+			`"cfda_info":[{"cfda_number":"93.853","cfda_title":"Neuroscience","cfda_new":true}],`+
+			`"period_of_performance":{"start_date":"2020-01-01"}}`)
+	}))
+	defer server.Close()
+
+	s := newTestScraper(server.URL, 1)
+	s.outputRoot = t.TempDir()
+
+	var rows []Award
+	if err := json.Unmarshal([]byte(`[{"generated_internal_id":"ASST_NON_R01_075","Recipient Name":"UNIVERSITY OF CALIFORNIA, DAVIS",`+
+		`"Award Amount":2500,"Start Date":"2020-01-01","New Search Column":"x"}]`), &rows); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.enrichGroupAwards(context.Background(), "grants", rows); err != nil {
+		t.Fatal(err)
+	}
+
+	var saved *EnhancedAward
+	walkEnhancedAwards(s.outputRoot, "grants", func(path string, award *EnhancedAward) error {
+		saved = award
+		return nil
+	})
+	if saved == nil || saved.Raw == nil {
+		t.Fatalf("saved award has no raw payload: %+v", saved)
+	}
+	if !strings.Contains(string(saved.Raw.SearchRow), `"New Search Column"`) || !strings.Contains(string(saved.Raw.Detail), `"brand_new_field"`) {
+		t.Errorf("raw payload lost undeclared keys: %s / %s", saved.Raw.SearchRow, saved.Raw.Detail)
+	}
+
+	var out bytes.Buffer
+	if err := printSchemaDrift(&out, s.outputRoot, []string{"grants"}, defaultSearchProfile()); err != nil {
+		t.Fatal(err)
+	}
+	report := out.String()
+
+	for _, want := range []string{
+		"[search row grants] 1 payloads",
+		"+ New Search Column (string)",
+		"- Recipient UEI",
+		"[detail grant] 1 payloads",
+		"+ brand_new_field (object)",
+		"+ cfda_info[].cfda_new (bool)",
+		"~ total_obligation: declared number, seen string",
+		"- period_of_performance.end_date",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report lacks %q:\n%s", want, report)
+		}
+	}
+	for _, unwanted := range []string{"brand_new_field.nested", "- Loan Value", "- cfda_info[]\n"} {
+		if strings.Contains(report, unwanted) {
+			t.Errorf("report should not contain %q:\n%s", unwanted, report)
+		}
+	}
+}
+
+func TestSearchDumpKeepsRawRows(t *testing.T) {
+	var rows []Award
+	if err := json.Unmarshal([]byte(`[{"generated_internal_id":"A","Award Amount":1.10,"extra":[1,2]}]`), &rows); err != nil {
+		t.Fatal(err)
+	}
+	path := t.TempDir() + "/uc_grants_2025-09-23.json"
+	if err := saveToJSON(rows, path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := loadGroupDump(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(loaded[0].RawJSON()); !strings.Contains(got, `"extra"`) || !strings.Contains(got, `1.10`) {
+		t.Errorf("dump row = %s", got)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"usaspending-scraper/fakeusaspending"
+)
+
+// newFakeAPI starts the fake API over the shared fixtures with two rows per
+// search page.
+func newFakeAPI(t *testing.T) *fakeusaspending.Server {
+	t.Helper()
+	return newFakeAPIFrom(t, "fakeusaspending.json")
+}
+
+// newFakeAPIFrom starts the fake API over testdata/name with two rows per
+// page, of search results and award lists alike.
+func newFakeAPIFrom(t *testing.T, name string) *fakeusaspending.Server {
+	t.Helper()
+	fixtures, err := fakeusaspending.Load(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := fakeusaspending.New(fixtures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.PageSize = 2
+	t.Cleanup(server.Close)
+	return server
+}
+
+// scrapeFakeAPI scrapes groups from the fake API over testdata/fixture into
+// a temporary output root and fails the test unless want awards are saved.
+// opts selects the optional fetches; OutputRoot and APIBase are filled in.
+func scrapeFakeAPI(t *testing.T, fixture string, opts ScraperOptions, want int, groups ...string) (*fakeusaspending.Server, *Scraper) {
+	t.Helper()
+	server := newFakeAPIFrom(t, fixture)
+	opts.OutputRoot = t.TempDir()
+	opts.APIBase = server.URL
+	s := NewScraper(opts)
+	if saved, err := s.scrapeAndSaveEnhancedData(context.Background(), groups); err != nil || saved != want {
+		t.Fatalf("scrapeAndSaveEnhancedData(%v) = %d, %v; want %d saved", groups, saved, err, want)
+	}
+	return server, s
+}
+
+// savedTree returns the award files under root, relative to it, with their
+// contents.
+func savedTree(t *testing.T, root string) map[string][]byte {
+	t.Helper()
+	files := make(map[string][]byte)
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.HasSuffix(path, ".json") {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		files[filepath.ToSlash(rel)] = data
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func sortedKeys(files map[string][]byte) []string {
+	keys := make([]string, 0, len(files))
+	for key := range files {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestScrapeGroupDataPagesThroughFakeAPI(t *testing.T) {
+	server := newFakeAPI(t)
+	s := newTestScraper(server.URL, 1)
+
+	awards, err := s.scrapeGroupData(context.Background(), "grants", awardTypeGroups["grants"])
+	if err != nil {
+		t.Fatalf("scrapeGroupData: %v", err)
+	}
+	var ids []string
+	for _, award := range awards {
+		ids = append(ids, award.GeneratedInternalID)
+	}
+	want := "ASST_NON_R01NS013560_075 ASST_NON_2112345_4900 ASST_NON_20196701329000_12D2 ASST_NON_R01GM000001_075"
+	if strings.Join(ids, " ") != want {
+		t.Errorf("awards = %v, want %s", ids, want)
+	}
+
+	searches := 0
+	for _, request := range server.Requests() {
+		if request == "POST /api/v2/search/spending_by_award/" {
+			searches++
+		}
+	}
+	if searches != 2 {
+		t.Errorf("%d search pages fetched, want 2: %v", searches, server.Requests())
+	}
+}
+
+func TestScrapeAndSaveEndToEnd(t *testing.T) {
+	_, s := scrapeFakeAPI(t, "fakeusaspending.json", ScraperOptions{}, 4, "contracts", "grants")
+	if s.summary.basicOnly["grants"] != 1 || s.summary.excluded["grants"] != 1 {
+		t.Errorf("summary: %d grants without detail, %d quarantined", s.summary.basicOnly["grants"], s.summary.excluded["grants"])
+	}
+
+	files := savedTree(t, s.outputRoot)
+	want := []string{
+		"Contracts/UC_SAN_DIEGO/2020/Department_of_Defense/CONT_AWD_N0001420C1234_9700_-NONE-_-NONE-.json",
+		"Grants/UC_DAVIS/2019/Department_of_Agriculture/ASST_NON_20196701329000_12D2.json",
+		"Grants/UC_LOS_ANGELES/2021/National_Science_Foundation/ASST_NON_2112345_4900.json",
+		"Grants/UC_SANTA_BARBARA/2007/Department_of_Health_and_Human_Services/ASST_NON_R01NS013560_075.json",
+		quarantineFile,
+	}
+	if got := sortedKeys(files); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("saved files:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	err := walkEnhancedAwards(s.outputRoot, "grants", func(path string, award *EnhancedAward) error {
+		hasDetail := award.DetailedData != nil
+		if wantDetail := award.BasicData.GeneratedInternalID != "ASST_NON_20196701329000_12D2"; hasDetail != wantDetail {
+			t.Errorf("%s: detail saved = %v", award.BasicData.GeneratedInternalID, hasDetail)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCassetteReplaysRecordedRun(t *testing.T) {
+	server := newFakeAPI(t)
+	cassette := t.TempDir()
+
+	record := NewScraper(ScraperOptions{
+		OutputRoot: t.TempDir(),
+		APIBase:    server.URL,
+		Transport:  NewCassette(cassette, CassetteRecord, nil),
+	})
+	if _, err := record.scrapeAndSaveEnhancedData(context.Background(), []string{"grants"}); err != nil {
+		t.Fatalf("recording run: %v", err)
+	}
+	server.Close()
+
+	replay := NewScraper(ScraperOptions{
+		OutputRoot: t.TempDir(),
+		APIBase:    server.URL,
+		Transport:  NewCassette(cassette, CassetteReplay, nil),
+	})
+	if _, err := replay.scrapeAndSaveEnhancedData(context.Background(), []string{"grants"}); err != nil {
+		t.Fatalf("replayed run: %v", err)
+	}
+
+	recorded, replayed := savedTree(t, record.outputRoot), savedTree(t, replay.outputRoot)
+	if len(recorded) != 4 || strings.Join(sortedKeys(recorded), "\n") != strings.Join(sortedKeys(replayed), "\n") {
+		t.Fatalf("recorded %v, replayed %v", sortedKeys(recorded), sortedKeys(replayed))
+	}
+	for name, data := range recorded {
+		if !bytes.Equal(data, replayed[name]) {
+			t.Errorf("%s differs between the recorded and replayed runs", name)
+		}
+	}
+
+	// A request that was never recorded fails instead of reaching the network
+	if _, err := replay.fetchDetailedAward(context.Background(), "ASST_NON_UNRECORDED"); err == nil || !strings.Contains(err.Error(), "has no response") {
+		t.Errorf("unrecorded request: %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEnrichGroupAwardsConcurrentWorkers(t *testing.T) {
+	var inFlight, maxInFlight int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			m := atomic.LoadInt32(&maxInFlight)
+			if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/v2/awards/"), "/")
+		fmt.Fprintf(w, `{"generated_unique_award_id":%q,"date_signed":"2020-01-01"}`, id)
+	}))
+	defer server.Close()
+
+	s := newTestScraper(server.URL, 1)
+	s.outputRoot = t.TempDir()
+	s.workers = 4
+
+	var awards []Award
+	for i := 0; i < 12; i++ {
+		awards = append(awards, Award{
+			GeneratedInternalID: fmt.Sprintf("ASST_NON_%02d_075", i),
+			RecipientName:       "UNIVERSITY OF CALIFORNIA, DAVIS",
+			AwardingAgency:      "Department of Agriculture",
+			StartDate:           Date{2020, time.January, 1},
+		})
+	}
+	awards = append(awards, Award{RecipientName: "NO ID"})
+
+	saved, err := s.enrichGroupAwards(context.Background(), "grants", awards)
+	if err != nil {
+		t.Fatalf("enrichGroupAwards: %v", err)
+	}
+	if saved != 12 {
+		t.Errorf("saved %d awards, want 12", saved)
+	}
+	if maxInFlight < 2 || maxInFlight > 4 {
+		t.Errorf("max concurrent requests = %d, want between 2 and 4", maxInFlight)
+	}
+
+	count := 0
+	if err := walkEnhancedAwards(s.outputRoot, "grants", func(path string, award *EnhancedAward) error {
+		count++
+		if award.DetailedData == nil || award.DetailedData.Common().GeneratedUniqueAwardID != award.BasicData.GeneratedInternalID {
+			t.Errorf("%s: detail does not match basic data", path)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if count != 12 {
+		t.Errorf("found %d files, want 12", count)
+	}
+}
+
+func TestEnrichGroupAwardsStopsOnCancel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	s := newTestScraper(server.URL, 1)
+	s.outputRoot = t.TempDir()
+	s.workers = 2
+
+	awards := make([]Award, 50)
+	for i := range awards {
+		awards[i].GeneratedInternalID = fmt.Sprintf("CONT_AWD_%02d", i)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	saved, err := s.enrichGroupAwards(ctx, "contracts", awards)
+	if err != context.DeadlineExceeded {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+	if saved != 0 {
+		t.Errorf("saved %d awards after cancellation, want 0", saved)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("workers did not stop promptly (took %v)", elapsed)
+	}
+}
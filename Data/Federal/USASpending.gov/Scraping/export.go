@@ -0,0 +1,101 @@
+package main
+
+import (
+	"math"
+	"strings"
+)
+
+// exportedAward is a saved award with the fields that can come from either
+// its search row or its detail settled once, so every export format
+// flattens awards the same way. The detail wins when it has the field.
+type exportedAward struct {
+	group  string
+	award  *EnhancedAward
+	basic  *Award
+	detail *DetailedAwardResponse // nil when saved without detail
+	entity Entity
+
+	recipient                             RecipientDetail
+	awarding, funding                     AgencyInfo
+	recipientLocation, placeOfPerformance Location
+	naics, psc                            HierarchyCode
+	fiscalYear                            int // Federal fiscal year; 0 when the award has no date
+}
+
+func newExportedAward(group string, award *EnhancedAward, resolver *RecipientResolver) *exportedAward {
+	basic := &award.BasicData
+	a := &exportedAward{group: group, award: award, basic: basic, entity: resolver.Resolve(award).Entity}
+	if award.DetailedData != nil {
+		a.detail = award.DetailedData.Common()
+	}
+	detail := a.detail
+
+	// The search row's recipient_id is the recipient hash with a level suffix
+	hash := basic.RecipientID
+	if i := strings.LastIndex(hash, "-"); i >= 0 && len(hash)-i == 2 {
+		hash = hash[:i]
+	}
+	a.recipient = RecipientDetail{RecipientName: basic.RecipientName, RecipientUEI: basic.RecipientUEI, RecipientHash: hash}
+	if detail != nil && detail.Recipient.RecipientName != "" {
+		a.recipient = detail.Recipient
+	}
+
+	a.awarding.ToptierAgency.Name = basic.AwardingAgency
+	a.awarding.SubtierAgency.Name = basic.AwardingSubAgency
+	a.funding.ToptierAgency.Name = basic.FundingAgency
+	if detail != nil && detail.AwardingAgency.ToptierAgency.Name != "" {
+		a.awarding = detail.AwardingAgency
+	}
+	if detail != nil && detail.FundingAgency.ToptierAgency.Name != "" {
+		a.funding = detail.FundingAgency
+	}
+
+	a.recipientLocation, a.placeOfPerformance = basic.RecipientLocation.Location, basic.PrimaryPlaceOfPerformance.Location
+	if detail != nil {
+		if !locationIsEmpty(detail.Recipient.Location) {
+			a.recipientLocation = detail.Recipient.Location
+		}
+		if !locationIsEmpty(detail.PlaceOfPerformance) {
+			a.placeOfPerformance = detail.PlaceOfPerformance
+		}
+	}
+
+	a.naics, a.psc = codeOf(basic.NAICS), codeOf(basic.PSC)
+	if detail != nil {
+		if a.naics.Code == "" {
+			a.naics = detail.NAICSHierarchy.BaseCode
+		}
+		if a.psc.Code == "" {
+			a.psc = detail.PSCHierarchy.BaseCode
+		}
+	}
+
+	if date, err := awardDate(award); err == nil {
+		a.fiscalYear = yearsFederal.Year(date)
+	}
+	return a
+}
+
+// codeOf returns a search row's code and description.
+func codeOf(code *CodeDescription) HierarchyCode {
+	var c HierarchyCode
+	if code != nil && code.Code != nil {
+		c.Code = *code.Code
+	}
+	if code != nil && code.Description != nil {
+		c.Description = *code.Description
+	}
+	return c
+}
+
+// locationIsEmpty reports whether a location has none of the fields the
+// exports keep.
+func locationIsEmpty(l Location) bool {
+	return l.AddressLine1 == "" && l.CityName == "" && l.CountyName == "" && l.StateCode == "" && l.StateName == "" &&
+		l.Zip5 == "" && l.CongressionalCode == "" && l.LocationCountryCode == "" && l.CountryName == ""
+}
+
+// dollarCents converts a float amount from the detail to Money in cents.
+func dollarCents(amount float64) Money {
+	return MoneyFromCents(int64(math.Round(amount * 100)))
+}
@@ -0,0 +1,486 @@
+// Package fakeusaspending serves a small slice of the USAspending API from
+// fixtures, so the scraper can be tested end to end without the network.
+//
+// It answers spending_by_award with paginated rows filtered by award type
+// code and time period, spending_by_award_count with the matching counts,
+// and awards/{id} with each award's detail. The transactions, subawards and
+// awards/funding lists page through each award's rows. At the subawards
+// spending level spending_by_award returns the subaward search rows instead,
+// and the recipient endpoints serve profiles, parent children and the
+// spending_by_category/recipient amounts. Other filters, such as keywords,
+// are accepted and ignored.
+package fakeusaspending
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Award is one fixture: the search row returned for its type code and the
+// detail returned by awards/{id}.
+type Award struct {
+	TypeCode   string          `json:"type_code"`             // Award type code the row matches, e.g. "A" or "04"
+	Row        json.RawMessage `json:"row"`                   // spending_by_award result row
+	Detail     json.RawMessage `json:"detail,omitempty"`      // awards/{id} body; without it the detail is a 404
+	ActionDate string          `json:"action_date,omitempty"` // Matched against time_period filters; without it every period matches
+
+	Transactions []json.RawMessage `json:"transactions,omitempty"` // transactions/ rows
+	Subawards    []json.RawMessage `json:"subawards,omitempty"`    // subawards/ rows
+	Funding      []json.RawMessage `json:"funding,omitempty"`      // awards/funding/ rows
+}
+
+// SearchRow is a spending_by_award row at the subawards spending level.
+type SearchRow struct {
+	TypeCode string          `json:"type_code"` // Award type code of the prime award
+	Row      json.RawMessage `json:"row"`
+}
+
+// RecipientAmount is what spending_by_category/recipient reports for a
+// recipient under one award type code.
+type RecipientAmount struct {
+	RecipientID string          `json:"recipient_id"`
+	TypeCode    string          `json:"type_code"`
+	Amount      json.RawMessage `json:"amount"`
+}
+
+// Fixtures is the content of a fixture file.
+type Fixtures struct {
+	Awards            []Award                      `json:"awards"`
+	SubawardSearch    []SearchRow                  `json:"subaward_search,omitempty"`
+	Recipients        map[string]json.RawMessage   `json:"recipients,omitempty"`         // recipient/{id} profiles
+	RecipientChildren map[string][]json.RawMessage `json:"recipient_children,omitempty"` // recipient/children/{uei} rows by parent UEI
+	RecipientAmounts  []RecipientAmount            `json:"recipient_amounts,omitempty"`
+}
+
+// Load reads a fixture file.
+func Load(path string) (*Fixtures, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fixtures Fixtures
+	if err := json.Unmarshal(data, &fixtures); err != nil {
+		return nil, fmt.Errorf("error decoding %s: %w", path, err)
+	}
+	return &fixtures, nil
+}
+
+// Count endpoint keys for each award type code, as the API groups them
+var countKeys = map[string]string{
+	"A": "contracts", "B": "contracts", "C": "contracts", "D": "contracts",
+	"02": "grants", "03": "grants", "04": "grants", "05": "grants",
+	"07": "loans", "08": "loans",
+	"06": "direct_payments", "10": "direct_payments",
+	"09": "other", "11": "other", "-1": "other",
+}
+
+// Server is a running fake API. Its URL is the base the scraper's API paths
+// are joined to.
+type Server struct {
+	*httptest.Server
+
+	// PageSize caps the rows per search page below the requested limit, so
+	// a handful of fixtures spans several pages. 0 uses the request's limit.
+	PageSize int
+
+	// Keyset makes search pages carry last_record_unique_id and
+	// last_record_sort_value, and a request that sends them back continues
+	// after that record. Rows then need an internal_id.
+	Keyset bool
+
+	// ResultWindow, when set, rejects page-number requests that reach past
+	// this many records with a 422, as the API does at 10,000.
+	ResultWindow int
+
+	awards      []Award
+	internalIDs []int
+	details     map[string]json.RawMessage
+	lists       map[string]map[string][]json.RawMessage // Award list rows by path, then award ID
+	fixtures    *Fixtures
+
+	mu       sync.Mutex
+	requests []string
+	searches []Search
+	listed   []ListRequest
+}
+
+// Search is the part of a spending_by_award request tests look at.
+type Search struct {
+	SpendingLevel       string       `json:"spending_level"`
+	AwardTypeCodes      []string     `json:"award_type_codes"`
+	RecipientSearchText []string     `json:"recipient_search_text"`
+	TimePeriod          []TimePeriod `json:"time_period"`
+	Page                int          `json:"page"`
+	LastRecordUniqueID  int          `json:"last_record_unique_id"`
+	LastRecordSortValue string       `json:"last_record_sort_value"`
+}
+
+// ListRequest is one page requested from an award list endpoint.
+type ListRequest struct {
+	Path    string `json:"-"` // e.g. "/api/v2/transactions/"
+	AwardID string `json:"award_id"`
+	Page    int    `json:"page"`
+	Limit   int    `json:"limit"`
+	Sort    string `json:"sort"`
+}
+
+// TimePeriod is one entry of the time_period filter.
+type TimePeriod struct {
+	StartDate string `json:"start_date"`
+	EndDate   string `json:"end_date"`
+}
+
+// New starts a fake API serving the fixtures. Close it when done.
+func New(fixtures *Fixtures) (*Server, error) {
+	s := &Server{
+		awards:   fixtures.Awards,
+		details:  make(map[string]json.RawMessage),
+		fixtures: fixtures,
+		lists: map[string]map[string][]json.RawMessage{
+			transactionsPath: {},
+			subawardsPath:    {},
+			fundingPath:      {},
+		},
+	}
+	for _, award := range fixtures.Awards {
+		var row struct {
+			ID         string `json:"generated_internal_id"`
+			InternalID int    `json:"internal_id"`
+		}
+		if err := json.Unmarshal(award.Row, &row); err != nil || row.ID == "" {
+			return nil, fmt.Errorf("fixture row has no generated_internal_id: %s", award.Row)
+		}
+		s.internalIDs = append(s.internalIDs, row.InternalID)
+		if award.Detail != nil {
+			s.details[row.ID] = award.Detail
+		}
+		s.lists[transactionsPath][row.ID] = award.Transactions
+		s.lists[subawardsPath][row.ID] = award.Subawards
+		s.lists[fundingPath][row.ID] = award.Funding
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v2/search/spending_by_award/", s.searchAwards)
+	mux.HandleFunc("/api/v2/search/spending_by_award_count/", s.countAwards)
+	mux.HandleFunc("/api/v2/awards/", s.awardDetail)
+	for path := range s.lists {
+		mux.HandleFunc(path, s.awardList)
+	}
+	mux.HandleFunc("/api/v2/recipient/", s.recipient)
+	mux.HandleFunc("/api/v2/search/spending_by_category/recipient/", s.recipientAmounts)
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, r.Method+" "+r.URL.RequestURI())
+		s.mu.Unlock()
+		mux.ServeHTTP(w, r)
+	}))
+	return s, nil
+}
+
+// Requests returns the method and path, with any query, of every request
+// served so far.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+// Searches returns every spending_by_award request served so far.
+func (s *Server) Searches() []Search {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Search(nil), s.searches...)
+}
+
+// ListRequests returns every award list page served so far.
+func (s *Server) ListRequests() []ListRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]ListRequest(nil), s.listed...)
+}
+
+// Award list endpoints paged per award
+const (
+	transactionsPath = "/api/v2/transactions/"
+	subawardsPath    = "/api/v2/subawards/"
+	fundingPath      = "/api/v2/awards/funding/"
+)
+
+type searchRequest struct {
+	Filters struct {
+		AwardTypeCodes      []string     `json:"award_type_codes"`
+		RecipientSearchText []string     `json:"recipient_search_text"`
+		RecipientID         string       `json:"recipient_id"`
+		TimePeriod          []TimePeriod `json:"time_period"`
+	} `json:"filters"`
+	SpendingLevel       string `json:"spending_level"`
+	Page                int    `json:"page"`
+	Limit               int    `json:"limit"`
+	LastRecordUniqueID  int    `json:"last_record_unique_id"`
+	LastRecordSortValue string `json:"last_record_sort_value"`
+}
+
+// matching returns the indexes of the fixtures whose type code is one of
+// the request's codes and whose action date falls in one of its time
+// periods, in fixture order.
+func (s *Server) matching(req *searchRequest) []int {
+	var indexes []int
+	for i, award := range s.awards {
+		if !inPeriods(award.ActionDate, req.Filters.TimePeriod) {
+			continue
+		}
+		if hasCode(req.Filters.AwardTypeCodes, award.TypeCode) {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// hasCode reports whether code is one of the requested award type codes.
+func hasCode(codes []string, code string) bool {
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// pageSize is the number of rows a page holds for a request's limit.
+func (s *Server) pageSize(limit int) int {
+	size := limit
+	if s.PageSize > 0 && (size == 0 || s.PageSize < size) {
+		size = s.PageSize
+	}
+	if size < 1 {
+		size = 10
+	}
+	return size
+}
+
+// page cuts one page out of n rows, returning its bounds and whether more
+// rows follow.
+func page(n, start, size int) (int, int, bool) {
+	end := start + size
+	if start > n {
+		start = n
+	}
+	if end > n {
+		end = n
+	}
+	return start, end, end < n
+}
+
+// inPeriods reports whether an ISO date falls in any of the periods. An
+// empty date or period list matches.
+func inPeriods(date string, periods []TimePeriod) bool {
+	if date == "" || len(periods) == 0 {
+		return true
+	}
+	for _, period := range periods {
+		if date >= period.StartDate && date <= period.EndDate {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) searchAwards(w http.ResponseWriter, r *http.Request) {
+	var req searchRequest
+	if !decodePost(w, r, &req) {
+		return
+	}
+	if req.Page < 1 {
+		req.Page = 1
+	}
+	size := s.pageSize(req.Limit)
+
+	s.mu.Lock()
+	s.searches = append(s.searches, Search{
+		SpendingLevel:       req.SpendingLevel,
+		AwardTypeCodes:      req.Filters.AwardTypeCodes,
+		RecipientSearchText: req.Filters.RecipientSearchText,
+		TimePeriod:          req.Filters.TimePeriod,
+		Page:                req.Page,
+		LastRecordUniqueID:  req.LastRecordUniqueID,
+		LastRecordSortValue: req.LastRecordSortValue,
+	})
+	s.mu.Unlock()
+
+	if req.SpendingLevel == "subawards" {
+		s.searchSubawards(w, &req, size)
+		return
+	}
+
+	matches := s.matching(&req)
+	start := (req.Page - 1) * size
+	if s.Keyset && req.LastRecordUniqueID != 0 {
+		start = len(matches)
+		for i, index := range matches {
+			if s.internalIDs[index] == req.LastRecordUniqueID {
+				start = i + 1
+				break
+			}
+		}
+	} else if s.ResultWindow > 0 && req.Page*size > s.ResultWindow {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]string{
+			"detail": fmt.Sprintf("Page %d with limit %d is past the %d-record result window", req.Page, size, s.ResultWindow),
+		})
+		return
+	}
+	start, end, hasNext := page(len(matches), start, size)
+	rows := make([]json.RawMessage, 0, end-start)
+	for _, index := range matches[start:end] {
+		rows = append(rows, s.awards[index].Row)
+	}
+
+	metadata := map[string]interface{}{
+		"page":    req.Page,
+		"hasNext": hasNext,
+	}
+	if s.Keyset && end > start {
+		last := matches[end-1]
+		metadata["last_record_unique_id"] = s.internalIDs[last]
+		metadata["last_record_sort_value"] = s.awards[last].ActionDate
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"results":       rows,
+		"page_metadata": metadata,
+	})
+}
+
+// searchSubawards serves a page of the subaward search rows whose prime
+// award type code was requested.
+func (s *Server) searchSubawards(w http.ResponseWriter, req *searchRequest, size int) {
+	var matches []json.RawMessage
+	for _, row := range s.fixtures.SubawardSearch {
+		if hasCode(req.Filters.AwardTypeCodes, row.TypeCode) {
+			matches = append(matches, row.Row)
+		}
+	}
+	start, end, hasNext := page(len(matches), (req.Page-1)*size, size)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"results":       append([]json.RawMessage{}, matches[start:end]...),
+		"page_metadata": map[string]interface{}{"page": req.Page, "hasNext": hasNext},
+	})
+}
+
+func (s *Server) countAwards(w http.ResponseWriter, r *http.Request) {
+	var req searchRequest
+	if !decodePost(w, r, &req) {
+		return
+	}
+	counts := map[string]int{"contracts": 0, "direct_payments": 0, "grants": 0, "idvs": 0, "loans": 0, "other": 0}
+	for _, index := range s.matching(&req) {
+		award := s.awards[index]
+		key, ok := countKeys[award.TypeCode]
+		if !ok && strings.HasPrefix(award.TypeCode, "IDV_") {
+			key, ok = "idvs", true
+		}
+		if ok {
+			counts[key]++
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"results": counts})
+}
+
+func (s *Server) awardDetail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"detail": "Method not allowed"})
+		return
+	}
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v2/awards/"), "/")
+	detail, ok := s.details[id]
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"detail": "No award found with: '" + id + "'"})
+		return
+	}
+	writeJSON(w, http.StatusOK, detail)
+}
+
+// awardList serves a page of one award's transactions, subawards or
+// funding rows. An award without rows, or unknown to the fixtures, has an
+// empty list, as the API answers.
+func (s *Server) awardList(w http.ResponseWriter, r *http.Request) {
+	var req ListRequest
+	if !decodePost(w, r, &req) {
+		return
+	}
+	req.Path = r.URL.Path
+	if req.Page < 1 {
+		req.Page = 1
+	}
+	s.mu.Lock()
+	s.listed = append(s.listed, req)
+	s.mu.Unlock()
+
+	rows := s.lists[r.URL.Path][req.AwardID]
+	size := s.pageSize(req.Limit)
+	start, end, hasNext := page(len(rows), (req.Page-1)*size, size)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"results":       append([]json.RawMessage{}, rows[start:end]...),
+		"page_metadata": map[string]interface{}{"page": req.Page, "hasNext": hasNext},
+	})
+}
+
+// recipient serves recipient/{id} profiles and recipient/children/{uei}.
+func (s *Server) recipient(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"detail": "Method not allowed"})
+		return
+	}
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v2/recipient/"), "/")
+	if uei, ok := strings.CutPrefix(id, "children/"); ok {
+		writeJSON(w, http.StatusOK, append([]json.RawMessage{}, s.fixtures.RecipientChildren[uei]...))
+		return
+	}
+	profile, ok := s.fixtures.Recipients[id]
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"detail": "Recipient not found: '" + id + "'"})
+		return
+	}
+	writeJSON(w, http.StatusOK, profile)
+}
+
+// recipientAmounts answers spending_by_category/recipient with the amounts
+// recorded for the filtered recipient under the requested type codes.
+func (s *Server) recipientAmounts(w http.ResponseWriter, r *http.Request) {
+	var req searchRequest
+	if !decodePost(w, r, &req) {
+		return
+	}
+	results := []map[string]json.RawMessage{}
+	for _, amount := range s.fixtures.RecipientAmounts {
+		if amount.RecipientID == req.Filters.RecipientID && hasCode(req.Filters.AwardTypeCodes, amount.TypeCode) {
+			id, _ := json.Marshal(amount.RecipientID)
+			results = append(results, map[string]json.RawMessage{"amount": amount.Amount, "recipient_id": id})
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"results":       results,
+		"page_metadata": map[string]interface{}{"page": 1, "hasNext": false},
+	})
+}
+
+func decodePost(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"detail": "Method not allowed"})
+		return false
+	}
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"detail": err.Error()})
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
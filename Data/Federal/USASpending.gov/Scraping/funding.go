@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Funding rows requested per page; the most the endpoint allows
+const fundingPageSize = 100
+
+// File at the output root holding the last funding rollup
+const fundingRollupFile = "funding_rollup.json"
+
+// Funding is one File C row from /api/v2/awards/funding/: what a federal
+// account obligated and outlayed for an award in one reporting period.
+//
+// FederalAccount is the agency and main account code (e.g. "075-0846"),
+// which groups the treasury account symbols (TAS) of one appropriation. The
+// endpoint returns no TAS, so funding is never broken down below it.
+type Funding struct {
+	FederalAccount             string `json:"federal_account"`
+	AccountTitle               string `json:"account_title"`
+	ObjectClass                string `json:"object_class"`
+	ObjectClassName            string `json:"object_class_name"`
+	ProgramActivityCode        string `json:"program_activity_code"`
+	ProgramActivityName        string `json:"program_activity_name"`
+	DisasterEmergencyFundCode  string `json:"disaster_emergency_fund_code"`
+	TransactionObligatedAmount Money  `json:"transaction_obligated_amount"`
+	GrossOutlayAmount          Money  `json:"gross_outlay_amount"`
+	FundingAgencyName          string `json:"funding_agency_name"`
+	AwardingAgencyName         string `json:"awarding_agency_name"`
+	ReportingFiscalYear        int    `json:"reporting_fiscal_year"`
+	ReportingFiscalQuarter     int    `json:"reporting_fiscal_quarter"`
+	ReportingFiscalMonth       int    `json:"reporting_fiscal_month"`
+	IsQuarterlySubmission      bool   `json:"is_quarterly_submission"`
+
+	raw      json.RawMessage // Row as the API sent it
+	mismatch error           // Type mismatch tolerated while decoding
+}
+
+// UnmarshalJSON decodes a funding row and keeps it verbatim.
+func (f *Funding) UnmarshalJSON(data []byte) error {
+	type plain Funding
+	if err := json.Unmarshal(data, (*plain)(f)); err != nil {
+		if err := tolerateTypeError(err, "funding row for "+f.FederalAccount, &f.mismatch); err != nil {
+			return err
+		}
+	}
+	f.raw = captureRaw(data)
+	return nil
+}
+
+// RawJSON returns the JSON the funding row was decoded from.
+func (f *Funding) RawJSON() json.RawMessage {
+	return f.raw
+}
+
+func (f *Funding) typeMismatch() error {
+	return f.mismatch
+}
+
+// fetchFunding pages through the federal account funding of an award,
+// latest reporting period first.
+func (s *Scraper) fetchFunding(ctx context.Context, generatedInternalID string) ([]Funding, error) {
+	funding, err := fetchAwardList[Funding](ctx, s, s.fundingURL, AwardListRequest{
+		AwardID: generatedInternalID,
+		Page:    1,
+		Limit:   fundingPageSize,
+		Sort:    "reporting_fiscal_date",
+		Order:   "desc",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error fetching funding: %w", err)
+	}
+	return funding, nil
+}
+
+// FundingRollup is one campus and federal account in funding_rollup.json.
+type FundingRollup struct {
+	Campus         string `json:"campus"`
+	FederalAccount string `json:"federal_account"`
+	AccountTitle   string `json:"account_title"`
+	Awards         int    `json:"awards"`
+	Rows           int    `json:"rows"`
+	Obligated      Money  `json:"obligated"`
+	Outlayed       Money  `json:"outlayed"`
+
+	obligated int64
+	outlayed  int64
+}
+
+// FundingRollupReport is the layout of funding_rollup.json.
+type FundingRollupReport struct {
+	GeneratedAt string          `json:"generated_at"`
+	Awards      int             `json:"awards"` // Saved awards with funding rows
+	Accounts    []FundingRollup `json:"accounts"`
+}
+
+// outlayKey identifies the rows of one award whose outlays are reported
+// cumulatively through a fiscal year.
+type outlayKey struct {
+	account, objectClass, programActivity, defc string
+	fiscalYear                                  int
+}
+
+// awardOutlays returns an award's outlays per federal account. File C
+// outlays are year to date, so only the latest period of each fiscal year
+// counts; obligations, by contrast, are per period and are summed as is.
+func awardOutlays(funding []Funding) map[string]int64 {
+	latest := make(map[outlayKey]*Funding)
+	for i := range funding {
+		row := &funding[i]
+		key := outlayKey{row.FederalAccount, row.ObjectClass, row.ProgramActivityCode, row.DisasterEmergencyFundCode, row.ReportingFiscalYear}
+		if current := latest[key]; current == nil || row.ReportingFiscalMonth > current.ReportingFiscalMonth {
+			latest[key] = row
+		}
+	}
+	outlays := make(map[string]int64)
+	for key, row := range latest {
+		if cents, ok := row.GrossOutlayAmount.Cents(); ok {
+			outlays[key.account] += cents
+		}
+	}
+	return outlays
+}
+
+// rollupFunding sums the saved funding rows of UC awards by campus and
+// federal account, largest obligation first.
+func rollupFunding(outputRoot string, groups []string, resolver *RecipientResolver) (FundingRollupReport, error) {
+	report := FundingRollupReport{GeneratedAt: time.Now().Format(time.RFC3339)}
+	rollups := make(map[[2]string]*FundingRollup)
+	for _, groupName := range groups {
+		err := walkEnhancedAwards(outputRoot, groupName, func(path string, award *EnhancedAward) error {
+			if len(award.Funding) == 0 {
+				return nil
+			}
+			campus := resolver.Resolve(award).Entity
+			if !campus.IsUC() {
+				return nil
+			}
+			report.Awards++
+
+			seen := make(map[string]bool)
+			for _, row := range award.Funding {
+				key := [2]string{campus.Key, row.FederalAccount}
+				rollup := rollups[key]
+				if rollup == nil {
+					rollup = &FundingRollup{Campus: campus.Key, FederalAccount: row.FederalAccount}
+					rollups[key] = rollup
+				}
+				if rollup.AccountTitle == "" {
+					rollup.AccountTitle = row.AccountTitle
+				}
+				if !seen[row.FederalAccount] {
+					seen[row.FederalAccount] = true
+					rollup.Awards++
+				}
+				rollup.Rows++
+				if cents, ok := row.TransactionObligatedAmount.Cents(); ok {
+					rollup.obligated += cents
+				}
+			}
+			for account, cents := range awardOutlays(award.Funding) {
+				rollups[[2]string{campus.Key, account}].outlayed += cents
+			}
+			return nil
+		})
+		if err != nil {
+			return report, err
+		}
+	}
+
+	report.Accounts = make([]FundingRollup, 0, len(rollups))
+	for _, rollup := range rollups {
+		rollup.Obligated = MoneyFromCents(rollup.obligated)
+		rollup.Outlayed = MoneyFromCents(rollup.outlayed)
+		report.Accounts = append(report.Accounts, *rollup)
+	}
+	sort.Slice(report.Accounts, func(i, j int) bool {
+		a, b := report.Accounts[i], report.Accounts[j]
+		if a.obligated != b.obligated {
+			return a.obligated > b.obligated
+		}
+		if a.Campus != b.Campus {
+			return a.Campus < b.Campus
+		}
+		return a.FederalAccount < b.FederalAccount
+	})
+	return report, nil
+}
+
+// printFundingRollup writes the rollup to w and to funding_rollup.json.
+func printFundingRollup(w io.Writer, outputRoot string, groups []string, resolver *RecipientResolver) error {
+	report, err := rollupFunding(outputRoot, groups, resolver)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "Funding by campus and federal account (%d saved awards with funding)\n", report.Awards)
+	if report.Awards == 0 {
+		fmt.Fprintf(w, "  none; scrape or update with -funding to fetch it\n")
+	}
+	for _, rollup := range report.Accounts {
+		fmt.Fprintf(w, "  %-22s %-10s %-50.50s %5d awards  obligated $%s  outlayed $%s\n",
+			rollup.Campus, rollup.FederalAccount, rollup.AccountTitle, rollup.Awards, rollup.Obligated, rollup.Outlayed)
+	}
+
+	path := filepath.Join(outputRoot, fundingRollupFile)
+	if err := writeJSONFile(path, report); err != nil {
+		return fmt.Errorf("error writing %s: %w", fundingRollupFile, err)
+	}
+	fmt.Fprintf(w, "\nWritten to %s\n", path)
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFundingRollup(t *testing.T) {
+	// UCSF reports two periods of FY2022 with year-to-date outlays, then FY2023
+	server, s := scrapeFakeAPI(t, "fake_funding.json", ScraperOptions{Funding: true}, 2, "grants")
+	for _, req := range server.ListRequests() {
+		if req.Path != "/api/v2/awards/funding/" || req.Sort != "reporting_fiscal_date" {
+			t.Errorf("unexpected request %+v", req)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := printFundingRollup(&out, s.outputRoot, []string{"grants"}, s.resolver); err != nil {
+		t.Fatalf("printFundingRollup: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(s.outputRoot, fundingRollupFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report FundingRollupReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, rollup := range report.Accounts {
+		got = append(got, fmt.Sprintf("%s %s %d/%d %s %s", rollup.Campus, rollup.FederalAccount, rollup.Awards, rollup.Rows, rollup.Obligated, rollup.Outlayed))
+	}
+	want := []string{
+		"UC_SAN_FRANCISCO 075-0846 1/3 150000.00 160000.00",
+		"UC_LOS_ANGELES 075-0846 1/1 40000.00 10000.00",
+		"UC_SAN_FRANCISCO 075-0849 1/1 20000.50 0.00",
+	}
+	if report.Awards != 2 || strings.Join(got, "; ") != strings.Join(want, "; ") {
+		t.Errorf("rollup of %d awards = %v, want %v", report.Awards, got, want)
+	}
+	if !strings.Contains(out.String(), "National Cancer Institute") {
+		t.Errorf("report output:\n%s", out.String())
+	}
+
+	// The saved award keeps the rows verbatim for schema-drift
+	err = walkEnhancedAwards(s.outputRoot, "grants", func(path string, award *EnhancedAward) error {
+		if award.BasicData.GeneratedInternalID == "ASST_NON_UCSF" {
+			if award.Raw == nil || len(award.Raw.Funding) != 4 || !strings.Contains(string(award.Raw.Funding[0]), "future_key") {
+				t.Errorf("raw funding not kept: %+v", award.Raw)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
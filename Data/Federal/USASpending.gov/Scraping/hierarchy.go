@@ -0,0 +1,489 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Directory at the output root holding recipient profiles and the tree
+const recipientsDir = "recipients"
+
+// File in recipientsDir holding the parent/child tree
+const recipientTreeFile = "tree.json"
+
+// RecipientProfile is a /api/v2/recipient/{id}/ response. The ID is a
+// recipient hash with its level: P for a parent, C for a child with a
+// parent on record and R for a recipient without one.
+type RecipientProfile struct {
+	Name                           string            `json:"name"`
+	AlternateNames                 []string          `json:"alternate_names"`
+	DUNS                           string            `json:"duns"`
+	UEI                            string            `json:"uei"`
+	RecipientID                    string            `json:"recipient_id"`
+	RecipientLevel                 string            `json:"recipient_level"`
+	ParentID                       string            `json:"parent_id"`
+	ParentName                     string            `json:"parent_name"`
+	ParentDUNS                     string            `json:"parent_duns"`
+	ParentUEI                      string            `json:"parent_uei"`
+	Parents                        []RecipientParent `json:"parents"`
+	BusinessTypes                  []string          `json:"business_types"`
+	Location                       RecipientLocation `json:"location"`
+	TotalTransactionAmount         Money             `json:"total_transaction_amount"`
+	TotalTransactions              int               `json:"total_transactions"`
+	TotalFaceValueLoanAmount       Money             `json:"total_face_value_loan_amount"`
+	TotalFaceValueLoanTransactions int               `json:"total_face_value_loan_transactions"`
+
+	raw      json.RawMessage // Response as the API sent it
+	mismatch error           // Type mismatch tolerated while decoding
+}
+
+// RecipientParent is one parent a recipient has had on record.
+type RecipientParent struct {
+	ParentID   string `json:"parent_id"`
+	ParentName string `json:"parent_name"`
+	ParentDUNS string `json:"parent_duns"`
+	ParentUEI  string `json:"parent_uei"`
+}
+
+// RecipientLocation is the address of a recipient profile.
+type RecipientLocation struct {
+	AddressLine1      string `json:"address_line1"`
+	AddressLine2      string `json:"address_line2"`
+	AddressLine3      string `json:"address_line3"`
+	CityName          string `json:"city_name"`
+	StateCode         string `json:"state_code"`
+	Zip               string `json:"zip"`
+	Zip4              string `json:"zip4"`
+	CountryCode       string `json:"country_code"`
+	CountryName       string `json:"country_name"`
+	CongressionalCode string `json:"congressional_code"`
+	ForeignProvince   string `json:"foreign_province"`
+	ForeignPostalCode string `json:"foreign_postal_code"`
+}
+
+// UnmarshalJSON decodes a recipient profile and keeps it verbatim.
+func (p *RecipientProfile) UnmarshalJSON(data []byte) error {
+	type plain RecipientProfile
+	if err := json.Unmarshal(data, (*plain)(p)); err != nil {
+		if err := tolerateTypeError(err, "recipient "+p.RecipientID, &p.mismatch); err != nil {
+			return err
+		}
+	}
+	p.raw = captureRaw(data)
+	return nil
+}
+
+// RawJSON returns the JSON the profile was decoded from.
+func (p *RecipientProfile) RawJSON() json.RawMessage {
+	return p.raw
+}
+
+// RecipientChild is one child of a parent recipient, from
+// /api/v2/recipient/children/{uei}/.
+type RecipientChild struct {
+	RecipientID   string `json:"recipient_id"`
+	Name          string `json:"name"`
+	DUNS          string `json:"duns"`
+	UEI           string `json:"uei"`
+	StateProvince string `json:"state_province"`
+	Amount        Money  `json:"amount"`
+}
+
+// RecipientIdentifier is a UEI and DUNS pair seen on saved awards of a
+// recipient, with the span of award dates it was used for.
+type RecipientIdentifier struct {
+	UEI       string `json:"uei,omitempty"`
+	DUNS      string `json:"duns,omitempty"`
+	FirstSeen Date   `json:"first_seen"`
+	LastSeen  Date   `json:"last_seen"`
+	Awards    int    `json:"awards"`
+}
+
+// RecipientRecord is the layout of recipients/<recipient_id>.json.
+type RecipientRecord struct {
+	FetchedAt      string                `json:"fetched_at"`
+	Entity         string                `json:"entity"` // Canonical entity the profile resolves to
+	Profile        RecipientProfile      `json:"profile"`
+	Children       []RecipientChild      `json:"children,omitempty"`         // Parents only
+	AmountsByGroup map[string]Money      `json:"amounts_by_group,omitempty"` // Within the profile's time periods
+	Identifiers    []RecipientIdentifier `json:"identifiers,omitempty"`      // From saved awards, oldest first
+	SavedAwards    int                   `json:"saved_awards"`
+	RawProfile     json.RawMessage       `json:"raw_profile,omitempty"`
+}
+
+// recipientIdentifier returns the entry for the uei and duns pair in ids,
+// adding it when new.
+func recipientIdentifier(ids *[]RecipientIdentifier, uei, duns string) *RecipientIdentifier {
+	for i := range *ids {
+		if (*ids)[i].UEI == uei && (*ids)[i].DUNS == duns {
+			return &(*ids)[i]
+		}
+	}
+	*ids = append(*ids, RecipientIdentifier{UEI: uei, DUNS: duns})
+	return &(*ids)[len(*ids)-1]
+}
+
+// savedRecipients is what the saved awards say about each recipient hash.
+type savedRecipients struct {
+	awards      map[string]int
+	identifiers map[string][]RecipientIdentifier
+}
+
+func (r *savedRecipients) add(recipientID, uei string, duns *string, date Date) {
+	if recipientID == "" {
+		return
+	}
+	r.awards[recipientID]++
+	if duns == nil {
+		duns = new(string)
+	}
+	ids := r.identifiers[recipientID]
+	id := recipientIdentifier(&ids, uei, *duns)
+	id.Awards++
+	if !date.IsZero() {
+		if id.FirstSeen.IsZero() || date.Before(id.FirstSeen) {
+			id.FirstSeen = date
+		}
+		if id.LastSeen.IsZero() || id.LastSeen.Before(date) {
+			id.LastSeen = date
+		}
+	}
+	r.identifiers[recipientID] = ids
+}
+
+// collectSavedRecipients gathers the recipient and parent hashes of the saved
+// awards with detail, and the identifiers each was used with.
+func collectSavedRecipients(outputRoot string, groups []string) (*savedRecipients, error) {
+	saved := &savedRecipients{awards: make(map[string]int), identifiers: make(map[string][]RecipientIdentifier)}
+	for _, groupName := range groups {
+		err := walkEnhancedAwards(outputRoot, groupName, func(path string, award *EnhancedAward) error {
+			if award.DetailedData == nil {
+				return nil
+			}
+			recipient := award.DetailedData.Common().Recipient
+			date, _ := awardDate(award)
+			saved.add(recipient.RecipientHash, recipient.RecipientUEI, recipient.RecipientUniqueID, date)
+			saved.add(recipient.ParentRecipientHash, recipient.ParentRecipientUEI, recipient.ParentRecipientUniqueID, date)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, ids := range saved.identifiers {
+		sort.SliceStable(ids, func(i, j int) bool { return ids[i].FirstSeen.Before(ids[j].FirstSeen) })
+	}
+	return saved, nil
+}
+
+// fetchRecipientProfile fetches the all-time profile of a recipient.
+func (s *Scraper) fetchRecipientProfile(ctx context.Context, recipientID string) (*RecipientProfile, error) {
+	profileURL := s.recipientURL + url.PathEscape(recipientID) + "/?year=all"
+	if s.dryRun {
+		fmt.Fprintf(s.out, "GET %s\n", profileURL)
+		return nil, nil
+	}
+	var profile RecipientProfile
+	if err := s.getJSON(ctx, profileURL, &profile); err != nil {
+		return nil, fmt.Errorf("error fetching recipient %s: %w", recipientID, err)
+	}
+	warnTypeMismatch(profile.mismatch)
+	return &profile, nil
+}
+
+// fetchRecipientChildren lists the children of a parent recipient by its UEI
+// or, for older parents, its DUNS.
+func (s *Scraper) fetchRecipientChildren(ctx context.Context, parent *RecipientProfile) ([]RecipientChild, error) {
+	id := parent.UEI
+	if id == "" {
+		id = parent.DUNS
+	}
+	if id == "" {
+		return nil, nil
+	}
+	childrenURL := s.recipientURL + "children/" + url.PathEscape(id) + "/?year=all"
+	if s.dryRun {
+		fmt.Fprintf(s.out, "GET %s\n", childrenURL)
+		return nil, nil
+	}
+	var children []RecipientChild
+	if err := s.getJSON(ctx, childrenURL, &children); err != nil {
+		return nil, fmt.Errorf("error fetching children of %s: %w", parent.RecipientID, err)
+	}
+	sort.SliceStable(children, func(i, j int) bool { return children[i].Amount.Float64() > children[j].Amount.Float64() })
+	return children, nil
+}
+
+// RecipientCategoryRequest is the body of a spending_by_category/recipient
+// call.
+type RecipientCategoryRequest struct {
+	Filters Filters `json:"filters"`
+	Limit   int     `json:"limit"`
+	Page    int     `json:"page"`
+}
+
+type RecipientCategoryResponse struct {
+	Results []struct {
+		Amount      Money  `json:"amount"`
+		RecipientID string `json:"recipient_id"`
+		Name        string `json:"name"`
+	} `json:"results"`
+}
+
+// fetchRecipientAmounts sums what a recipient received per award group
+// within the search profile's time periods.
+func (s *Scraper) fetchRecipientAmounts(ctx context.Context, recipientID string) (map[string]Money, error) {
+	amounts := make(map[string]Money)
+	for _, groupName := range awardTypeGroupOrder {
+		request := RecipientCategoryRequest{
+			Filters: Filters{
+				TimePeriod:     s.profile.Filters.TimePeriod,
+				AwardTypeCodes: awardTypeGroups[groupName],
+				RecipientID:    recipientID,
+			},
+			Limit: 10,
+			Page:  1,
+		}
+		if s.dryRun {
+			if err := s.printDryRunRequest(s.recipientCategoryURL, request); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		var response RecipientCategoryResponse
+		if err := s.postJSON(ctx, s.recipientCategoryURL, request, &response); err != nil {
+			return nil, fmt.Errorf("error fetching %s amounts of %s: %w", groupName, recipientID, err)
+		}
+		var cents int64
+		for _, result := range response.Results {
+			if c, ok := result.Amount.Cents(); ok {
+				cents += c
+			}
+		}
+		if cents != 0 {
+			amounts[groupName] = MoneyFromCents(cents)
+		}
+	}
+	return amounts, nil
+}
+
+// fetchRecipientRecords fetches the profile of every recipient and parent
+// hash on the saved awards, with the children of each parent and the amounts
+// per award group, and writes them to recipients/. A recipient that fails
+// is logged and left out.
+func (s *Scraper) fetchRecipientRecords(ctx context.Context, saved *savedRecipients, resolver *RecipientResolver) ([]*RecipientRecord, error) {
+	ids := make([]string, 0, len(saved.awards))
+	for id := range saved.awards {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	dir := filepath.Join(s.outputRoot, recipientsDir)
+	if !s.dryRun {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("error creating %s: %w", dir, err)
+		}
+	}
+
+	var records []*RecipientRecord
+	for i, id := range ids {
+		log.Printf("Fetching recipient %d/%d: %s", i+1, len(ids), id)
+		record, err := s.fetchRecipientRecord(ctx, id, saved, resolver)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			log.Printf("Warning: %v", err)
+			continue
+		}
+		if record == nil {
+			continue // Dry run
+		}
+		if err := writeJSONFile(filepath.Join(dir, id+".json"), record); err != nil {
+			return nil, fmt.Errorf("error writing recipient %s: %w", id, err)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func (s *Scraper) fetchRecipientRecord(ctx context.Context, id string, saved *savedRecipients, resolver *RecipientResolver) (*RecipientRecord, error) {
+	profile, err := s.fetchRecipientProfile(ctx, id)
+	if err != nil || profile == nil {
+		return nil, err
+	}
+	if profile.RecipientID == "" {
+		profile.RecipientID = id
+	}
+	record := &RecipientRecord{
+		FetchedAt:   time.Now().Format(time.RFC3339),
+		Entity:      resolver.resolve(recipientRef{name: profile.Name, uei: profile.UEI, city: profile.Location.CityName}).Entity.Key,
+		Profile:     *profile,
+		Identifiers: saved.identifiers[id],
+		SavedAwards: saved.awards[id],
+		RawProfile:  profile.RawJSON(),
+	}
+	if strings.HasSuffix(id, "-P") {
+		if record.Children, err = s.fetchRecipientChildren(ctx, profile); err != nil {
+			return nil, err
+		}
+	}
+	if record.AmountsByGroup, err = s.fetchRecipientAmounts(ctx, id); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// RecipientTreeNode is one recipient or entity in recipients/tree.json.
+type RecipientTreeNode struct {
+	ID       string              `json:"id"`
+	Name     string              `json:"name"`
+	UEI      string              `json:"uei,omitempty"`
+	Entity   string              `json:"entity,omitempty"`
+	Amount   Money               `json:"amount"`
+	Children []RecipientTreeNode `json:"children,omitempty"`
+
+	cents int64
+}
+
+// RecipientTree is the layout of recipients/tree.json. Parents follows the
+// parent/child links the API reports. UC regroups every UC profile under
+// the Regents by canonical entity, since campuses are often registered
+// under other parents or none.
+type RecipientTree struct {
+	GeneratedAt string              `json:"generated_at"`
+	Parents     []RecipientTreeNode `json:"parents"`
+	UC          RecipientTreeNode   `json:"uc"`
+}
+
+// buildRecipientTree links the fetched records into the parent tree and the
+// UC rollup. A profile's amount is its all-time total_transaction_amount;
+// the C and R profiles of one hash hold the transactions with and without a
+// parent on record, so both are counted.
+func buildRecipientTree(records []*RecipientRecord) RecipientTree {
+	tree := RecipientTree{GeneratedAt: time.Now().Format(time.RFC3339)}
+	byID := make(map[string]*RecipientRecord)
+	for _, record := range records {
+		byID[record.Profile.RecipientID] = record
+	}
+	node := func(record *RecipientRecord) RecipientTreeNode {
+		cents, _ := record.Profile.TotalTransactionAmount.Cents()
+		return RecipientTreeNode{
+			ID:     record.Profile.RecipientID,
+			Name:   record.Profile.Name,
+			UEI:    record.Profile.UEI,
+			Entity: record.Entity,
+			Amount: MoneyFromCents(cents),
+			cents:  cents,
+		}
+	}
+
+	for _, record := range records {
+		if !strings.HasSuffix(record.Profile.RecipientID, "-P") {
+			continue
+		}
+		parent := node(record)
+		for _, child := range record.Children {
+			childNode := RecipientTreeNode{ID: child.RecipientID, Name: child.Name, UEI: child.UEI}
+			childNode.cents, _ = child.Amount.Cents()
+			childNode.Amount = MoneyFromCents(childNode.cents)
+			if fetched := byID[child.RecipientID]; fetched != nil {
+				childNode.Entity = fetched.Entity
+			}
+			parent.Children = append(parent.Children, childNode)
+		}
+		tree.Parents = append(tree.Parents, parent)
+	}
+	sortRecipientNodes(tree.Parents)
+
+	// UC rollup: Regents -> entity -> recipient profiles
+	entities := make(map[string]*RecipientTreeNode)
+	tree.UC = RecipientTreeNode{ID: "UC", Name: "Regents of the University of California"}
+	for _, record := range records {
+		entity := entityByKey[record.Entity]
+		if !entity.IsUC() || strings.HasSuffix(record.Profile.RecipientID, "-P") {
+			continue // Parents would count their children again
+		}
+		group := entities[entity.Key]
+		if group == nil {
+			group = &RecipientTreeNode{ID: entity.Key, Name: entity.Name, Entity: entity.Key}
+			entities[entity.Key] = group
+		}
+		child := node(record)
+		group.Children = append(group.Children, child)
+		group.cents += child.cents
+	}
+	for _, group := range entities {
+		sortRecipientNodes(group.Children)
+		group.Amount = MoneyFromCents(group.cents)
+		tree.UC.Children = append(tree.UC.Children, *group)
+		tree.UC.cents += group.cents
+	}
+	sortRecipientNodes(tree.UC.Children)
+	tree.UC.Amount = MoneyFromCents(tree.UC.cents)
+	return tree
+}
+
+// sortRecipientNodes orders nodes by amount, largest first.
+func sortRecipientNodes(nodes []RecipientTreeNode) {
+	sort.SliceStable(nodes, func(i, j int) bool {
+		if nodes[i].cents != nodes[j].cents {
+			return nodes[i].cents > nodes[j].cents
+		}
+		return nodes[i].ID < nodes[j].ID
+	})
+}
+
+// printRecipientTree writes a node and its children, one level per indent.
+func printRecipientTree(w io.Writer, node RecipientTreeNode, indent string) {
+	label := node.Name
+	if node.ID != "" && node.ID != node.Entity {
+		label += " (" + node.ID + ")"
+	}
+	if node.Entity != "" && node.ID != node.Entity {
+		label += " -> " + node.Entity
+	}
+	fmt.Fprintf(w, "%s%s: $%s\n", indent, label, node.Amount)
+	for _, child := range node.Children {
+		printRecipientTree(w, child, indent+"  ")
+	}
+}
+
+// runRecipientStage fetches the recipient profiles behind the saved awards,
+// writes recipients/ and prints the UC rollup and the parent tree.
+func runRecipientStage(ctx context.Context, w io.Writer, s *Scraper, groups []string, resolver *RecipientResolver) error {
+	saved, err := collectSavedRecipients(s.outputRoot, groups)
+	if err != nil {
+		return err
+	}
+	log.Printf("Saved awards name %d distinct recipient and parent profiles", len(saved.awards))
+
+	records, err := s.fetchRecipientRecords(ctx, saved, resolver)
+	if err != nil || s.dryRun {
+		return err
+	}
+
+	tree := buildRecipientTree(records)
+	fmt.Fprintf(w, "University of California rollup (all-time transaction amounts)\n")
+	printRecipientTree(w, tree.UC, "  ")
+	fmt.Fprintf(w, "\nParents on record (%d)\n", len(tree.Parents))
+	for _, parent := range tree.Parents {
+		printRecipientTree(w, parent, "  ")
+	}
+
+	path := filepath.Join(s.outputRoot, recipientsDir, recipientTreeFile)
+	if err := writeJSONFile(path, tree); err != nil {
+		return fmt.Errorf("error writing %s: %w", recipientTreeFile, err)
+	}
+	fmt.Fprintf(w, "\nWritten %d profiles and %s\n", len(records), path)
+	return nil
+}
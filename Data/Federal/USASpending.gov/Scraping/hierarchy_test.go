@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecipientStage(t *testing.T) {
+	server, s := scrapeFakeAPI(t, "fake_recipients.json", ScraperOptions{}, 3, "grants")
+
+	var out bytes.Buffer
+	if err := runRecipientStage(context.Background(), &out, s, []string{"grants"}, s.resolver); err != nil {
+		t.Fatalf("runRecipientStage: %v", err)
+	}
+	categoryRequests := 0
+	for _, request := range server.Requests() {
+		switch {
+		case request == "POST /api/v2/search/spending_by_category/recipient/":
+			categoryRequests++
+		case strings.HasPrefix(request, "GET /api/v2/recipient/") && !strings.HasSuffix(request, "/?year=all"):
+			t.Errorf("recipient requested without year=all: %s", request)
+		}
+	}
+	if categoryRequests != 3*len(awardTypeGroupOrder) {
+		t.Errorf("%d category requests, want %d", categoryRequests, 3*len(awardTypeGroupOrder))
+	}
+
+	var ucla RecipientRecord
+	data, err := os.ReadFile(filepath.Join(s.outputRoot, recipientsDir, "ucla-C.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &ucla); err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, id := range ucla.Identifiers {
+		ids = append(ids, fmt.Sprintf("%s/%s %s..%s", id.UEI, id.DUNS, id.FirstSeen, id.LastSeen))
+	}
+	if got := strings.Join(ids, "; "); got != "/092530369 2015-05-01..2015-05-01; RN3BJ9G3SMF8/ 2023-03-01..2023-03-01" {
+		t.Errorf("identifiers = %s", got)
+	}
+	if ucla.Entity != "UC_LOS_ANGELES" || ucla.SavedAwards != 2 || ucla.AmountsByGroup["grants"].String() != "800.25" || ucla.Profile.AlternateNames[0] != "UCLA" {
+		t.Errorf("UCLA record = %+v", ucla)
+	}
+
+	var tree RecipientTree
+	data, err = os.ReadFile(filepath.Join(s.outputRoot, recipientsDir, recipientTreeFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &tree); err != nil {
+		t.Fatal(err)
+	}
+	if tree.UC.Amount.String() != "1400.25" || len(tree.UC.Children) != 2 || tree.UC.Children[0].ID != "UC_LOS_ANGELES" || tree.UC.Children[1].Children[0].ID != "ucr-R" {
+		t.Errorf("UC rollup = %+v", tree.UC)
+	}
+	if len(tree.Parents) != 1 || len(tree.Parents[0].Children) != 2 || tree.Parents[0].Children[0].Entity != "UC_LOS_ANGELES" {
+		t.Errorf("parents = %+v", tree.Parents)
+	}
+	if !strings.Contains(out.String(), "    UNIVERSITY OF CALIFORNIA, LOS ANGELES (ucla-C) -> UC_LOS_ANGELES: $1000.25") {
+		t.Errorf("output:\n%s", out.String())
+	}
+}
@@ -0,0 +1,456 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Child rows requested per page; the most the endpoint allows
+const idvChildPageSize = 100
+
+// Files at the output root holding the last award graph
+const (
+	awardGraphFile    = "award_graph.json"
+	awardGraphDOTFile = "award_graph.dot"
+)
+
+// ParentAward is the IDV an order or child IDV was placed under, as the
+// parent_award of an awards/{id} response. Fields are in the API's key order
+// and keep its nulls, so saved files re-encode unchanged.
+type ParentAward struct {
+	AgencyID                         *int    `json:"agency_id"`
+	AgencyName                       *string `json:"agency_name"`
+	AgencySlug                       *string `json:"agency_slug"`
+	AwardID                          *int    `json:"award_id"`
+	GeneratedUniqueAwardID           *string `json:"generated_unique_award_id"`
+	IDVTypeDescription               *string `json:"idv_type_description"`
+	MultipleOrSingleAwardDescription *string `json:"multiple_or_single_aw_desc"`
+	PIID                             *string `json:"piid"`
+	SubAgencyID                      *string `json:"sub_agency_id"`
+	SubAgencyName                    *string `json:"sub_agency_name"`
+	TypeOfIDCDescription             *string `json:"type_of_idc_description"`
+}
+
+// ID returns the generated unique award ID of the parent, or "".
+func (p *ParentAward) ID() string {
+	if p == nil || p.GeneratedUniqueAwardID == nil {
+		return ""
+	}
+	return *p.GeneratedUniqueAwardID
+}
+
+// IDVChild is one award or IDV under an IDV, from /api/v2/idvs/awards/.
+type IDVChild struct {
+	AwardID                  int    `json:"award_id"`
+	GeneratedUniqueAwardID   string `json:"generated_unique_award_id"`
+	PIID                     string `json:"piid"`
+	AwardType                string `json:"award_type"`
+	Description              string `json:"description"`
+	FundingAgency            string `json:"funding_agency"`
+	FundingAgencyID          int    `json:"funding_agency_id"`
+	LastDateToOrder          Date   `json:"last_date_to_order"`
+	ObligatedAmount          Money  `json:"obligated_amount"`
+	PeriodOfPerformanceStart Date   `json:"period_of_performance_start_date"`
+	PeriodOfPerformanceEnd   Date   `json:"period_of_performance_current_end_date"`
+
+	raw      json.RawMessage // Row as the API sent it
+	mismatch error           // Type mismatch tolerated while decoding
+}
+
+// UnmarshalJSON decodes a child row and keeps it verbatim.
+func (c *IDVChild) UnmarshalJSON(data []byte) error {
+	type plain IDVChild
+	if err := json.Unmarshal(data, (*plain)(c)); err != nil {
+		if err := tolerateTypeError(err, "IDV child "+c.GeneratedUniqueAwardID, &c.mismatch); err != nil {
+			return err
+		}
+	}
+	c.raw = captureRaw(data)
+	return nil
+}
+
+// RawJSON returns the JSON the child row was decoded from.
+func (c *IDVChild) RawJSON() json.RawMessage {
+	return c.raw
+}
+
+func (c *IDVChild) typeMismatch() error {
+	return c.mismatch
+}
+
+// IDVChildren holds what was placed under an IDV: orders made directly on
+// it, child IDVs, and the orders made on those child IDVs.
+type IDVChildren struct {
+	Awards        []IDVChild `json:"child_awards,omitempty"`
+	IDVs          []IDVChild `json:"child_idvs,omitempty"`
+	Grandchildren []IDVChild `json:"grandchild_awards,omitempty"`
+}
+
+// rows returns every child row, for raw capture.
+func (c *IDVChildren) rows() []*IDVChild {
+	var rows []*IDVChild
+	for _, list := range [][]IDVChild{c.Awards, c.IDVs, c.Grandchildren} {
+		for i := range list {
+			rows = append(rows, &list[i])
+		}
+	}
+	return rows
+}
+
+// fetchIDVChildren pages through the child awards, child IDVs and grandchild
+// awards of an IDV.
+func (s *Scraper) fetchIDVChildren(ctx context.Context, generatedInternalID string) (*IDVChildren, error) {
+	children := &IDVChildren{}
+	for _, list := range []struct {
+		kind string
+		rows *[]IDVChild
+	}{
+		{"child_awards", &children.Awards},
+		{"child_idvs", &children.IDVs},
+		{"grandchild_awards", &children.Grandchildren},
+	} {
+		rows, err := fetchAwardList[IDVChild](ctx, s, s.idvAwardsURL, AwardListRequest{
+			AwardID: generatedInternalID,
+			Type:    list.kind,
+			Page:    1,
+			Limit:   idvChildPageSize,
+			Sort:    "piid",
+			Order:   "asc",
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error fetching %s: %w", list.kind, err)
+		}
+		*list.rows = rows
+	}
+	return children, nil
+}
+
+// isIDVID reports whether a generated award ID names an IDV.
+func isIDVID(id string) bool {
+	return strings.HasPrefix(id, "CONT_IDV_")
+}
+
+// parentIDVFromID returns the IDV a contract award was ordered under, read
+// from its generated ID (CONT_AWD_<piid>_<agency>_<parent piid>_<parent
+// agency>), or "" for a standalone award.
+func parentIDVFromID(id string) string {
+	parts := strings.Split(id, "_")
+	if len(parts) != 6 || parts[0] != "CONT" || parts[1] != "AWD" || parts[4] == "-NONE-" {
+		return ""
+	}
+	return "CONT_IDV_" + parts[4] + "_" + parts[5]
+}
+
+// piidFromID returns the PIID in a generated contract or IDV ID.
+func piidFromID(id string) string {
+	parts := strings.Split(id, "_")
+	if len(parts) < 4 || parts[0] != "CONT" {
+		return ""
+	}
+	return parts[2]
+}
+
+// GraphNode is one award in award_graph.json. Children lists the awards and
+// IDVs placed under it; Total sums the obligations of every award (not IDV)
+// below an IDV.
+type GraphNode struct {
+	ID        string   `json:"id"`
+	PIID      string   `json:"piid,omitempty"`
+	Kind      string   `json:"kind"` // "idv" or "award"
+	Campus    string   `json:"campus,omitempty"`
+	Saved     bool     `json:"saved"` // Whether the award is in the tree
+	Obligated Money    `json:"obligated"`
+	Children  []string `json:"children,omitempty"`
+	Orders    int      `json:"orders,omitempty"`
+	Total     *Money   `json:"total_obligated,omitempty"`
+
+	obligated int64
+	children  map[string]bool
+}
+
+// AwardGraph is the layout of award_graph.json: parent/child links between
+// IDVs and the awards placed under them.
+type AwardGraph struct {
+	GeneratedAt string       `json:"generated_at"`
+	Nodes       []*GraphNode `json:"nodes"`
+
+	byID map[string]*GraphNode
+}
+
+func newAwardGraph() *AwardGraph {
+	return &AwardGraph{GeneratedAt: time.Now().Format(time.RFC3339), byID: make(map[string]*GraphNode)}
+}
+
+// node returns the node for id, adding it when new.
+func (g *AwardGraph) node(id string) *GraphNode {
+	node := g.byID[id]
+	if node == nil {
+		kind := "award"
+		if isIDVID(id) {
+			kind = "idv"
+		}
+		node = &GraphNode{ID: id, PIID: piidFromID(id), Kind: kind, children: make(map[string]bool)}
+		g.byID[id] = node
+	}
+	return node
+}
+
+// link records child as placed under parent.
+func (g *AwardGraph) link(parent, child string) {
+	if parent == "" || child == "" || parent == child {
+		return
+	}
+	g.node(parent).children[child] = true
+	g.node(child)
+}
+
+// setAmount records the obligation of an award that was not saved; saved
+// awards keep their own amount.
+func (n *GraphNode) setAmount(piid string, amount Money) {
+	if n.PIID == "" {
+		n.PIID = piid
+	}
+	if !n.Saved && !n.Obligated.Valid() {
+		n.Obligated = amount
+	}
+}
+
+// addAward adds a saved award with its parent and, for an IDV, its children.
+func (g *AwardGraph) addAward(award *EnhancedAward, campus Entity) {
+	id := award.BasicData.GeneratedInternalID
+	node := g.node(id)
+	node.Saved = true
+	node.Campus = campus.Key
+	node.Obligated = award.BasicData.AwardAmount
+	if award.BasicData.AwardID != "" {
+		node.PIID = award.BasicData.AwardID
+	}
+
+	// The typed parent_award is authoritative; the ID covers awards saved
+	// without detail
+	parent := parentIDVFromID(id)
+	if award.DetailedData != nil {
+		if p := award.DetailedData.Common().ParentAward.ID(); p != "" {
+			parent = p
+		}
+	}
+	g.link(parent, id)
+
+	if award.IDVChildren == nil {
+		return
+	}
+	for _, child := range award.IDVChildren.Awards {
+		g.link(id, child.GeneratedUniqueAwardID)
+		g.node(child.GeneratedUniqueAwardID).setAmount(child.PIID, child.ObligatedAmount)
+	}
+	for _, child := range award.IDVChildren.IDVs {
+		g.link(id, child.GeneratedUniqueAwardID)
+		g.node(child.GeneratedUniqueAwardID).setAmount(child.PIID, child.ObligatedAmount)
+	}
+	for _, child := range award.IDVChildren.Grandchildren {
+		// Grandchildren hang off a child IDV; the endpoint does not say
+		// which, but the order's generated ID does
+		parent := parentIDVFromID(child.GeneratedUniqueAwardID)
+		if parent == "" {
+			parent = id
+		}
+		g.link(id, parent)
+		g.link(parent, child.GeneratedUniqueAwardID)
+		g.node(child.GeneratedUniqueAwardID).setAmount(child.PIID, child.ObligatedAmount)
+	}
+}
+
+// finish sorts the nodes and their children and totals every IDV.
+func (g *AwardGraph) finish() {
+	g.Nodes = g.Nodes[:0]
+	for _, node := range g.byID {
+		node.Children = node.Children[:0]
+		for child := range node.children {
+			node.Children = append(node.Children, child)
+		}
+		sort.Strings(node.Children)
+		node.obligated, _ = node.Obligated.Cents()
+		g.Nodes = append(g.Nodes, node)
+	}
+	sort.Slice(g.Nodes, func(i, j int) bool { return g.Nodes[i].ID < g.Nodes[j].ID })
+
+	for _, node := range g.Nodes {
+		if node.Kind != "idv" {
+			continue
+		}
+		orders, cents := g.descendantAwards(node.ID)
+		total := MoneyFromCents(cents)
+		node.Orders, node.Total = orders, &total
+	}
+}
+
+// descendantAwards counts and sums the awards below an IDV, visiting each
+// node once even if it is linked from more than one place.
+func (g *AwardGraph) descendantAwards(id string) (int, int64) {
+	seen := map[string]bool{id: true}
+	stack := []string{id}
+	orders, cents := 0, int64(0)
+	for len(stack) > 0 {
+		node := g.byID[stack[len(stack)-1]]
+		stack = stack[:len(stack)-1]
+		for _, child := range node.Children {
+			if seen[child] {
+				continue
+			}
+			seen[child] = true
+			stack = append(stack, child)
+			if g.byID[child].Kind == "award" {
+				orders++
+				cents += g.byID[child].obligated
+			}
+		}
+	}
+	return orders, cents
+}
+
+// find returns the node whose ID or PIID is key.
+func (g *AwardGraph) find(key string) *GraphNode {
+	if node := g.byID[key]; node != nil {
+		return node
+	}
+	for _, node := range g.Nodes {
+		if node.PIID == key {
+			return node
+		}
+	}
+	return nil
+}
+
+// buildAwardGraph links the saved awards of the groups to their parent IDVs
+// and, for saved IDVs, to the children fetched with them.
+func buildAwardGraph(outputRoot string, groups []string, resolver *RecipientResolver) (*AwardGraph, error) {
+	graph := newAwardGraph()
+	for _, groupName := range groups {
+		err := walkEnhancedAwards(outputRoot, groupName, func(path string, award *EnhancedAward) error {
+			graph.addAward(award, resolver.Resolve(award).Entity)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	graph.finish()
+	return graph, nil
+}
+
+// writeDOT writes the graph in GraphViz DOT, IDVs as boxes. Standalone
+// awards with no parent or children are left out.
+func (g *AwardGraph) writeDOT(w io.Writer) error {
+	linked := make(map[string]bool)
+	for _, node := range g.Nodes {
+		for _, child := range node.Children {
+			linked[node.ID], linked[child] = true, true
+		}
+	}
+
+	fmt.Fprintln(w, "digraph awards {")
+	fmt.Fprintln(w, "  rankdir=LR;")
+	for _, node := range g.Nodes {
+		if !linked[node.ID] {
+			continue
+		}
+		label := node.PIID
+		if label == "" {
+			label = node.ID
+		}
+		if node.Total != nil {
+			label += fmt.Sprintf("\n%d orders, $%s", node.Orders, node.Total)
+		} else if node.Obligated.Valid() {
+			label += "\n$" + node.Obligated.String()
+		}
+		if node.Campus != "" {
+			label += "\n" + node.Campus
+		}
+		shape := "ellipse"
+		if node.Kind == "idv" {
+			shape = "box"
+		}
+		style := ""
+		if !node.Saved {
+			style = ", style=dashed"
+		}
+		fmt.Fprintf(w, "  %q [label=%q, shape=%s%s];\n", node.ID, label, shape, style)
+	}
+	for _, node := range g.Nodes {
+		for _, child := range node.Children {
+			fmt.Fprintf(w, "  %q -> %q;\n", node.ID, child)
+		}
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
+
+// printAwardGraph writes award_graph.json and award_graph.dot and lists the
+// IDVs by total obligation, or the tree under one IDV when idv is set.
+func printAwardGraph(w io.Writer, outputRoot string, groups []string, resolver *RecipientResolver, idv string) error {
+	graph, err := buildAwardGraph(outputRoot, groups, resolver)
+	if err != nil {
+		return err
+	}
+
+	if idv != "" {
+		root := graph.find(idv)
+		if root == nil {
+			return fmt.Errorf("no award %s in the graph", idv)
+		}
+		graph.printTree(w, root, "", make(map[string]bool))
+	} else {
+		var idvs []*GraphNode
+		for _, node := range graph.Nodes {
+			if node.Kind == "idv" && node.Orders > 0 {
+				idvs = append(idvs, node)
+			}
+		}
+		sort.SliceStable(idvs, func(i, j int) bool {
+			return idvs[i].Total.Float64() > idvs[j].Total.Float64()
+		})
+		fmt.Fprintf(w, "IDVs with orders in the tree: %d\n", len(idvs))
+		for _, node := range idvs {
+			fmt.Fprintf(w, "  %-16s %-40s %5d orders  $%s\n", node.PIID, node.ID, node.Orders, node.Total)
+		}
+	}
+
+	path := filepath.Join(outputRoot, awardGraphFile)
+	if err := writeJSONFile(path, graph); err != nil {
+		return fmt.Errorf("error writing %s: %w", awardGraphFile, err)
+	}
+	dotPath := filepath.Join(outputRoot, awardGraphDOTFile)
+	if err := writeFileAtomic(dotPath, graph.writeDOT); err != nil {
+		return fmt.Errorf("error writing %s: %w", awardGraphDOTFile, err)
+	}
+	fmt.Fprintf(w, "\nWritten to %s and %s\n", path, dotPath)
+	return nil
+}
+
+// printTree lists node and everything below it, one level per indent.
+func (g *AwardGraph) printTree(w io.Writer, node *GraphNode, indent string, seen map[string]bool) {
+	amount := "$" + node.Obligated.String()
+	if node.Total != nil {
+		amount = fmt.Sprintf("%d orders, $%s", node.Orders, node.Total)
+	} else if !node.Obligated.Valid() {
+		amount = "amount unknown"
+	}
+	saved := ""
+	if !node.Saved {
+		saved = "  (not saved)"
+	}
+	fmt.Fprintf(w, "%s%s %s: %s%s\n", indent, node.PIID, node.ID, amount, saved)
+	if seen[node.ID] {
+		return
+	}
+	seen[node.ID] = true
+	for _, child := range node.Children {
+		g.printTree(w, g.byID[child], indent+"  ", seen)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIDVChildrenAndAwardGraph(t *testing.T) {
+	const (
+		vehicle   = "CONT_IDV_W911NF19D0001_9700"
+		childIDV  = "CONT_IDV_W911NF19A0002_9700"
+		order     = "CONT_AWD_W911NF19F0024_9700_W911NF19D0001_9700"
+		grandkid  = "CONT_AWD_W911NF20F0001_9700_W911NF19A0002_9700"
+		otherUnit = "CONT_AWD_W911NF19F0030_9700_W911NF19D0001_9700"
+	)
+	var types []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/api/v2/idvs/awards/"):
+			var req AwardListRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			if req.AwardID != vehicle {
+				t.Errorf("children fetched for %s", req.AwardID)
+			}
+			types = append(types, req.Type)
+			switch req.Type {
+			case "child_awards":
+				fmt.Fprintf(w, `{"results":[
+					{"generated_unique_award_id":%q,"piid":"W911NF19F0024","obligated_amount":243300},
+					{"generated_unique_award_id":%q,"piid":"W911NF19F0030","obligated_amount":1000.50,"period_of_performance_start_date":"2019-10-01"}
+				],"page_metadata":{"page":1,"hasNext":false}}`, order, otherUnit)
+			case "child_idvs":
+				fmt.Fprintf(w, `{"results":[{"generated_unique_award_id":%q,"piid":"W911NF19A0002","obligated_amount":0}],"page_metadata":{"page":1,"hasNext":false}}`, childIDV)
+			default:
+				fmt.Fprintf(w, `{"results":[{"generated_unique_award_id":%q,"piid":"W911NF20F0001","obligated_amount":500,"future_key":1}],"page_metadata":{"page":1,"hasNext":false}}`, grandkid)
+			}
+		case strings.HasSuffix(r.URL.Path, "/"+vehicle+"/"):
+			fmt.Fprintf(w, `{"generated_unique_award_id":%q,"category":"idv","date_signed":"2019-01-10"}`, vehicle)
+		default:
+			fmt.Fprintf(w, `{"generated_unique_award_id":%q,"category":"contract","date_signed":"2019-06-01",
+				"parent_award":{"award_id":169786065,"generated_unique_award_id":%q,"piid":"W911NF19D0001","idv_type_description":"IDC"}}`, order, vehicle)
+		}
+	}))
+	defer server.Close()
+
+	s := newTestScraper(server.URL, 1)
+	s.outputRoot = t.TempDir()
+
+	recipient := "UNIVERSITY OF CALIFORNIA, SANTA BARBARA"
+	idvs := []Award{{GeneratedInternalID: vehicle, AwardID: "W911NF19D0001", RecipientName: recipient, StartDate: Date{2019, time.January, 10}}}
+	if saved, err := s.enrichGroupAwards(context.Background(), "idvs", idvs); err != nil || saved != 1 {
+		t.Fatalf("enrichGroupAwards(idvs) = %d, %v", saved, err)
+	}
+	if strings.Join(types, ",") != "child_awards,child_idvs,grandchild_awards" {
+		t.Errorf("child types fetched = %v", types)
+	}
+	contracts := []Award{{GeneratedInternalID: order, AwardID: "W911NF19F0024", RecipientName: recipient, AwardAmount: MoneyFromCents(24330000), StartDate: Date{2019, time.June, 1}}}
+	if saved, err := s.enrichGroupAwards(context.Background(), "contracts", contracts); err != nil || saved != 1 {
+		t.Fatalf("enrichGroupAwards(contracts) = %d, %v", saved, err)
+	}
+
+	// The order keeps a typed parent and the IDV its children
+	err := walkEnhancedAwards(s.outputRoot, "contracts", func(path string, award *EnhancedAward) error {
+		if parent := award.DetailedData.Common().ParentAward; parent.ID() != vehicle || parent.AwardID == nil || *parent.AwardID != 169786065 {
+			t.Errorf("parent_award = %+v", parent)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = walkEnhancedAwards(s.outputRoot, "idvs", func(path string, award *EnhancedAward) error {
+		if award.IDVChildren == nil || len(award.IDVChildren.Awards) != 2 || len(award.Raw.IDVChildren) != 4 {
+			t.Errorf("IDV children not saved: %+v", award.IDVChildren)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := printAwardGraph(&out, s.outputRoot, []string{"contracts", "idvs"}, s.resolver, "W911NF19D0001"); err != nil {
+		t.Fatalf("printAwardGraph: %v", err)
+	}
+	wantTree := "W911NF19D0001 " + vehicle + ": 3 orders, $244800.50\n" +
+		"  W911NF19F0024 " + order + ": $243300.00\n" +
+		"  W911NF19F0030 " + otherUnit + ": $1000.50  (not saved)\n" +
+		"  W911NF19A0002 " + childIDV + ": 1 orders, $500.00  (not saved)\n" +
+		"    W911NF20F0001 " + grandkid + ": $500  (not saved)\n"
+	if !strings.HasPrefix(out.String(), wantTree) {
+		t.Errorf("tree:\n%s\nwant:\n%s", out.String(), wantTree)
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.outputRoot, awardGraphFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var graph AwardGraph
+	if err := json.Unmarshal(data, &graph); err != nil {
+		t.Fatal(err)
+	}
+	if len(graph.Nodes) != 5 {
+		t.Errorf("graph has %d nodes, want 5", len(graph.Nodes))
+	}
+	for _, node := range graph.Nodes {
+		if node.ID == vehicle && (len(node.Children) != 3 || node.Campus != "UC_SANTA_BARBARA" || !node.Saved) {
+			t.Errorf("vehicle node = %+v", node)
+		}
+	}
+
+	dot, err := os.ReadFile(filepath.Join(s.outputRoot, awardGraphDOTFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		fmt.Sprintf("%q -> %q;", vehicle, order),
+		fmt.Sprintf("%q -> %q;", childIDV, grandkid),
+		fmt.Sprintf("%q [label=%q, shape=box];", vehicle, "W911NF19D0001\n3 orders, $244800.50\nUC_SANTA_BARBARA"),
+	} {
+		if !strings.Contains(string(dot), want) {
+			t.Errorf("DOT output is missing %s:\n%s", want, dot)
+		}
+	}
+}
+
+func TestIDVOrderRoundTripsSavedJSON(t *testing.T) {
+	// A saved order whose parent_award has a null
+	path := filepath.Join("testdata", "idv_order.json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	award, err := loadEnhancedAward(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := encodeIndented(t, award); got != string(data) {
+		t.Errorf("saved order does not round-trip:\n%s", got)
+	}
+
+	parent := award.DetailedData.Common().ParentAward
+	if parent.ID() != "CONT_IDV_AG6395B090006_12K3" || parent.MultipleOrSingleAwardDescription != nil || *parent.SubAgencyID != "12K3" {
+		t.Errorf("parent_award = %+v", parent)
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The layout award files are saved under unless the tree records another
+const defaultLayoutTemplate = "{group}/{campus}/{year}/{agency}/{id}.json"
+
+// File at the output root recording the layout of the award tree
+const layoutFile = "layout.json"
+
+// layoutFields are the placeholders a layout template may use.
+var layoutFields = map[string]string{
+	"group":       "award group directory, e.g. Grants",
+	"campus":      "canonical recipient entity, e.g. UC_DAVIS",
+	"recipient":   "recipient name as the API spells it",
+	"year":        "year bucket of the start date, or of the signing date, e.g. 2024",
+	"fiscal_year": "the same year bucket labelled, e.g. FY2024, UCFY2024 or CY2023",
+	"agency":      "awarding agency name",
+	"agency_code": "awarding toptier agency code, e.g. 075",
+	"id":          "generated_internal_id",
+}
+
+var placeholderPattern = regexp.MustCompile(`\{[^{}]*\}`)
+
+// Layout turns an award into its path under the output root.
+type Layout struct {
+	Template string        `json:"template"`
+	Years    YearBucketing `json:"years,omitempty"` // Bucketing of {year} and {fiscal_year}
+	Recorded string        `json:"recorded_at,omitempty"`
+}
+
+// ParseLayout checks a template. It must start with the {group} directory,
+// which every command walks, and name each file by its {id}.
+func ParseLayout(template string) (*Layout, error) {
+	template = filepath.ToSlash(strings.TrimSpace(template))
+	if !strings.HasPrefix(template, "{group}/") {
+		return nil, fmt.Errorf("layout %q must start with {group}/", template)
+	}
+	if !strings.HasSuffix(template, "/{id}.json") {
+		return nil, fmt.Errorf("layout %q must end with /{id}.json", template)
+	}
+	for _, segment := range strings.Split(template, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return nil, fmt.Errorf("layout %q has an empty or relative path segment", template)
+		}
+	}
+	for _, placeholder := range placeholderPattern.FindAllString(template, -1) {
+		if _, ok := layoutFields[strings.Trim(placeholder, "{}")]; !ok {
+			return nil, fmt.Errorf("layout %q: unknown placeholder %s (valid: %s)", template, placeholder, layoutFieldList())
+		}
+	}
+	if strings.ContainsAny(placeholderPattern.ReplaceAllString(template, ""), "{}") {
+		return nil, fmt.Errorf("layout %q has an unbalanced brace", template)
+	}
+	return &Layout{Template: template, Years: yearsCalendar}, nil
+}
+
+func layoutFieldList() string {
+	names := make([]string, 0, len(layoutFields))
+	for name := range layoutFields {
+		names = append(names, "{"+name+"}")
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// defaultLayout is the layout of a tree that has never been relaid out. It
+// keeps calendar years, which trees saved before bucketing used.
+func defaultLayout() *Layout {
+	return &Layout{Template: defaultLayoutTemplate, Years: yearsCalendar}
+}
+
+// readLayout returns the layout recorded at the output root, or the default
+// layout for a tree that has never been relaid out.
+func readLayout(outputRoot string) (*Layout, error) {
+	data, err := os.ReadFile(filepath.Join(outputRoot, layoutFile))
+	if os.IsNotExist(err) {
+		return defaultLayout(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", layoutFile, err)
+	}
+
+	var recorded Layout
+	if err := json.Unmarshal(data, &recorded); err != nil {
+		return nil, fmt.Errorf("error decoding %s: %w", layoutFile, err)
+	}
+	layout, err := ParseLayout(recorded.Template)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", layoutFile, err)
+	}
+	layout.Years, err = ParseYearBucketing(string(recorded.Years))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", layoutFile, err)
+	}
+	layout.Recorded = recorded.Recorded
+	return layout, nil
+}
+
+// targetLayout builds the layout relayout moves a tree to. Without years
+// it keeps the bucketing recorded in layout.json, so relaying out never
+// switches the years scrape and update save with unless asked to.
+func targetLayout(outputRoot, template, years string) (*Layout, error) {
+	layout, err := ParseLayout(template)
+	if err != nil {
+		return nil, err
+	}
+	if years == "" {
+		recorded, err := readLayout(outputRoot)
+		if err != nil {
+			return nil, err
+		}
+		layout.Years = recorded.Years
+		return layout, nil
+	}
+	if layout.Years, err = ParseYearBucketing(years); err != nil {
+		return nil, err
+	}
+	return layout, nil
+}
+
+// errTreeHasAwards stops a walk at the first saved award.
+var errTreeHasAwards = errors.New("tree has saved awards")
+
+// runLayout returns the layout scrape, enrich and update save with. years,
+// from -years, picks the bucketing of a tree that has no saved awards yet
+// and, with record, writes it to layout.json so later runs keep it. A tree
+// that already has awards keeps the bucketing they were saved with; only
+// relayout moves them to another.
+func runLayout(outputRoot, years string, record bool) (*Layout, error) {
+	layout, err := readLayout(outputRoot)
+	if err != nil || years == "" {
+		return layout, err
+	}
+	bucketing, err := ParseYearBucketing(years)
+	if err != nil {
+		return nil, err
+	}
+	if bucketing == layout.Years {
+		return layout, nil
+	}
+
+	saved := layout.Recorded != ""
+	for _, groupName := range awardTypeGroupOrder {
+		if saved {
+			break
+		}
+		err := walkEnhancedAwards(outputRoot, groupName, func(string, *EnhancedAward) error {
+			return errTreeHasAwards
+		})
+		if errors.Is(err, errTreeHasAwards) {
+			saved = true
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	if saved {
+		return nil, fmt.Errorf("-years %s: %s is saved with %s years; run relayout -years %s to change them", bucketing, outputRoot, layout.Years, bucketing)
+	}
+
+	layout.Years = bucketing
+	if record {
+		if err := ensureDirectoryExists(outputRoot); err != nil {
+			return nil, fmt.Errorf("error creating directory: %w", err)
+		}
+		if err := writeLayout(outputRoot, layout); err != nil {
+			return nil, fmt.Errorf("error writing %s: %w", layoutFile, err)
+		}
+	}
+	return layout, nil
+}
+
+func writeLayout(outputRoot string, layout *Layout) error {
+	record := Layout{Template: layout.Template, Years: layout.Years, Recorded: time.Now().Format(time.RFC3339)}
+	return writeJSONFile(filepath.Join(outputRoot, layoutFile), record)
+}
+
+// usesYear reports whether the template puts awards in year directories.
+func (l *Layout) usesYear() bool {
+	return strings.Contains(l.Template, "{year}") || strings.Contains(l.Template, "{fiscal_year}")
+}
+
+// awardPath fills in the template for an award. Every placeholder is
+// sanitized for use in a file name. It fails when the template needs a year
+// and the award has no usable date.
+func (l *Layout) awardPath(outputRoot, groupName string, enhancedAward *EnhancedAward, entity Entity) (string, error) {
+	values := layoutValues(groupName, enhancedAward, entity)
+	if l.usesYear() {
+		date, err := awardDate(enhancedAward)
+		if err != nil {
+			return "", err
+		}
+		year := l.Years.Year(date)
+		values["year"] = strconv.Itoa(year)
+		values["fiscal_year"] = l.Years.Label(year)
+	}
+	segments := strings.Split(l.Template, "/")
+	parts := make([]string, 0, len(segments)+1)
+	parts = append(parts, outputRoot)
+	for _, segment := range segments {
+		parts = append(parts, placeholderPattern.ReplaceAllStringFunc(segment, func(placeholder string) string {
+			return sanitizeFileName(values[strings.Trim(placeholder, "{}")])
+		}))
+	}
+	return filepath.Join(parts...), nil
+}
+
+func layoutValues(groupName string, enhancedAward *EnhancedAward, entity Entity) map[string]string {
+	award := enhancedAward.BasicData
+	var detailedData *DetailedAwardResponse
+	if enhancedAward.DetailedData != nil {
+		detailedData = enhancedAward.DetailedData.Common()
+	}
+
+	group := directoryMapping[groupName]
+	if group == "" {
+		group = "Other"
+	}
+
+	recipientName := award.RecipientName
+	if recipientName == "" {
+		recipientName = "Unknown_Recipient"
+	}
+
+	awardingAgency := award.AwardingAgency
+	if awardingAgency == "" && detailedData != nil {
+		awardingAgency = detailedData.AwardingAgency.ToptierAgency.Name
+	}
+	if awardingAgency == "" {
+		awardingAgency = "Unknown_Agency"
+	}
+	agencyCode := "unknown"
+	if detailedData != nil && detailedData.AwardingAgency.ToptierAgency.Code != "" {
+		agencyCode = detailedData.AwardingAgency.ToptierAgency.Code
+	}
+
+	return map[string]string{
+		"group":       group,
+		"campus":      entity.Key,
+		"recipient":   recipientName,
+		"agency":      awardingAgency,
+		"agency_code": agencyCode,
+		"id":          award.GeneratedInternalID,
+	}
+}
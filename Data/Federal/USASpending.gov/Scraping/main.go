@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -9,7 +10,10 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -17,6 +21,7 @@ import (
 type TimePeriod struct {
 	StartDate string `json:"start_date"`
 	EndDate   string `json:"end_date"`
+	DateType  string `json:"date_type,omitempty"` // e.g. "last_modified_date"; the API defaults to action date
 }
 
 type PlaceOfPerformance struct {
@@ -24,12 +29,21 @@ type PlaceOfPerformance struct {
 	State   string `json:"state"`
 }
 
+type AgencyFilter struct {
+	Type string `json:"type"` // "awarding" or "funding"
+	Tier string `json:"tier"` // "toptier" or "subtier"
+	Name string `json:"name"`
+}
+
 type Filters struct {
-	Keywords                    []string             `json:"keywords"`
-	TimePeriod                  []TimePeriod         `json:"time_period"`
-	AwardTypeCodes              []string             `json:"award_type_codes"`
-	RecipientTypeNames          []string             `json:"recipient_type_names"`
-	PlaceOfPerformanceLocations []PlaceOfPerformance `json:"place_of_performance_locations"`
+	Keywords                    []string             `json:"keywords,omitempty"`
+	TimePeriod                  []TimePeriod         `json:"time_period,omitempty"`
+	AwardTypeCodes              []string             `json:"award_type_codes,omitempty"`
+	RecipientTypeNames          []string             `json:"recipient_type_names,omitempty"`
+	PlaceOfPerformanceLocations []PlaceOfPerformance `json:"place_of_performance_locations,omitempty"`
+	RecipientSearchText         []string             `json:"recipient_search_text,omitempty"`
+	Agencies                    []AgencyFilter       `json:"agencies,omitempty"`
+	RecipientID                 string               `json:"recipient_id,omitempty"` // Recipient hash with its level, e.g. "<hash>-C"
 }
 
 type APIRequest struct {
@@ -41,6 +55,10 @@ type APIRequest struct {
 	AuditTrail    string   `json:"auditTrail"`
 	Fields        []string `json:"fields"`
 	SpendingLevel string   `json:"spending_level"`
+
+	// Keyset pagination cursor from the previous page's PageMetadata
+	LastRecordUniqueID  int    `json:"last_record_unique_id,omitempty"`
+	LastRecordSortValue string `json:"last_record_sort_value,omitempty"`
 }
 
 // API Response Structures
@@ -70,45 +88,45 @@ type Location struct {
 }
 
 type CodeDescription struct {
-	Code        string `json:"code"`
-	Description string `json:"description"`
+	Code        *string `json:"code"`
+	Description *string `json:"description"`
 }
 
 type Award struct {
-	InternalID                int         `json:"internal_id"`
-	AwardID                   string      `json:"Award ID"`
-	RecipientName             string      `json:"Recipient Name"`
-	AwardAmount               interface{} `json:"Award Amount"`
-	TotalOutlays              interface{} `json:"Total Outlays"`
-	Description               string      `json:"Description"`
-	ContractAwardType         string      `json:"Contract Award Type"`
-	RecipientUEI              string      `json:"Recipient UEI"`
-	RecipientLocation         interface{} `json:"Recipient Location"`           // Can be Location object or string fields
-	PrimaryPlaceOfPerformance interface{} `json:"Primary Place of Performance"` // Can be Location object or string fields
-	DefCodes                  []string    `json:"def_codes"`
-	COVID19Obligations        interface{} `json:"COVID-19 Obligations"`
-	COVID19Outlays            interface{} `json:"COVID-19 Outlays"`
-	InfrastructureObligations interface{} `json:"Infrastructure Obligations"`
-	InfrastructureOutlays     interface{} `json:"Infrastructure Outlays"`
-	AwardingAgency            string      `json:"Awarding Agency"`
-	AwardingSubAgency         string      `json:"Awarding Sub Agency"`
-	StartDate                 string      `json:"Start Date"`
-	EndDate                   string      `json:"End Date"`
-	NAICS                     interface{} `json:"NAICS"` // Can be CodeDescription or string
-	PSC                       interface{} `json:"PSC"`   // Can be CodeDescription or string
-	RecipientID               string      `json:"recipient_id"`
-	PrimeAwardRecipientID     string      `json:"prime_award_recipient_id"`
-	GeneratedInternalID       string      `json:"generated_internal_id"`
+	InternalID                int              `json:"internal_id"`
+	AwardID                   string           `json:"Award ID"`
+	RecipientName             string           `json:"Recipient Name"`
+	AwardAmount               Money            `json:"Award Amount"`
+	TotalOutlays              Money            `json:"Total Outlays"`
+	Description               string           `json:"Description"`
+	ContractAwardType         string           `json:"Contract Award Type"`
+	RecipientUEI              string           `json:"Recipient UEI"`
+	RecipientLocation         AwardLocation    `json:"Recipient Location"`
+	PrimaryPlaceOfPerformance AwardLocation    `json:"Primary Place of Performance"`
+	DefCodes                  []string         `json:"def_codes"`
+	COVID19Obligations        Money            `json:"COVID-19 Obligations"`
+	COVID19Outlays            Money            `json:"COVID-19 Outlays"`
+	InfrastructureObligations Money            `json:"Infrastructure Obligations"`
+	InfrastructureOutlays     Money            `json:"Infrastructure Outlays"`
+	AwardingAgency            string           `json:"Awarding Agency"`
+	AwardingSubAgency         string           `json:"Awarding Sub Agency"`
+	StartDate                 Date             `json:"Start Date"`
+	EndDate                   Date             `json:"End Date"`
+	NAICS                     *CodeDescription `json:"NAICS"` // Contracts only
+	PSC                       *CodeDescription `json:"PSC"`   // Contracts only
+	RecipientID               string           `json:"recipient_id"`
+	PrimeAwardRecipientID     string           `json:"prime_award_recipient_id"`
+	GeneratedInternalID       string           `json:"generated_internal_id"`
 
 	// Missing fields from search response
 	AwardingAgencyID int    `json:"awarding_agency_id"`
 	AgencySlug       string `json:"agency_slug"`
 
 	// Loan-specific fields
-	LoanValue     interface{} `json:"Loan Value"`
-	SubsidyCost   interface{} `json:"Subsidy Cost"`
-	IssuedDate    string      `json:"Issued Date"`
-	FundingAgency string      `json:"Funding Agency"`
+	LoanValue     Money  `json:"Loan Value"`
+	SubsidyCost   Money  `json:"Subsidy Cost"`
+	IssuedDate    Date   `json:"Issued Date"`
+	FundingAgency string `json:"Funding Agency"`
 
 	// Location fields for loans (separate from Location object)
 	RecipientLocationCityName     string `json:"recipient_location_city_name"`
@@ -118,6 +136,9 @@ type Award struct {
 	POPCityName                   string `json:"pop_city_name"`
 	POPStateCode                  string `json:"pop_state_code"`
 	POPCountryName                string `json:"pop_country_name"`
+
+	raw      json.RawMessage // Row as the API sent it
+	mismatch error           // Type mismatch tolerated while decoding
 }
 
 type APIResponse struct {
@@ -283,7 +304,7 @@ type DetailedAwardResponse struct {
 	TotalAccountObligation  float64             `json:"total_account_obligation"`
 	AccountOutlaysByDEFC    []AccountObligation `json:"account_outlays_by_defc"`
 	AccountObligationsByDEFC []AccountObligation `json:"account_obligations_by_defc"`
-	ParentAward             interface{}         `json:"parent_award"`
+	ParentAward             *ParentAward        `json:"parent_award"` // Set for orders and child IDVs
 	LatestTransactionContractData *ContractData `json:"latest_transaction_contract_data"`
 	FundingAgency           AgencyInfo          `json:"funding_agency"`
 	AwardingAgency          AgencyInfo          `json:"awarding_agency"`
@@ -294,27 +315,138 @@ type DetailedAwardResponse struct {
 	PSCHierarchy            PSCHierarchy        `json:"psc_hierarchy"`
 	NAICSHierarchy          NAICSHierarchy      `json:"naics_hierarchy"`
 	TotalOutlay             float64             `json:"total_outlay"`
+
+	raw      json.RawMessage // Response as the API sent it
+	mismatch error           // Type mismatch tolerated while decoding
 }
 
 // Combined structure that holds both basic and detailed award data
 type EnhancedAward struct {
-	BasicData    Award                 `json:"basic_data"`
-	DetailedData *DetailedAwardResponse `json:"detailed_data,omitempty"`
+	BasicData    Award         `json:"basic_data"`
+	DetailedData AwardDetail   `json:"detailed_data,omitempty"` // Variant chosen by the award's category
+	Raw          *RawPayload   `json:"raw,omitempty"`
+	Transactions []Transaction `json:"transactions,omitempty"` // Oldest first; fetched with -transactions
+	Subawards    []Subaward    `json:"subawards,omitempty"`    // Fetched with -subawards
+	Funding      []Funding     `json:"funding,omitempty"`      // Latest period first; fetched with -funding
+	IDVChildren  *IDVChildren  `json:"idv_children,omitempty"` // IDVs only
 }
 
 type Scraper struct {
-	client  *http.Client
-	baseURL string
-	delay   time.Duration
+	client               *http.Client
+	baseURL              string
+	countURL             string
+	awardsURL            string
+	transactionsURL      string
+	subawardsURL         string
+	fundingURL           string
+	idvAwardsURL         string
+	recipientURL         string
+	recipientCategoryURL string
+	bulkDownloadURL      string
+	downloadStatusURL    string
+	delay                time.Duration
+	retry                RetryPolicy
+	limiter              *RateLimiter
+	workers              int
+	checkpoint           *Checkpoint
+	outputRoot           string
+	dryRun               bool
+	out                  io.Writer
+	profile              *SearchProfile
+	summary              *runSummary
+	resolver             *RecipientResolver
+	quarantine           *quarantine
+	undated              *undatedAwards
+	layout               *Layout
+	transactions         bool // Fetch each award's transaction history
+	subawards            bool // Fetch the subawards of awards that report any
+	funding              bool // Fetch each award's federal account funding
 }
 
-func NewScraper() *Scraper {
+// savedFunc is called from the enrichment workers after each award file is
+// written.
+type savedFunc func(groupName string, award *EnhancedAward, path string)
+
+// ScraperOptions holds the run settings that come from the command line.
+type ScraperOptions struct {
+	OutputRoot   string             // Root directory that holds Contracts/, Grants/, ...
+	Delay        time.Duration      // Pause between API requests
+	DryRun       bool               // Print requests instead of sending them
+	Out          io.Writer          // Destination for dry-run output
+	Profile      *SearchProfile     // Search filters and per-group settings; defaults to uc-all
+	Retry        RetryPolicy        // Retry policy for every API call; zero value means DefaultRetryPolicy
+	Workers      int                // Concurrent detail fetches; at least 1
+	RateLimit    float64            // Requests per second across all workers; 0 disables the limiter
+	Burst        int                // Requests allowed at once before RateLimit applies
+	Checkpoint   *Checkpoint        // Progress record for resumable runs; nil disables it
+	Resolver     *RecipientResolver // Maps recipients to canonical UC entities; defaults to the built-in alias table
+	Layout       *Layout            // Path template for award files; defaults to defaultLayoutTemplate
+	Transactions bool               // Also fetch each award's transaction history
+	Subawards    bool               // Also fetch the subawards of awards that report any
+	Funding      bool               // Also fetch each award's federal account funding
+	APIBase      string             // Scheme and host the API paths are joined to; defaults to defaultAPIBase
+	Transport    http.RoundTripper  // Transport for every API call; nil uses http.DefaultTransport
+}
+
+func NewScraper(opts ScraperOptions) *Scraper {
+	if opts.OutputRoot == "" {
+		opts.OutputRoot = defaultOutputRoot
+	}
+	if opts.Out == nil {
+		opts.Out = os.Stdout
+	}
+	if opts.Profile == nil {
+		opts.Profile = defaultSearchProfile()
+	}
+	if opts.Retry.MaxAttempts == 0 {
+		opts.Retry = DefaultRetryPolicy()
+	}
+	if opts.Workers < 1 {
+		opts.Workers = 1
+	}
+	if opts.Resolver == nil {
+		opts.Resolver = defaultRecipientResolver()
+	}
+	if opts.Layout == nil {
+		opts.Layout = defaultLayout()
+	}
+	api := strings.TrimSuffix(opts.APIBase, "/")
+	if api == "" {
+		api = defaultAPIBase
+	}
 	return &Scraper{
 		client: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout:   30 * time.Second,
+			Transport: opts.Transport,
 		},
-		baseURL: "https://api.usaspending.gov/api/v2/search/spending_by_award/",
-		delay:   1 * time.Second, // Be respectful to the API
+		baseURL:              api + "/api/v2/search/spending_by_award/",
+		countURL:             api + "/api/v2/search/spending_by_award_count/",
+		awardsURL:            api + "/api/v2/awards/",
+		transactionsURL:      api + "/api/v2/transactions/",
+		subawardsURL:         api + "/api/v2/subawards/",
+		fundingURL:           api + "/api/v2/awards/funding/",
+		idvAwardsURL:         api + "/api/v2/idvs/awards/",
+		recipientURL:         api + "/api/v2/recipient/",
+		recipientCategoryURL: api + "/api/v2/search/spending_by_category/recipient/",
+		bulkDownloadURL:      api + "/api/v2/bulk_download/awards/",
+		downloadStatusURL:    api + "/api/v2/download/status/",
+		delay:                opts.Delay, // Be respectful to the API
+		retry:                opts.Retry,
+		limiter:              NewRateLimiter(opts.RateLimit, opts.Burst),
+		workers:              opts.Workers,
+		checkpoint:           opts.Checkpoint,
+		outputRoot:           opts.OutputRoot,
+		dryRun:               opts.DryRun,
+		out:                  opts.Out,
+		profile:              opts.Profile,
+		summary:              newRunSummary(),
+		resolver:             opts.Resolver,
+		quarantine:           newQuarantine(opts.OutputRoot),
+		undated:              newUndatedAwards(opts.OutputRoot),
+		layout:               opts.Layout,
+		transactions:         opts.Transactions,
+		subawards:            opts.Subawards,
+		funding:              opts.Funding,
 	}
 }
 
@@ -328,21 +460,40 @@ var awardTypeGroups = map[string][]string{
 	"direct_payments":            {"09", "11"},
 }
 
-// Directory mapping for each award type group
+// Stable processing order for the award type groups
+var awardTypeGroupOrder = []string{
+	"contracts",
+	"grants",
+	"loans",
+	"idvs",
+	"other_financial_assistance",
+	"direct_payments",
+}
+
+// Default output root, relative to the Scraping directory
+const defaultOutputRoot = ".."
+
+// The public API that every endpoint URL is built from
+const defaultAPIBase = "https://api.usaspending.gov"
+
+// Directory mapping for each award type group, relative to the output root
 var directoryMapping = map[string]string{
-	"contracts":                  "../Contracts",
-	"grants":                     "../Grants",
-	"loans":                      "../Loans",
-	"idvs":                       "../Contract_IDVs",
-	"other_financial_assistance": "../Other_Financial_Assistance",
-	"direct_payments":            "../Direct_Payments",
+	"contracts":                  "Contracts",
+	"grants":                     "Grants",
+	"loans":                      "Loans",
+	"idvs":                       "Contract_IDVs",
+	"other_financial_assistance": "Other_Financial_Assistance",
+	"direct_payments":            "Direct_Payments",
+}
+
+// GroupConfig is the sort field and requested columns for one award group.
+type GroupConfig struct {
+	SortField string   `json:"sort_field,omitempty"`
+	Fields    []string `json:"fields,omitempty"`
 }
 
 // Award type specific configurations
-var awardTypeConfigs = map[string]struct {
-	SortField string
-	Fields    []string
-}{
+var awardTypeConfigs = map[string]GroupConfig{
 	"contracts": {
 		SortField: "Award Amount",
 		Fields: []string{
@@ -403,34 +554,13 @@ var awardTypeConfigs = map[string]struct {
 }
 
 func (s *Scraper) createRequest(groupName string, awardTypeCodes []string) APIRequest {
-	config := awardTypeConfigs[groupName]
+	config := s.profile.groupConfig(groupName)
+
+	filters := s.profile.Filters
+	filters.AwardTypeCodes = awardTypeCodes
 
 	return APIRequest{
-		Filters: Filters{
-			Keywords: []string{"University of California"},
-			TimePeriod: []TimePeriod{
-				{
-					StartDate: "2007-10-01",
-					EndDate:   "2025-09-30",
-				},
-			},
-			AwardTypeCodes: awardTypeCodes,
-			RecipientTypeNames: []string{
-				"higher_education",
-				"public_institution_of_higher_education",
-				"private_institution_of_higher_education",
-				"minority_serving_institution_of_higher_education",
-				"school_of_forestry",
-				"veterinary_college",
-				"government",
-			},
-			PlaceOfPerformanceLocations: []PlaceOfPerformance{
-				{
-					Country: "USA",
-					State:   "CA",
-				},
-			},
-		},
+		Filters:       filters,
 		Page:          1,
 		Limit:         100,
 		Sort:          config.SortField,
@@ -442,132 +572,208 @@ func (s *Scraper) createRequest(groupName string, awardTypeCodes []string) APIRe
 }
 
 func (s *Scraper) makeRequest(ctx context.Context, request APIRequest) (*APIResponse, error) {
-	jsonData, err := json.Marshal(request)
-	if err != nil {
-		return nil, fmt.Errorf("error marshaling request: %w", err)
+	if s.dryRun {
+		return &APIResponse{}, s.printDryRunRequest(s.baseURL, request)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", s.baseURL, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("error creating request: %w", err)
+	var apiResponse APIResponse
+	if err := s.postJSON(ctx, s.baseURL, request, &apiResponse); err != nil {
+		return nil, err
+	}
+	for i := range apiResponse.Results {
+		warnTypeMismatch(apiResponse.Results[i].mismatch)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("User-Agent", "UC-Holdings-Scraper/1.0")
+	return &apiResponse, nil
+}
 
-	resp, err := s.client.Do(req)
+// postJSON sends body as JSON to url and decodes the response into result.
+func (s *Scraper) postJSON(ctx context.Context, url string, body interface{}, result interface{}) error {
+	jsonData, err := json.Marshal(body)
 	if err != nil {
-		return nil, fmt.Errorf("error making request: %w", err)
+		return fmt.Errorf("error marshaling request: %w", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
+	respBody, err := s.doWithRetry(ctx, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(jsonData))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "UC-Holdings-Scraper/1.0")
+		return req, nil
+	})
+	if err != nil {
+		return err
 	}
 
-	var apiResponse APIResponse
-	if err := json.NewDecoder(resp.Body).Decode(&apiResponse); err != nil {
-		return nil, fmt.Errorf("error decoding response: %w", err)
+	if err := json.Unmarshal(respBody, result); err != nil {
+		return fmt.Errorf("error decoding response: %w", err)
 	}
 
-	return &apiResponse, nil
+	return nil
 }
 
-func (s *Scraper) fetchDetailedAward(ctx context.Context, generatedInternalID string) (*DetailedAwardResponse, error) {
-	detailURL := fmt.Sprintf("https://api.usaspending.gov/api/v2/awards/%s/", generatedInternalID)
-	
-	req, err := http.NewRequestWithContext(ctx, "GET", detailURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("error creating detail request: %w", err)
-	}
+func (s *Scraper) fetchDetailedAward(ctx context.Context, generatedInternalID string) (AwardDetail, error) {
+	detailURL := s.awardsURL + generatedInternalID + "/"
 
-	req.Header.Set("User-Agent", "UC-Holdings-Scraper/1.0")
-	req.Header.Set("Accept", "application/json")
+	if s.dryRun {
+		fmt.Fprintf(s.out, "GET %s\n", detailURL)
+		return nil, nil
+	}
 
-	resp, err := s.client.Do(req)
+	respBody, err := s.get(ctx, detailURL)
 	if err != nil {
-		return nil, fmt.Errorf("error making detail request: %w", err)
+		return nil, fmt.Errorf("error fetching detail: %w", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("detail API returned status %d: %s", resp.StatusCode, string(body))
+	detail, err := decodeAwardDetail(respBody)
+	if err != nil {
+		return nil, err
 	}
+	warnTypeMismatch(detail.Common().mismatch)
+	return detail, nil
+}
 
-	var detailResponse DetailedAwardResponse
-	if err := json.NewDecoder(resp.Body).Decode(&detailResponse); err != nil {
-		return nil, fmt.Errorf("error decoding detail response: %w", err)
-	}
+// get sends a GET request with retries and returns the response body.
+func (s *Scraper) get(ctx context.Context, url string) ([]byte, error) {
+	return s.doWithRetry(ctx, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("User-Agent", "UC-Holdings-Scraper/1.0")
+		req.Header.Set("Accept", "application/json")
+		return req, nil
+	})
+}
 
-	return &detailResponse, nil
+// getJSON sends a GET request and decodes the response into result.
+func (s *Scraper) getJSON(ctx context.Context, url string, result interface{}) error {
+	respBody, err := s.get(ctx, url)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(respBody, result); err != nil {
+		return fmt.Errorf("error decoding response: %w", err)
+	}
+	return nil
 }
 
-func (s *Scraper) scrapeGroupData(ctx context.Context, groupName string, awardTypeCodes []string) ([]Award, error) {
-	var groupAwards []Award
-	page := 1
+// AwardListRequest is the body of the endpoints that list records of one
+// award page by page: transactions, subawards, awards/funding and
+// idvs/awards.
+type AwardListRequest struct {
+	AwardID string `json:"award_id"`
+	Type    string `json:"type,omitempty"` // idvs/awards only: child_awards, child_idvs or grandchild_awards
+	Page    int    `json:"page"`
+	Limit   int    `json:"limit"`
+	Sort    string `json:"sort"`
+	Order   string `json:"order"`
+}
 
-	log.Printf("Starting to scrape %s data (codes: %v)...", groupName, awardTypeCodes)
+// fetchAwardList pages through one of the award list endpoints from
+// request.Page on and returns every result.
+func fetchAwardList[T any](ctx context.Context, s *Scraper, url string, request AwardListRequest) ([]T, error) {
+	if s.dryRun {
+		return nil, s.printDryRunRequest(url, request)
+	}
 
+	var results []T
 	for {
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		default:
+		var response struct {
+			Results      []T          `json:"results"`
+			PageMetadata PageMetadata `json:"page_metadata"`
 		}
+		if err := s.postJSON(ctx, url, request, &response); err != nil {
+			return nil, fmt.Errorf("page %d: %w", request.Page, err)
+		}
+		for i := range response.Results {
+			if row, ok := interface{}(&response.Results[i]).(interface{ typeMismatch() error }); ok {
+				warnTypeMismatch(row.typeMismatch())
+			}
+		}
+		results = append(results, response.Results...)
+		if !response.PageMetadata.HasNext || len(response.Results) == 0 {
+			return results, nil
+		}
+		request.Page++
+	}
+}
 
-		log.Printf("[%s] Fetching page %d...", groupName, page)
-
-		request := s.createRequest(groupName, awardTypeCodes)
-		request.Page = page
+func (s *Scraper) scrapeGroupData(ctx context.Context, groupName string, awardTypeCodes []string) ([]Award, error) {
+	return s.scrapeGroupRequest(ctx, groupName, s.createRequest(groupName, awardTypeCodes))
+}
 
-		response, err := s.makeRequest(ctx, request)
-		if err != nil {
-			return nil, fmt.Errorf("error fetching %s page %d: %w", groupName, page, err)
-		}
+// scrapeGroupRequest is scrapeGroupData for a request whose filters differ
+// from the profile's, such as update's last_modified_date window.
+func (s *Scraper) scrapeGroupRequest(ctx context.Context, groupName string, request APIRequest) ([]Award, error) {
+	log.Printf("Starting to scrape %s data (codes: %v)...", groupName, request.Filters.AwardTypeCodes)
 
-		groupAwards = append(groupAwards, response.Results...)
+	collector := newAwardCollector(groupName)
 
-		log.Printf("[%s] Page %d: got %d awards, total so far: %d",
-			groupName, page, len(response.Results), len(groupAwards))
+	// Rows fetched by an interrupted run are picked up from the checkpoint
+	resumed, err := s.checkpoint.searchAwards(groupName)
+	if err != nil {
+		return nil, err
+	}
+	if len(resumed) > 0 {
+		collector.add(resumed)
+		log.Printf("[%s] Loaded %d awards from checkpoint", groupName, len(collector.awards))
+	}
+	if s.checkpoint.searchComplete(groupName) {
+		log.Printf("[%s] Search already complete in checkpoint", groupName)
+		return collector.awards, nil
+	}
 
-		// Check if there are more pages
-		if !response.PageMetadata.HasNext || len(response.Results) == 0 {
-			log.Printf("[%s] No more pages. Total awards collected: %d", groupName, len(groupAwards))
-			break
+	if len(request.Filters.TimePeriod) == 0 {
+		// Without a time period there is nothing to split on
+		if err := s.scrapeWindow(ctx, request, collector); err != nil {
+			return nil, err
+		}
+	}
+	for _, period := range request.Filters.TimePeriod {
+		windowRequest := request
+		windowRequest.Filters.TimePeriod = []TimePeriod{period}
+		if err := s.scrapeWindow(ctx, windowRequest, collector); err != nil {
+			return nil, err
 		}
+	}
 
-		page++
+	collector.logShortfalls()
+	log.Printf("[%s] No more pages. Total awards collected: %d", groupName, len(collector.awards))
 
-		// Be respectful to the API
-		time.Sleep(s.delay)
+	if err := s.checkpoint.markSearchComplete(groupName); err != nil {
+		return nil, fmt.Errorf("error saving checkpoint: %w", err)
 	}
-
-	return groupAwards, nil
+	return collector.awards, nil
 }
 
-func (s *Scraper) scrapeAndSaveAllData(ctx context.Context) (int, error) {
+func (s *Scraper) scrapeAndSaveAllData(ctx context.Context, groups []string) (int, error) {
 	totalAwards := 0
 	timestamp := time.Now().Format("2006-01-02")
 
-	log.Printf("Starting to scrape University of California data for all award types...")
+	log.Printf("Starting to scrape University of California data for award groups %v...", groups)
 
-	for groupName, awardTypeCodes := range awardTypeGroups {
-		groupAwards, err := s.scrapeGroupData(ctx, groupName, awardTypeCodes)
+	for _, groupName := range groups {
+		groupAwards, err := s.scrapeGroupData(ctx, groupName, awardTypeGroups[groupName])
 		if err != nil {
 			return 0, fmt.Errorf("error scraping %s: %w", groupName, err)
 		}
 
 		// Save each group to its respective directory
-		if len(groupAwards) > 0 {
-			directory := directoryMapping[groupName]
-			filename := fmt.Sprintf("%s/uc_%s_%s.json", directory, groupName, timestamp)
+		if len(groupAwards) > 0 && !s.dryRun {
+			directory := groupDirectory(s.outputRoot, groupName)
+			if err := ensureDirectoryExists(directory); err != nil {
+				return 0, fmt.Errorf("error creating directory: %w", err)
+			}
+			filename := filepath.Join(directory, fmt.Sprintf("%s%s_%s.json", groupDumpPrefix, groupName, timestamp))
 
 			if err := saveToJSON(groupAwards, filename); err != nil {
 				return 0, fmt.Errorf("error saving %s data: %w", groupName, err)
 			}
 
+			s.summary.recordDump(filename)
 			log.Printf("Saved %s data to %s", groupName, filename)
 		}
 
@@ -575,106 +781,334 @@ func (s *Scraper) scrapeAndSaveAllData(ctx context.Context) (int, error) {
 		log.Printf("Completed %s: %d awards. Running total: %d", groupName, len(groupAwards), totalAwards)
 
 		// Small delay between groups
-		time.Sleep(s.delay)
+		if err := sleepContext(ctx, s.delay); err != nil {
+			return totalAwards, err
+		}
 	}
 
 	log.Printf("Completed all award types. Total awards collected: %d", totalAwards)
 	return totalAwards, nil
 }
 
-func (s *Scraper) scrapeAndSaveEnhancedData(ctx context.Context) (int, error) {
+func (s *Scraper) scrapeAndSaveEnhancedData(ctx context.Context, groups []string) (int, error) {
 	totalAwards := 0
 	totalEnhanced := 0
 
 	log.Printf("Starting enhanced scraping: collecting basic data and detailed information...")
 
-	for groupName, awardTypeCodes := range awardTypeGroups {
+	for _, groupName := range groups {
+		if s.checkpoint.enrichComplete(groupName) {
+			log.Printf("Skipping %s: already complete in checkpoint", groupName)
+			continue
+		}
+
 		log.Printf("Processing %s awards...", groupName)
-		
+
 		// Step 1: Collect basic award data
-		groupAwards, err := s.scrapeGroupData(ctx, groupName, awardTypeCodes)
+		groupAwards, err := s.scrapeGroupData(ctx, groupName, awardTypeGroups[groupName])
 		if err != nil {
 			return 0, fmt.Errorf("error scraping %s: %w", groupName, err)
 		}
-		
+
 		totalAwards += len(groupAwards)
 		log.Printf("Collected %d basic %s awards. Now fetching detailed data...", len(groupAwards), groupName)
 
 		// Step 2: Fetch detailed data and save organized files
-		for i, award := range groupAwards {
-			select {
-			case <-ctx.Done():
-				return totalEnhanced, ctx.Err()
-			default:
-			}
+		saved, err := s.enrichGroupAwards(ctx, groupName, groupAwards)
+		totalEnhanced += saved
+		if err != nil {
+			return totalEnhanced, err
+		}
 
-			if award.GeneratedInternalID == "" {
-				log.Printf("Skipping award %d/%d in %s: missing generated_internal_id", i+1, len(groupAwards), groupName)
-				continue
-			}
+		// Small delay between groups
+		if err := sleepContext(ctx, s.delay); err != nil {
+			return totalEnhanced, err
+		}
+	}
+
+	log.Printf("Enhanced scraping completed!")
+	log.Printf("Total basic awards collected: %d", totalAwards)
+	log.Printf("Total enhanced awards saved: %d", totalEnhanced)
+	return totalEnhanced, nil
+}
+
+// enrichSavedData fetches details for the awards in each group's most recent
+// search dump (written by the search command) and saves the organized files.
+func (s *Scraper) enrichSavedData(ctx context.Context, groups []string) (int, error) {
+	totalEnhanced := 0
+
+	for _, groupName := range groups {
+		if s.checkpoint.enrichComplete(groupName) {
+			log.Printf("Skipping %s: already complete in checkpoint", groupName)
+			continue
+		}
+
+		dumpPath, err := latestGroupDump(s.outputRoot, groupName)
+		if err != nil {
+			return totalEnhanced, err
+		}
+		if dumpPath == "" {
+			log.Printf("No saved search results for %s in %s, skipping", groupName, groupDirectory(s.outputRoot, groupName))
+			continue
+		}
+
+		groupAwards, err := loadGroupDump(dumpPath)
+		if err != nil {
+			return totalEnhanced, err
+		}
+		log.Printf("Loaded %d %s awards from %s. Now fetching detailed data...", len(groupAwards), groupName, dumpPath)
+
+		saved, err := s.enrichGroupAwards(ctx, groupName, groupAwards)
+		totalEnhanced += saved
+		if err != nil {
+			return totalEnhanced, err
+		}
+	}
+
+	log.Printf("Total enhanced awards saved: %d", totalEnhanced)
+	return totalEnhanced, nil
+}
 
-			log.Printf("Fetching details for award %d/%d in %s: %s", i+1, len(groupAwards), groupName, award.GeneratedInternalID)
+// enrichGroupAwards fetches detailed data for each award and writes one
+// EnhancedAward file per award into the hierarchical layout. Awards are
+// handed to s.workers goroutines over a channel; every request they make
+// goes through the shared rate limiter.
+func (s *Scraper) enrichGroupAwards(ctx context.Context, groupName string, groupAwards []Award) (int, error) {
+	return s.enrichGroupAwardsFunc(ctx, groupName, groupAwards, nil)
+}
+
+// enrichGroupAwardsFunc is enrichGroupAwards calling onSaved, when not nil,
+// after each award file is written.
+func (s *Scraper) enrichGroupAwardsFunc(ctx context.Context, groupName string, groupAwards []Award, onSaved savedFunc) (int, error) {
+	type job struct {
+		index int
+		award Award
+	}
 
-			// Fetch detailed award data
-			detailedData, err := s.fetchDetailedAward(ctx, award.GeneratedInternalID)
-			if err != nil {
-				log.Printf("Warning: Failed to fetch details for %s: %v", award.GeneratedInternalID, err)
-				// Continue with basic data only
-				detailedData = nil
+	jobs := make(chan job)
+	var totalEnhanced int64
+	var wg sync.WaitGroup
+
+	for w := 0; w < s.workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				log.Printf("Fetching details for award %d/%d in %s: %s", j.index+1, len(groupAwards), groupName, j.award.GeneratedInternalID)
+				if s.enrichAward(ctx, groupName, j.award, onSaved) {
+					atomic.AddInt64(&totalEnhanced, 1)
+				}
 			}
+		}()
+	}
+
+	alreadyEnriched := 0
+
+feed:
+	for i, award := range groupAwards {
+		if award.GeneratedInternalID == "" {
+			log.Printf("Skipping award %d/%d in %s: missing generated_internal_id", i+1, len(groupAwards), groupName)
+			continue
+		}
+		if s.checkpoint.isEnriched(groupName, award.GeneratedInternalID) {
+			alreadyEnriched++
+			continue
+		}
+
+		select {
+		case jobs <- job{i, award}:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
 
-			// Create enhanced award structure
-			enhancedAward := EnhancedAward{
-				BasicData:    award,
-				DetailedData: detailedData,
+	saved := int(atomic.LoadInt64(&totalEnhanced))
+	if err := ctx.Err(); err != nil {
+		return saved, err
+	}
+
+	if alreadyEnriched > 0 {
+		log.Printf("Skipped %d %s awards already enriched in checkpoint", alreadyEnriched, groupName)
+	}
+	log.Printf("Completed %s: saved %d enhanced awards", groupName, saved)
+
+	// Awards whose detail or related records failed to fetch were not marked
+	// enriched; the group stays open so -resume retries them
+	if s.checkpoint != nil {
+		pending := 0
+		for _, award := range groupAwards {
+			if award.GeneratedInternalID != "" && !s.checkpoint.isEnriched(groupName, award.GeneratedInternalID) {
+				pending++
 			}
+		}
+		if pending > 0 {
+			log.Printf("Leaving %s open in checkpoint: %d awards are still missing detail", groupName, pending)
+			s.checkpoint.markEnrichPending()
+			return saved, nil
+		}
+	}
+
+	if err := s.checkpoint.markEnrichComplete(groupName); err != nil {
+		return saved, fmt.Errorf("error saving checkpoint: %w", err)
+	}
+	return saved, nil
+}
+
+// enrichAward fetches one award's detail and saves the combined record. It
+// reports whether a file was written.
+func (s *Scraper) enrichAward(ctx context.Context, groupName string, award Award, onSaved savedFunc) bool {
+	// Fetch detailed award data
+	detailedData, err := s.fetchDetailedAward(ctx, award.GeneratedInternalID)
+	if err != nil {
+		if ctx.Err() != nil {
+			return false
+		}
+		log.Printf("Warning: Failed to fetch details for %s: %v", award.GeneratedInternalID, err)
+		// Continue with basic data only
+		detailedData = nil
+	}
 
-			// Determine file organization
-			year := extractYearFromDate(award.StartDate)
-			if year == "unknown" && detailedData != nil {
-				year = extractYearFromDate(detailedData.DateSigned)
+	complete := detailedData != nil
+
+	// Fetch the transaction history when asked for
+	var transactions []Transaction
+	if s.transactions {
+		transactions, err = s.fetchTransactions(ctx, award.GeneratedInternalID)
+		if err != nil {
+			if ctx.Err() != nil {
+				return false
 			}
+			log.Printf("Warning: Failed to fetch transactions for %s: %v", award.GeneratedInternalID, err)
+			complete = false
+		}
+	}
 
-			recipientName := award.RecipientName
-			if recipientName == "" {
-				recipientName = "Unknown_Recipient"
+	// Fetch subawards when asked for and the detail reports any
+	var subawards []Subaward
+	if s.subawards && detailedData != nil && detailedData.Common().SubawardCount > 0 {
+		subawards, err = s.fetchSubawards(ctx, award.GeneratedInternalID)
+		if err != nil {
+			if ctx.Err() != nil {
+				return false
 			}
+			log.Printf("Warning: Failed to fetch subawards for %s: %v", award.GeneratedInternalID, err)
+			complete = false
+		}
+	}
 
-			awardingAgency := award.AwardingAgency
-			if awardingAgency == "" && detailedData != nil {
-				awardingAgency = detailedData.AwardingAgency.ToptierAgency.Name
+	// Fetch the federal account funding when asked for
+	var funding []Funding
+	if s.funding {
+		funding, err = s.fetchFunding(ctx, award.GeneratedInternalID)
+		if err != nil {
+			if ctx.Err() != nil {
+				return false
 			}
-			if awardingAgency == "" {
-				awardingAgency = "Unknown_Agency"
+			log.Printf("Warning: Failed to fetch funding for %s: %v", award.GeneratedInternalID, err)
+			complete = false
+		}
+	}
+
+	// Fetch what was ordered under an IDV
+	var children *IDVChildren
+	if _, ok := detailedData.(*IDVAwardDetail); ok {
+		children, err = s.fetchIDVChildren(ctx, award.GeneratedInternalID)
+		if err != nil {
+			if ctx.Err() != nil {
+				return false
 			}
+			log.Printf("Warning: Failed to fetch IDV children for %s: %v", award.GeneratedInternalID, err)
+			complete = false
+		}
+	}
+	if s.dryRun {
+		return false
+	}
 
-			// Create organized file path
-			dirPath := createDirectoryPath(groupName, recipientName, year, awardingAgency)
-			fileName := fmt.Sprintf("%s.json", sanitizeFileName(award.GeneratedInternalID))
-			filePath := fmt.Sprintf("%s/%s", dirPath, fileName)
+	// Create enhanced award structure
+	enhancedAward := EnhancedAward{
+		BasicData:    award,
+		DetailedData: detailedData,
+		Transactions: transactions,
+		Subawards:    subawards,
+		Funding:      funding,
+		IDVChildren:  children,
+	}
+	enhancedAward.Raw = newRawPayload(&enhancedAward)
+
+	// Awards the keyword search matched but that are not UC stay out of the tree
+	resolution := s.resolver.Resolve(&enhancedAward)
+	if !resolution.Entity.IsUC() {
+		log.Printf("Quarantining %s (%s): %s", award.GeneratedInternalID, award.RecipientName, resolution.Reason)
+		if err := s.quarantine.add(newQuarantinedAward(groupName, &enhancedAward, resolution)); err != nil {
+			log.Printf("Error recording quarantined award %s: %v", award.GeneratedInternalID, err)
+			s.summary.recordFailed()
+			return false
+		}
+		s.summary.recordQuarantined(groupName)
+		if detailedData != nil {
+			if err := s.checkpoint.markEnriched(groupName, award.GeneratedInternalID); err != nil {
+				log.Printf("Warning: could not record %s in checkpoint: %v", award.GeneratedInternalID, err)
+			}
+		}
+		return false
+	}
 
-			// Save enhanced award data
-			if err := saveEnhancedAwardToJSON(enhancedAward, filePath); err != nil {
-				log.Printf("Error saving award %s: %v", award.GeneratedInternalID, err)
-				continue
+	// Awards without a usable date are reported instead of guessing a year
+	filePath, err := s.layout.awardPath(s.outputRoot, groupName, &enhancedAward, resolution.Entity)
+	if err != nil {
+		log.Printf("Leaving out %s: %v", award.GeneratedInternalID, err)
+		if err := s.undated.add(newUndatedAward(groupName, &enhancedAward, err)); err != nil {
+			log.Printf("Error recording undated award %s: %v", award.GeneratedInternalID, err)
+			s.summary.recordFailed()
+			return false
+		}
+		s.summary.recordUndated(groupName)
+		if detailedData != nil {
+			if err := s.checkpoint.markEnriched(groupName, award.GeneratedInternalID); err != nil {
+				log.Printf("Warning: could not record %s in checkpoint: %v", award.GeneratedInternalID, err)
 			}
+		}
+		return false
+	}
 
-			totalEnhanced++
-			
-			// Rate limiting between detail requests
-			time.Sleep(s.delay)
+	// Never replace a saved record with a less complete one
+	if !complete {
+		if _, err := os.Stat(filePath); err == nil {
+			log.Printf("Keeping existing file for %s since part of it could not be fetched", award.GeneratedInternalID)
+			return false
 		}
+	}
 
-		log.Printf("Completed %s: saved %d enhanced awards", groupName, len(groupAwards))
+	// Save enhanced award data
+	if err := saveEnhancedAwardToJSON(enhancedAward, filePath); err != nil {
+		log.Printf("Error saving award %s: %v", award.GeneratedInternalID, err)
+		s.summary.recordFailed()
+		return false
+	}
+	s.summary.recordSaved(groupName, detailedData != nil)
 
-		// Small delay between groups
-		time.Sleep(s.delay)
+	// Awards saved with anything missing are retried on resume
+	if complete {
+		if err := s.checkpoint.markEnriched(groupName, award.GeneratedInternalID); err != nil {
+			log.Printf("Warning: could not record %s in checkpoint: %v", award.GeneratedInternalID, err)
+		}
 	}
 
-	log.Printf("Enhanced scraping completed!")
-	log.Printf("Total basic awards collected: %d", totalAwards)
-	log.Printf("Total enhanced awards saved: %d", totalEnhanced)
-	return totalEnhanced, nil
+	if onSaved != nil {
+		onSaved(groupName, &enhancedAward, filePath)
+	}
+
+	return true
+}
+
+// enhancedAwardPath builds the path of an award in the default layout,
+// [Type]/[Entity]/[Year]/[Agency]/[Award ID].json, where Entity is the
+// canonical key of the recipient, such as UC_DAVIS.
+func enhancedAwardPath(outputRoot, groupName string, enhancedAward EnhancedAward, entity Entity) (string, error) {
+	return defaultLayout().awardPath(outputRoot, groupName, &enhancedAward, entity)
 }
 
 // Utility functions for directory and file organization
@@ -693,86 +1127,100 @@ func sanitizeFileName(name string) string {
 	return sanitized
 }
 
-func extractYearFromDate(dateStr string) string {
-	if dateStr == "" {
-		return "unknown"
-	}
-	// Handle various date formats
-	if len(dateStr) >= 4 {
-		return dateStr[:4]
-	}
-	return "unknown"
-}
-
-func createDirectoryPath(groupName, recipientName, year, awardingAgency string) string {
-	baseDir := directoryMapping[groupName]
-	if baseDir == "" {
-		baseDir = "../Other"
+func groupDirectory(outputRoot, groupName string) string {
+	dir := directoryMapping[groupName]
+	if dir == "" {
+		dir = "Other"
 	}
-	
-	sanitizedRecipient := sanitizeFileName(recipientName)
-	sanitizedAgency := sanitizeFileName(awardingAgency)
-	
-	return fmt.Sprintf("%s/%s/%s/%s", baseDir, sanitizedRecipient, year, sanitizedAgency)
+	return filepath.Join(outputRoot, dir)
 }
 
 func ensureDirectoryExists(dirPath string) error {
 	return os.MkdirAll(dirPath, 0755)
 }
 
-func saveEnhancedAwardToJSON(award EnhancedAward, filepath string) error {
-	if err := ensureDirectoryExists(filepath[:strings.LastIndex(filepath, "/")]); err != nil {
+func saveEnhancedAwardToJSON(award EnhancedAward, filePath string) error {
+	if err := ensureDirectoryExists(filepath.Dir(filePath)); err != nil {
 		return fmt.Errorf("error creating directory: %w", err)
 	}
 
-	file, err := os.Create(filepath)
-	if err != nil {
-		return fmt.Errorf("error creating file: %w", err)
-	}
-	defer file.Close()
+	return writeJSONFile(filePath, award)
+}
 
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(award); err != nil {
+// saveToJSON writes a search dump. Rows are written as the API sent them so
+// the dump loses no fields.
+func saveToJSON(data []Award, filename string) error {
+	rows, err := rawRows(data)
+	if err != nil {
 		return fmt.Errorf("error encoding JSON: %w", err)
 	}
+	return writeJSONFile(filename, rows)
+}
 
-	return nil
+// writeJSONFile writes v as indented JSON through writeFileAtomic.
+func writeJSONFile(filename string, v interface{}) error {
+	return writeFileAtomic(filename, func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(v); err != nil {
+			return fmt.Errorf("error encoding JSON: %w", err)
+		}
+		return nil
+	})
 }
 
-func saveToJSON(data []Award, filename string) error {
-	file, err := os.Create(filename)
+// writeFileAtomic writes through a temp file in the same directory, which is
+// synced and then renamed over filename. A crash or a full disk leaves either
+// the old file or the new one, never a truncated one.
+func writeFileAtomic(filename string, write func(w io.Writer) error) error {
+	dir := filepath.Dir(filename)
+	file, err := os.CreateTemp(dir, "."+filepath.Base(filename)+".*"+tempFileSuffix)
 	if err != nil {
 		return fmt.Errorf("error creating file: %w", err)
 	}
-	defer file.Close()
+	tmpName := file.Name()
+	committed := false
+	defer func() {
+		if !committed {
+			file.Close()
+			os.Remove(tmpName)
+		}
+	}()
 
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(data); err != nil {
-		return fmt.Errorf("error encoding JSON: %w", err)
+	writer := bufio.NewWriter(file)
+	if err := write(writer); err != nil {
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("error writing file: %w", err)
+	}
+	if err := file.Chmod(0644); err != nil {
+		return fmt.Errorf("error writing file: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("error syncing file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("error writing file: %w", err)
 	}
+	if err := os.Rename(tmpName, filename); err != nil {
+		return fmt.Errorf("error renaming file: %w", err)
+	}
+	committed = true
 
+	// Persist the rename itself
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
 	return nil
 }
 
 func main() {
-	ctx := context.Background()
-
-	scraper := NewScraper()
-
-	log.Printf("Starting USASpending.gov Enhanced Scraper")
-	log.Printf("This will collect basic award data and detailed information for each award")
-	log.Printf("Awards will be organized by: [Award Type]/[Recipient]/[Year]/[Agency]/[Award ID].json")
-	
-	totalAwards, err := scraper.scrapeAndSaveEnhancedData(ctx)
-	if err != nil {
-		log.Fatalf("Error scraping enhanced data: %v", err)
-	}
+	ctx, stop := signalContext()
+	defer stop()
 
-	log.Printf("Successfully scraped and saved %d enhanced awards", totalAwards)
-	log.Printf("Data organized in hierarchical directory structure:")
-	for groupName, directory := range directoryMapping {
-		log.Printf("  %s -> %s/[Recipient]/[Year]/[Agency]/", groupName, directory)
+	if err := run(ctx, os.Args[1:]); err != nil {
+		log.Fatalf("Error: %v", err)
 	}
 }
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+)
+
+// The search endpoint refuses page-number requests past this many records.
+// Keyset cursors are not subject to the cap.
+const searchResultWindow = 10000
+
+var errResultWindowExceeded = errors.New("result window exceeded")
+
+type CountRequest struct {
+	Filters       Filters `json:"filters"`
+	SpendingLevel string  `json:"spending_level"`
+}
+
+type CountResponse struct {
+	Results map[string]int `json:"results"`
+}
+
+// awardCollector accumulates one group's awards across time windows,
+// dropping awards that show up in more than one window.
+type awardCollector struct {
+	groupName  string
+	awards     []Award
+	seen       map[string]bool
+	shortfalls []string
+}
+
+func newAwardCollector(groupName string) *awardCollector {
+	return &awardCollector{
+		groupName: groupName,
+		seen:      make(map[string]bool),
+	}
+}
+
+// add appends awards not seen before and returns how many were new.
+func (c *awardCollector) add(awards []Award) int {
+	added := 0
+	for _, award := range awards {
+		key := award.GeneratedInternalID
+		if key == "" {
+			key = strconv.Itoa(award.InternalID)
+		}
+		if c.seen[key] {
+			continue
+		}
+		c.seen[key] = true
+		c.awards = append(c.awards, award)
+		added++
+	}
+	return added
+}
+
+func (c *awardCollector) recordShortfall(period string, fetched, expected int) {
+	message := fmt.Sprintf("%s: fetched %d of %d awards", period, fetched, expected)
+	if expected < 0 {
+		message = fmt.Sprintf("%s: fetched %d awards, total unknown", period, fetched)
+	}
+	c.shortfalls = append(c.shortfalls, message)
+	log.Printf("[%s] Warning: could not reach all awards for %s", c.groupName, message)
+}
+
+func (c *awardCollector) logShortfalls() {
+	if len(c.shortfalls) == 0 {
+		return
+	}
+	log.Printf("[%s] Warning: %d time windows came back incomplete:", c.groupName, len(c.shortfalls))
+	for _, message := range c.shortfalls {
+		log.Printf("[%s]   %s", c.groupName, message)
+	}
+}
+
+// fetchAwardCount asks the count endpoint how many awards match the filters.
+// The endpoint buckets award type codes under its own keys, which do not
+// line up with the group names (06/10 count as direct_payments, 09/11 as
+// other), so every bucket is summed; the filter already limits the codes to
+// one group. It returns -1 when the count is unavailable.
+func (s *Scraper) fetchAwardCount(ctx context.Context, filters Filters) (int, error) {
+	request := CountRequest{
+		Filters:       filters,
+		SpendingLevel: "awards",
+	}
+
+	if s.dryRun {
+		return -1, s.printDryRunRequest(s.countURL, request)
+	}
+
+	var response CountResponse
+	if err := s.postJSON(ctx, s.countURL, request, &response); err != nil {
+		return -1, err
+	}
+
+	if len(response.Results) == 0 {
+		return -1, nil
+	}
+	count := 0
+	for _, n := range response.Results {
+		count += n
+	}
+	return count, nil
+}
+
+// scrapeWindow collects every award matching request, which carries at most
+// one time period. When the window holds more than searchResultWindow awards
+// and the API hands out no keyset cursor, the period is split in half and
+// each half is scraped on its own.
+func (s *Scraper) scrapeWindow(ctx context.Context, request APIRequest, c *awardCollector) error {
+	label := "all dates"
+	var period *TimePeriod
+	if len(request.Filters.TimePeriod) == 1 {
+		period = &request.Filters.TimePeriod[0]
+		label = period.StartDate + ".." + period.EndDate
+	}
+
+	groupName := c.groupName
+	if cursor := s.checkpoint.windowCursor(groupName, label); cursor != nil && cursor.Done {
+		log.Printf("[%s] %s already complete in checkpoint, skipping", groupName, label)
+		return nil
+	}
+
+	count, err := s.fetchAwardCount(ctx, request.Filters)
+	if err != nil {
+		log.Printf("[%s] Warning: could not count awards for %s: %v", groupName, label, err)
+		count = -1
+	}
+	if count >= 0 {
+		log.Printf("[%s] %s: %d matching awards", groupName, label, count)
+	}
+
+	fetched, err := s.pageWindow(ctx, request, label, count, c)
+	if errors.Is(err, errResultWindowExceeded) {
+		if halves, ok := splitTimePeriod(period); ok {
+			log.Printf("[%s] %s exceeds the %d-record result window; splitting into %s..%s and %s..%s",
+				groupName, label, searchResultWindow,
+				halves[0].StartDate, halves[0].EndDate, halves[1].StartDate, halves[1].EndDate)
+			for _, half := range halves {
+				halfRequest := request
+				halfRequest.Filters.TimePeriod = []TimePeriod{half}
+				if err := s.scrapeWindow(ctx, halfRequest, c); err != nil {
+					return err
+				}
+			}
+			return s.checkpoint.markWindowDone(groupName, label)
+		}
+		c.recordShortfall(label, fetched, count)
+		return s.checkpoint.markWindowDone(groupName, label)
+	}
+	if err != nil {
+		return err
+	}
+
+	if count > fetched {
+		c.recordShortfall(label, fetched, count)
+	}
+	return s.checkpoint.markWindowDone(groupName, label)
+}
+
+// pageWindow walks the pages of one window, switching to keyset cursors as
+// soon as the API returns them. It returns errResultWindowExceeded when the
+// next page would have to go past searchResultWindow by page number. Each
+// page is logged to the checkpoint, and a window with a saved cursor resumes
+// after its last page.
+func (s *Scraper) pageWindow(ctx context.Context, request APIRequest, label string, count int, c *awardCollector) (int, error) {
+	groupName := c.groupName
+	fetched := 0
+	page := 1
+
+	if cursor := s.checkpoint.windowCursor(groupName, label); cursor != nil && cursor.Page > 0 {
+		fetched = cursor.Fetched
+		page = cursor.Page + 1
+		request.LastRecordUniqueID = cursor.LastRecordUniqueID
+		request.LastRecordSortValue = cursor.LastRecordSortValue
+		log.Printf("[%s] Resuming %s at page %d (%d awards already fetched)", groupName, label, page, fetched)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return fetched, ctx.Err()
+		default:
+		}
+
+		log.Printf("[%s] Fetching page %d...", groupName, page)
+
+		request.Page = page
+		response, err := s.makeRequest(ctx, request)
+		if err != nil {
+			return fetched, fmt.Errorf("error fetching %s page %d: %w", groupName, page, err)
+		}
+
+		fetched += len(response.Results)
+		c.add(response.Results)
+
+		metadata := response.PageMetadata
+		request.LastRecordUniqueID = metadata.LastRecordUniqueID
+		request.LastRecordSortValue = metadata.LastRecordSortValue
+
+		cursor := WindowCursor{
+			Page:                page,
+			Fetched:             fetched,
+			LastRecordUniqueID:  request.LastRecordUniqueID,
+			LastRecordSortValue: request.LastRecordSortValue,
+		}
+		if err := s.checkpoint.recordPage(groupName, label, cursor, response.Results); err != nil {
+			return fetched, fmt.Errorf("error saving checkpoint: %w", err)
+		}
+
+		log.Printf("[%s] Page %d: got %d awards, total so far: %d",
+			groupName, page, len(response.Results), len(c.awards))
+
+		// Check if there are more pages
+		if !metadata.HasNext || len(response.Results) == 0 {
+			return fetched, nil
+		}
+
+		if metadata.LastRecordUniqueID == 0 && (count > searchResultWindow || (page+1)*request.Limit > searchResultWindow) {
+			// No cursor, and page numbers alone cannot reach the end
+			return fetched, errResultWindowExceeded
+		}
+
+		page++
+
+		// Be respectful to the API
+		if err := sleepContext(ctx, s.delay); err != nil {
+			return fetched, err
+		}
+	}
+}
+
+// splitTimePeriod halves a period by date. It reports false for a nil or
+// single-day period, which cannot be split further.
+func splitTimePeriod(period *TimePeriod) ([2]TimePeriod, bool) {
+	var halves [2]TimePeriod
+	if period == nil {
+		return halves, false
+	}
+
+	start, err := time.Parse("2006-01-02", period.StartDate)
+	if err != nil {
+		return halves, false
+	}
+	end, err := time.Parse("2006-01-02", period.EndDate)
+	if err != nil || !end.After(start) {
+		return halves, false
+	}
+
+	days := int(end.Sub(start).Hours() / 24)
+	mid := start.AddDate(0, 0, days/2)
+
+	halves[0] = TimePeriod{StartDate: period.StartDate, EndDate: mid.Format("2006-01-02"), DateType: period.DateType}
+	halves[1] = TimePeriod{StartDate: mid.AddDate(0, 0, 1).Format("2006-01-02"), EndDate: period.EndDate, DateType: period.DateType}
+	return halves, true
+}
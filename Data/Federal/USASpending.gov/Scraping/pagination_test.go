@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"usaspending-scraper/fakeusaspending"
+)
+
+// generatedGrants builds n grant fixtures with internal IDs 1..n, the action
+// date of each coming from date.
+func generatedGrants(n int, date func(i int) string) []fakeusaspending.Award {
+	awards := make([]fakeusaspending.Award, n)
+	for i := range awards {
+		id := fmt.Sprintf("ASST_NON_GEN%05d_075", i+1)
+		awards[i] = fakeusaspending.Award{
+			TypeCode:   "04",
+			Row:        []byte(fmt.Sprintf(`{"internal_id":%d,"generated_internal_id":%q,"Award ID":"GEN%05d","Start Date":%q}`, i+1, id, i+1, date(i))),
+			ActionDate: date(i),
+		}
+	}
+	return awards
+}
+
+func newGeneratedAPI(t *testing.T, awards []fakeusaspending.Award) *fakeusaspending.Server {
+	t.Helper()
+	server, err := fakeusaspending.New(&fakeusaspending.Fixtures{Awards: awards})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.ResultWindow = searchResultWindow
+	t.Cleanup(server.Close)
+	return server
+}
+
+// spreadOver2020 spreads fixtures over the days of 2020.
+func spreadOver2020(i int) string {
+	return time.Date(2020, time.January, 1+i%366, 0, 0, 0, 0, time.UTC).Format(dateLayout)
+}
+
+func windowRequest(s *Scraper, start, end string) APIRequest {
+	request := s.createRequest("grants", awardTypeGroups["grants"])
+	request.Filters.TimePeriod = []TimePeriod{{StartDate: start, EndDate: end}}
+	return request
+}
+
+// captureLog sends the standard logger to a buffer for the rest of the test.
+func captureLog(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return &buf
+}
+
+func TestPageWindowSwitchesToKeysetCursor(t *testing.T) {
+	server := newGeneratedAPI(t, generatedGrants(searchResultWindow+50, spreadOver2020))
+	server.Keyset = true
+	s := newTestScraper(server.URL, 1)
+
+	c := newAwardCollector("grants")
+	if err := s.scrapeWindow(context.Background(), windowRequest(s, "2020-01-01", "2020-12-31"), c); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.awards) != searchResultWindow+50 || len(c.shortfalls) != 0 {
+		t.Errorf("collected %d awards with shortfalls %v, want %d and none", len(c.awards), c.shortfalls, searchResultWindow+50)
+	}
+
+	searches := server.Searches()
+	if len(searches) != 101 {
+		t.Fatalf("%d search pages fetched, want 101", len(searches))
+	}
+	for i, search := range searches {
+		if period := search.TimePeriod; len(period) != 1 || period[0].StartDate != "2020-01-01" || period[0].EndDate != "2020-12-31" {
+			t.Fatalf("search %d has time period %v; a window with a cursor must not be split", i+1, period)
+		}
+		// The first page has no cursor yet; every later one continues from the last row
+		if want := i * 100; search.LastRecordUniqueID != want {
+			t.Fatalf("search %d sent last_record_unique_id %d, want %d", i+1, search.LastRecordUniqueID, want)
+		}
+	}
+	if last := searches[len(searches)-1]; last.Page != 101 || last.LastRecordSortValue == "" {
+		t.Errorf("last search = %+v, want page 101 past the result window with a sort value", last)
+	}
+}
+
+func TestScrapeWindowSplitsOversizedWindow(t *testing.T) {
+	awards := generatedGrants(searchResultWindow+50, spreadOver2020)
+
+	// An award with actions in both halves matches both of them
+	twice := awards[0]
+	twice.ActionDate = "2020-11-30"
+	awards = append(awards, twice)
+
+	server := newGeneratedAPI(t, awards)
+	s := newTestScraper(server.URL, 1)
+
+	c := newAwardCollector("grants")
+	if err := s.scrapeWindow(context.Background(), windowRequest(s, "2020-01-01", "2020-12-31"), c); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.awards) != searchResultWindow+50 || len(c.shortfalls) != 0 {
+		t.Errorf("collected %d awards with shortfalls %v, want %d and none", len(c.awards), c.shortfalls, searchResultWindow+50)
+	}
+
+	periods := make(map[string]int)
+	for _, search := range server.Searches() {
+		periods[search.TimePeriod[0].StartDate+".."+search.TimePeriod[0].EndDate]++
+	}
+	want := map[string]int{
+		"2020-01-01..2020-12-31": 1,  // One page before the count forces a split
+		"2020-01-01..2020-07-01": 52, // 5,109 awards
+		"2020-07-02..2020-12-31": 50, // 4,942 awards, one of them also in the first half
+	}
+	if fmt.Sprint(periods) != fmt.Sprint(want) {
+		t.Errorf("pages per window = %v, want %v", periods, want)
+	}
+}
+
+func TestScrapeWindowRecordsShortfall(t *testing.T) {
+	total := searchResultWindow + 50
+	server := newGeneratedAPI(t, generatedGrants(total, func(int) string { return "2020-03-15" }))
+	s := newTestScraper(server.URL, 1)
+	logged := captureLog(t)
+
+	// A single day cannot be split, so only the first page is reachable
+	c := newAwardCollector("grants")
+	if err := s.scrapeWindow(context.Background(), windowRequest(s, "2020-03-15", "2020-03-15"), c); err != nil {
+		t.Fatal(err)
+	}
+	c.logShortfalls()
+
+	want := fmt.Sprintf("2020-03-15..2020-03-15: fetched 100 of %d awards", total)
+	if len(c.shortfalls) != 1 || c.shortfalls[0] != want {
+		t.Errorf("shortfalls = %v, want [%s]", c.shortfalls, want)
+	}
+	for _, line := range []string{
+		"[grants] Warning: could not reach all awards for " + want,
+		"[grants] Warning: 1 time windows came back incomplete:",
+		"[grants]   " + want,
+	} {
+		if !strings.Contains(logged.String(), line) {
+			t.Errorf("log does not contain %q:\n%s", line, logged)
+		}
+	}
+	if len(c.awards) != 100 {
+		t.Errorf("collected %d awards, want the 100 on the first page", len(c.awards))
+	}
+}
+
+func TestScrapeWindowCountsEveryGroup(t *testing.T) {
+	// The count endpoint files 06/10 under direct_payments and 09/11 under
+	// other, the reverse of the group names
+	for _, group := range []string{"other_financial_assistance", "direct_payments"} {
+		total := searchResultWindow + 50
+		awards := generatedGrants(total, func(int) string { return "2020-03-15" })
+		for i := range awards {
+			awards[i].TypeCode = awardTypeGroups[group][i%2]
+		}
+		server := newGeneratedAPI(t, awards)
+		s := newTestScraper(server.URL, 1)
+		logged := captureLog(t)
+
+		request := s.createRequest(group, awardTypeGroups[group])
+		request.Filters.TimePeriod = []TimePeriod{{StartDate: "2020-03-15", EndDate: "2020-03-15"}}
+		c := newAwardCollector(group)
+		if err := s.scrapeWindow(context.Background(), request, c); err != nil {
+			t.Fatal(err)
+		}
+
+		if line := fmt.Sprintf("[%s] 2020-03-15..2020-03-15: %d matching awards", group, total); !strings.Contains(logged.String(), line) {
+			t.Errorf("log does not contain %q:\n%s", line, logged)
+		}
+		want := fmt.Sprintf("2020-03-15..2020-03-15: fetched 100 of %d awards", total)
+		if len(c.shortfalls) != 1 || c.shortfalls[0] != want {
+			t.Errorf("%s: shortfalls = %v, want [%s]", group, c.shortfalls, want)
+		}
+	}
+}
+
+func TestSplitTimePeriod(t *testing.T) {
+	period := func(start, end string) *TimePeriod {
+		return &TimePeriod{StartDate: start, EndDate: end, DateType: "last_modified_date"}
+	}
+	tests := []struct {
+		name   string
+		period *TimePeriod
+		ok     bool
+		halves [2]TimePeriod
+	}{
+		{"nil", nil, false, [2]TimePeriod{}},
+		{"single day", period("2020-03-15", "2020-03-15"), false, [2]TimePeriod{}},
+		{"reversed", period("2020-03-15", "2020-03-01"), false, [2]TimePeriod{}},
+		{"bad date", period("2020-03-15", "soon"), false, [2]TimePeriod{}},
+		{"two days", period("2020-03-01", "2020-03-02"), true,
+			[2]TimePeriod{*period("2020-03-01", "2020-03-01"), *period("2020-03-02", "2020-03-02")}},
+		{"three days", period("2020-03-01", "2020-03-03"), true,
+			[2]TimePeriod{*period("2020-03-01", "2020-03-02"), *period("2020-03-03", "2020-03-03")}},
+		{"four days", period("2020-03-01", "2020-03-04"), true,
+			[2]TimePeriod{*period("2020-03-01", "2020-03-02"), *period("2020-03-03", "2020-03-04")}},
+		{"leap year", period("2020-01-01", "2020-12-31"), true,
+			[2]TimePeriod{*period("2020-01-01", "2020-07-01"), *period("2020-07-02", "2020-12-31")}},
+		{"fiscal year", period("2019-10-01", "2020-09-30"), true,
+			[2]TimePeriod{*period("2019-10-01", "2020-03-31"), *period("2020-04-01", "2020-09-30")}},
+	}
+	for _, tt := range tests {
+		halves, ok := splitTimePeriod(tt.period)
+		if ok != tt.ok || halves != tt.halves {
+			t.Errorf("%s: split = %v, %v; want %v, %v", tt.name, halves, ok, tt.halves, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"reflect"
+)
+
+// Default Parquet directory under the output root
+const defaultParquetDir = "parquet"
+
+// parquetKind is how an award column is typed and what its value function
+// returns. A nil value is null, as are empty strings, nil pointers, null
+// amounts and zero dates.
+type parquetKind int
+
+const (
+	parquetText       parquetKind = iota // string or *string
+	parquetMoney                         // Money, *Money or float64 dollars; DECIMAL(18,2)
+	parquetDay                           // Date, or a YYYY-MM-DD string; DATE
+	parquetInteger                       // int; INT32
+	parquetFlag                          // bool; BOOLEAN
+	parquetTextList                      // []string; LIST of strings
+	parquetAmountList                    // []AccountObligation; LIST of {code, amount}
+)
+
+// parquetAwardColumn is one column of the award schema.
+type parquetAwardColumn struct {
+	name  string
+	kind  parquetKind
+	value func(a *exportedAward) interface{}
+}
+
+// node returns the schema of the column.
+func (c parquetAwardColumn) node() *parquetNode {
+	switch c.kind {
+	case parquetText:
+		return parquetLeafNode(c.name, parquetOptional, parquetByteArray, parquetString)
+	case parquetMoney:
+		return parquetLeafNode(c.name, parquetOptional, parquetInt64, parquetDecimal)
+	case parquetDay:
+		return parquetLeafNode(c.name, parquetOptional, parquetInt32, parquetDate)
+	case parquetInteger:
+		return parquetLeafNode(c.name, parquetOptional, parquetInt32, parquetNoAnnotation)
+	case parquetFlag:
+		return parquetLeafNode(c.name, parquetOptional, parquetBoolean, parquetNoAnnotation)
+	case parquetTextList:
+		return parquetListNode(c.name, parquetLeafNode("", parquetRequired, parquetByteArray, parquetString))
+	case parquetAmountList:
+		return parquetListNode(c.name, parquetGroupNode("", parquetRequired,
+			parquetLeafNode("code", parquetRequired, parquetByteArray, parquetString),
+			parquetLeafNode("amount", parquetRequired, parquetInt64, parquetDecimal)))
+	}
+	panic(fmt.Sprintf("parquet column %s: unknown kind %d", c.name, c.kind))
+}
+
+// add adds the column's value for one award to its leaf columns.
+func (c parquetAwardColumn) add(columns []*parquetColumn, a *exportedAward) {
+	value := c.value(a)
+	switch c.kind {
+	case parquetTextList:
+		list, _ := value.([]string)
+		addParquetList(columns, list == nil, len(list), func(column *parquetColumn, i int) interface{} { return list[i] })
+		return
+	case parquetAmountList:
+		list, _ := value.([]AccountObligation)
+		addParquetList(columns, list == nil, len(list), func(column *parquetColumn, i int) interface{} {
+			if column.path[len(column.path)-1] == "code" {
+				return list[i].Code
+			}
+			cents, _ := dollarCents(list[i].Amount).Cents()
+			return cents
+		})
+		return
+	}
+
+	if v, ok := parquetScalar(c.kind, value); ok {
+		columns[0].add(0, 1, v)
+	} else {
+		columns[0].add(0, 0, nil)
+	}
+}
+
+// addParquetList adds a list of n elements, or a null list, to the leaf
+// columns of a list column.
+func addParquetList(columns []*parquetColumn, null bool, n int, element func(column *parquetColumn, i int) interface{}) {
+	for _, column := range columns {
+		switch {
+		case null:
+			column.add(0, 0, nil)
+		case n == 0:
+			column.add(0, 1, nil)
+		}
+		for i := 0; i < n; i++ {
+			rep := 1
+			if i == 0 {
+				rep = 0
+			}
+			column.add(rep, 2, element(column, i))
+		}
+	}
+}
+
+// parquetScalar converts a column value to its physical value, reporting
+// false for null.
+func parquetScalar(kind parquetKind, value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case nil:
+		return nil, false
+	case *string:
+		if v == nil {
+			return nil, false
+		}
+		return parquetScalar(kind, *v)
+	case *Money:
+		if v == nil {
+			return nil, false
+		}
+		return parquetScalar(kind, *v)
+	case float64:
+		return parquetScalar(kind, dollarCents(v))
+	case string:
+		if v == "" {
+			return nil, false
+		}
+		if kind == parquetDay {
+			date, err := ParseDate(v)
+			if err != nil {
+				return nil, false
+			}
+			return parquetScalar(kind, date)
+		}
+		return v, true
+	case Money:
+		cents, ok := v.Cents()
+		return cents, ok
+	case Date:
+		if v.IsZero() {
+			return nil, false
+		}
+		return int32(v.Time().Unix() / 86400), true
+	case int:
+		return int32(v), true
+	case bool:
+		return v, true
+	}
+	panic(fmt.Sprintf("parquet: unsupported value %T", value))
+}
+
+// fromDetail returns the value of a detail field, or null for awards saved
+// without detail.
+func fromDetail(value func(d *DetailedAwardResponse) interface{}) func(a *exportedAward) interface{} {
+	return func(a *exportedAward) interface{} {
+		if a.detail == nil {
+			return nil
+		}
+		return value(a.detail)
+	}
+}
+
+// fromAssistance returns the value of an assistance detail field, or null
+// for other awards.
+func fromAssistance(value func(d *AssistanceAwardDetail) interface{}) func(a *exportedAward) interface{} {
+	return func(a *exportedAward) interface{} {
+		assistance, ok := a.award.DetailedData.(*AssistanceAwardDetail)
+		if !ok {
+			return nil
+		}
+		return value(assistance)
+	}
+}
+
+// locationColumns returns the columns of a location, named with prefix.
+func locationColumns(prefix string, location func(a *exportedAward) *Location) []parquetAwardColumn {
+	field := func(name string, value func(l *Location) string) parquetAwardColumn {
+		return parquetAwardColumn{prefix + name, parquetText, func(a *exportedAward) interface{} { return value(location(a)) }}
+	}
+	return []parquetAwardColumn{
+		field("address_line1", func(l *Location) string { return l.AddressLine1 }),
+		field("city", func(l *Location) string { return l.CityName }),
+		field("county", func(l *Location) string { return l.CountyName }),
+		field("state_code", func(l *Location) string { return l.StateCode }),
+		field("state_name", func(l *Location) string { return l.StateName }),
+		field("zip5", func(l *Location) string { return l.Zip5 }),
+		field("congressional_code", func(l *Location) string { return l.CongressionalCode }),
+		field("country_code", func(l *Location) string { return l.LocationCountryCode }),
+		field("country_name", func(l *Location) string { return l.CountryName }),
+	}
+}
+
+// agencyColumns returns the columns of an agency, named with prefix.
+func agencyColumns(prefix string, agency func(a *exportedAward) *AgencyInfo) []parquetAwardColumn {
+	field := func(name string, value func(info *AgencyInfo) string) parquetAwardColumn {
+		return parquetAwardColumn{prefix + name, parquetText, func(a *exportedAward) interface{} { return value(agency(a)) }}
+	}
+	return []parquetAwardColumn{
+		field("agency", func(info *AgencyInfo) string { return info.ToptierAgency.Name }),
+		field("agency_code", func(info *AgencyInfo) string { return info.ToptierAgency.Code }),
+		field("agency_abbreviation", func(info *AgencyInfo) string { return info.ToptierAgency.Abbreviation }),
+		field("sub_agency", func(info *AgencyInfo) string { return info.SubtierAgency.Name }),
+		field("sub_agency_code", func(info *AgencyInfo) string { return info.SubtierAgency.Code }),
+	}
+}
+
+// parquetAwardColumns is the award schema: the search row and detail
+// flattened into one wide row, with every contract data field at the end as
+// a contract_ column. Where both carry a field, the column follows the
+// SQLite export in preferring the detail.
+func parquetAwardColumns() []parquetAwardColumn {
+	columns := []parquetAwardColumn{
+		{"award_group", parquetText, func(a *exportedAward) interface{} { return a.group }},
+		{"generated_internal_id", parquetText, func(a *exportedAward) interface{} { return a.basic.GeneratedInternalID }},
+		{"award_id", parquetText, func(a *exportedAward) interface{} { return a.basic.AwardID }},
+		{"category", parquetText, fromDetail(func(d *DetailedAwardResponse) interface{} { return d.Category })},
+		{"type", parquetText, fromDetail(func(d *DetailedAwardResponse) interface{} { return d.Type })},
+		{"type_description", parquetText, fromDetail(func(d *DetailedAwardResponse) interface{} { return d.TypeDescription })},
+		{"contract_award_type", parquetText, func(a *exportedAward) interface{} { return a.basic.ContractAwardType }},
+		{"description", parquetText, func(a *exportedAward) interface{} {
+			if a.basic.Description == "" && a.detail != nil {
+				return a.detail.Description
+			}
+			return a.basic.Description
+		}},
+		{"campus", parquetText, func(a *exportedAward) interface{} { return a.entity.Key }},
+		{"campus_name", parquetText, func(a *exportedAward) interface{} { return a.entity.Name }},
+
+		{"recipient_name", parquetText, func(a *exportedAward) interface{} { return a.recipient.RecipientName }},
+		{"recipient_uei", parquetText, func(a *exportedAward) interface{} { return a.recipient.RecipientUEI }},
+		{"recipient_duns", parquetText, func(a *exportedAward) interface{} { return a.recipient.RecipientUniqueID }},
+		{"recipient_hash", parquetText, func(a *exportedAward) interface{} { return a.recipient.RecipientHash }},
+		{"parent_recipient_name", parquetText, func(a *exportedAward) interface{} { return a.recipient.ParentRecipientName }},
+		{"parent_recipient_uei", parquetText, func(a *exportedAward) interface{} { return a.recipient.ParentRecipientUEI }},
+		{"parent_recipient_duns", parquetText, func(a *exportedAward) interface{} { return a.recipient.ParentRecipientUniqueID }},
+		{"parent_recipient_hash", parquetText, func(a *exportedAward) interface{} { return a.recipient.ParentRecipientHash }},
+		{"business_categories", parquetTextList, func(a *exportedAward) interface{} { return a.recipient.BusinessCategories }},
+	}
+	columns = append(columns, locationColumns("recipient_", func(a *exportedAward) *Location { return &a.recipientLocation })...)
+	columns = append(columns, locationColumns("pop_", func(a *exportedAward) *Location { return &a.placeOfPerformance })...)
+	columns = append(columns, agencyColumns("awarding_", func(a *exportedAward) *AgencyInfo { return &a.awarding })...)
+	columns = append(columns, agencyColumns("funding_", func(a *exportedAward) *AgencyInfo { return &a.funding })...)
+	columns = append(columns, []parquetAwardColumn{
+		{"naics_code", parquetText, func(a *exportedAward) interface{} { return a.naics.Code }},
+		{"naics_description", parquetText, func(a *exportedAward) interface{} { return a.naics.Description }},
+		{"psc_code", parquetText, func(a *exportedAward) interface{} { return a.psc.Code }},
+		{"psc_description", parquetText, func(a *exportedAward) interface{} { return a.psc.Description }},
+		{"fain", parquetText, fromAssistance(func(d *AssistanceAwardDetail) interface{} { return d.FAIN })},
+		{"assistance_listings", parquetTextList, fromAssistance(func(d *AssistanceAwardDetail) interface{} { return d.AssistanceListings() })},
+		{"parent_award_id", parquetText, fromDetail(func(d *DetailedAwardResponse) interface{} { return d.ParentAward.ID() })},
+
+		{"award_amount", parquetMoney, func(a *exportedAward) interface{} { return a.basic.AwardAmount }},
+		{"total_outlays", parquetMoney, func(a *exportedAward) interface{} { return a.basic.TotalOutlays }},
+		{"total_obligation", parquetMoney, fromDetail(func(d *DetailedAwardResponse) interface{} { return d.TotalObligation })},
+		{"base_exercised_options", parquetMoney, fromDetail(func(d *DetailedAwardResponse) interface{} { return d.BaseExercisedOptions })},
+		{"base_and_all_options", parquetMoney, fromDetail(func(d *DetailedAwardResponse) interface{} { return d.BaseAndAllOptions })},
+		{"total_account_obligation", parquetMoney, fromDetail(func(d *DetailedAwardResponse) interface{} { return d.TotalAccountObligation })},
+		{"total_account_outlay", parquetMoney, fromDetail(func(d *DetailedAwardResponse) interface{} { return d.TotalAccountOutlay })},
+		{"total_subaward_amount", parquetMoney, fromDetail(func(d *DetailedAwardResponse) interface{} {
+			if d.TotalSubawardAmount == nil {
+				return nil
+			}
+			return *d.TotalSubawardAmount
+		})},
+		{"covid19_obligations", parquetMoney, func(a *exportedAward) interface{} { return a.basic.COVID19Obligations }},
+		{"covid19_outlays", parquetMoney, func(a *exportedAward) interface{} { return a.basic.COVID19Outlays }},
+		{"infrastructure_obligations", parquetMoney, func(a *exportedAward) interface{} { return a.basic.InfrastructureObligations }},
+		{"infrastructure_outlays", parquetMoney, func(a *exportedAward) interface{} { return a.basic.InfrastructureOutlays }},
+		{"loan_value", parquetMoney, func(a *exportedAward) interface{} { return a.basic.LoanValue }},
+		{"subsidy_cost", parquetMoney, func(a *exportedAward) interface{} { return a.basic.SubsidyCost }},
+		{"non_federal_funding", parquetMoney, fromAssistance(func(d *AssistanceAwardDetail) interface{} { return d.NonFederalFunding })},
+		{"total_funding", parquetMoney, fromAssistance(func(d *AssistanceAwardDetail) interface{} { return d.TotalFunding })},
+		{"def_codes", parquetTextList, func(a *exportedAward) interface{} { return a.basic.DefCodes }},
+		{"account_obligations_by_defc", parquetAmountList, fromDetail(func(d *DetailedAwardResponse) interface{} { return d.AccountObligationsByDEFC })},
+		{"account_outlays_by_defc", parquetAmountList, fromDetail(func(d *DetailedAwardResponse) interface{} { return d.AccountOutlaysByDEFC })},
+
+		{"start_date", parquetDay, func(a *exportedAward) interface{} { return a.basic.StartDate }},
+		{"end_date", parquetDay, func(a *exportedAward) interface{} { return a.basic.EndDate }},
+		{"potential_end_date", parquetDay, fromDetail(func(d *DetailedAwardResponse) interface{} { return d.PeriodOfPerformance.PotentialEndDate })},
+		{"date_signed", parquetDay, fromDetail(func(d *DetailedAwardResponse) interface{} { return d.DateSigned })},
+		{"issued_date", parquetDay, func(a *exportedAward) interface{} { return a.basic.IssuedDate }},
+		{"last_modified_date", parquetDay, fromDetail(func(d *DetailedAwardResponse) interface{} { return d.PeriodOfPerformance.LastModifiedDate })},
+		{"fiscal_year", parquetInteger, func(a *exportedAward) interface{} {
+			if a.fiscalYear == 0 {
+				return nil
+			}
+			return a.fiscalYear
+		}},
+		{"subaward_count", parquetInteger, fromDetail(func(d *DetailedAwardResponse) interface{} { return d.SubawardCount })},
+	}...)
+
+	t := reflect.TypeOf(ContractData{})
+	for i, name := range contractDataColumns() {
+		i := i
+		kind := parquetText
+		if t.Field(i).Type.Kind() == reflect.Bool {
+			kind = parquetFlag
+		}
+		columns = append(columns, parquetAwardColumn{"contract_" + name, kind, fromDetail(func(d *DetailedAwardResponse) interface{} {
+			if d.LatestTransactionContractData == nil {
+				return nil
+			}
+			return reflect.ValueOf(d.LatestTransactionContractData).Elem().Field(i).Interface()
+		})})
+	}
+	return columns
+}
+
+// writeParquetAwards writes the saved awards of one group as a Parquet file
+// and returns how many it wrote.
+func writeParquetAwards(w io.Writer, outputRoot, groupName string, resolver *RecipientResolver) (int, error) {
+	columns := parquetAwardColumns()
+	nodes := make([]*parquetNode, len(columns))
+	for i, column := range columns {
+		nodes[i] = column.node()
+	}
+	pw, err := newParquetWriter(w, "award", nodes)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	err = walkEnhancedAwards(outputRoot, groupName, func(path string, award *EnhancedAward) error {
+		a := newExportedAward(groupName, award, resolver)
+		for i, column := range columns {
+			column.add(pw.field(i), a)
+		}
+		count++
+		return pw.EndRow()
+	})
+	if err != nil {
+		return count, err
+	}
+	return count, pw.Close()
+}
+
+// exportParquet writes one <group>.parquet file per award group to dir.
+func exportParquet(outputRoot string, groups []string, resolver *RecipientResolver, dir string) error {
+	if err := ensureDirectoryExists(dir); err != nil {
+		return fmt.Errorf("error creating directory: %w", err)
+	}
+	for _, groupName := range groups {
+		path := filepath.Join(dir, groupName+".parquet")
+		var count int
+		err := writeFileAtomic(path, func(w io.Writer) error {
+			var err error
+			count, err = writeParquetAwards(w, outputRoot, groupName, resolver)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("error exporting %s: %w", groupName, err)
+		}
+		log.Printf("Exported %d %s awards to %s", count, groupName, path)
+	}
+	return nil
+}
@@ -0,0 +1,461 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+// This file writes Parquet (https://parquet.apache.org/docs/file-format/),
+// as much of it as the export needs: optional scalar columns and lists,
+// uncompressed, with PLAIN values and RLE levels, which every reader
+// supports. Each row group holds one data page per column.
+
+const parquetMagic = "PAR1"
+
+// Rows per row group
+const parquetRowGroupRows = 10000
+
+// Physical types
+const (
+	parquetBoolean   int32 = 0
+	parquetInt32     int32 = 1
+	parquetInt64     int32 = 2
+	parquetByteArray int32 = 6
+)
+
+// parquetAnnotation is the logical type of a node, written both as the
+// legacy converted type and as the logical type.
+type parquetAnnotation int
+
+const (
+	parquetNoAnnotation parquetAnnotation = iota
+	parquetString
+	parquetList
+	parquetDecimal // DECIMAL(18,2)
+	parquetDate
+)
+
+// Converted type and logical type union field of each annotation
+var parquetAnnotationIDs = map[parquetAnnotation]struct{ converted, logical int32 }{
+	parquetString:  {0, 1},
+	parquetList:    {3, 3},
+	parquetDecimal: {5, 5},
+	parquetDate:    {6, 6},
+}
+
+// Repetition types
+const (
+	parquetRequired int32 = 0
+	parquetOptional int32 = 1
+	parquetRepeated int32 = 2
+)
+
+// Encodings
+const (
+	parquetPlain int32 = 0
+	parquetRLE   int32 = 3
+)
+
+// parquetNode is one element of the schema tree: a leaf column when it has a
+// physical type, a group otherwise.
+type parquetNode struct {
+	name       string
+	repetition int32
+	physical   int32
+	annotation parquetAnnotation
+	children   []*parquetNode
+}
+
+func parquetLeafNode(name string, repetition, physical int32, annotation parquetAnnotation) *parquetNode {
+	return &parquetNode{name: name, repetition: repetition, physical: physical, annotation: annotation}
+}
+
+func parquetGroupNode(name string, repetition int32, children ...*parquetNode) *parquetNode {
+	return &parquetNode{name: name, repetition: repetition, children: children}
+}
+
+// parquetListNode wraps element in the three-level LIST structure of the
+// format: <name> (LIST) { repeated group list { element } }.
+func parquetListNode(name string, element *parquetNode) *parquetNode {
+	element.name = "element"
+	list := parquetGroupNode(name, parquetOptional, parquetGroupNode("list", parquetRepeated, element))
+	list.annotation = parquetList
+	return list
+}
+
+func (n *parquetNode) isLeaf() bool {
+	return n.children == nil
+}
+
+// parquetColumn buffers the levels and values of one leaf column for the
+// current row group.
+type parquetColumn struct {
+	node           *parquetNode
+	path           []string
+	maxDef, maxRep int
+
+	reps, defs []int
+	values     bytes.Buffer
+	bools      []bool
+}
+
+// add appends one entry. value is nil unless def is maxDef, and is a
+// string, int32, int64 or bool matching the physical type.
+func (c *parquetColumn) add(rep, def int, value interface{}) {
+	c.reps = append(c.reps, rep)
+	c.defs = append(c.defs, def)
+	if def < c.maxDef {
+		return
+	}
+	switch v := value.(type) {
+	case string:
+		binary.Write(&c.values, binary.LittleEndian, uint32(len(v)))
+		c.values.WriteString(v)
+	case int32:
+		binary.Write(&c.values, binary.LittleEndian, v)
+	case int64:
+		binary.Write(&c.values, binary.LittleEndian, v)
+	case bool:
+		c.bools = append(c.bools, v)
+	default:
+		panic(fmt.Sprintf("parquet column %v: unsupported value %T", c.path, value))
+	}
+}
+
+// page returns the data page: repetition levels, definition levels, values.
+func (c *parquetColumn) page() []byte {
+	var page bytes.Buffer
+	if c.maxRep > 0 {
+		writeParquetLevels(&page, c.reps, c.maxRep)
+	}
+	if c.maxDef > 0 {
+		writeParquetLevels(&page, c.defs, c.maxDef)
+	}
+	if c.node.physical == parquetBoolean {
+		packed := make([]byte, (len(c.bools)+7)/8)
+		for i, b := range c.bools {
+			if b {
+				packed[i/8] |= 1 << (i % 8)
+			}
+		}
+		page.Write(packed)
+	} else {
+		page.Write(c.values.Bytes())
+	}
+	return page.Bytes()
+}
+
+func (c *parquetColumn) reset() {
+	c.reps, c.defs, c.bools = c.reps[:0], c.defs[:0], c.bools[:0]
+	c.values.Reset()
+}
+
+// writeParquetLevels writes levels in the RLE/bit-packed hybrid encoding,
+// as RLE runs only, after their length in bytes.
+func writeParquetLevels(w *bytes.Buffer, levels []int, maxLevel int) {
+	width := (bits.Len(uint(maxLevel)) + 7) / 8
+	var runs bytes.Buffer
+	for i := 0; i < len(levels); {
+		j := i
+		for j < len(levels) && levels[j] == levels[i] {
+			j++
+		}
+		runs.Write(binary.AppendUvarint(nil, uint64(j-i)<<1))
+		for b := 0; b < width; b++ {
+			runs.WriteByte(byte(levels[i] >> (8 * b)))
+		}
+		i = j
+	}
+	binary.Write(w, binary.LittleEndian, uint32(runs.Len()))
+	w.Write(runs.Bytes())
+}
+
+// parquetWriter writes rows to a Parquet file. Every top-level field's
+// values are added to its columns, then EndRow completes the row.
+type parquetWriter struct {
+	w      io.Writer
+	offset int64
+	root   *parquetNode
+
+	columns   []*parquetColumn
+	fields    [][]*parquetColumn // Leaf columns of each top-level field
+	rows      int                // Rows in the current row group
+	numRows   int64
+	rowGroups []parquetRowGroup
+}
+
+type parquetRowGroup struct {
+	chunks  []parquetChunk
+	size    int64
+	numRows int64
+}
+
+type parquetChunk struct {
+	offset    int64
+	size      int64
+	numValues int64
+}
+
+func newParquetWriter(w io.Writer, name string, fields []*parquetNode) (*parquetWriter, error) {
+	pw := &parquetWriter{w: w, root: &parquetNode{name: name, children: fields}}
+	for _, field := range fields {
+		start := len(pw.columns)
+		pw.addColumns(field, nil, 0, 0)
+		pw.fields = append(pw.fields, pw.columns[start:])
+	}
+	return pw, pw.write([]byte(parquetMagic))
+}
+
+func (pw *parquetWriter) addColumns(n *parquetNode, path []string, def, rep int) {
+	path = append(path[:len(path):len(path)], n.name)
+	switch n.repetition {
+	case parquetOptional:
+		def++
+	case parquetRepeated:
+		def++
+		rep++
+	}
+	if n.isLeaf() {
+		pw.columns = append(pw.columns, &parquetColumn{node: n, path: path, maxDef: def, maxRep: rep})
+		return
+	}
+	for _, child := range n.children {
+		pw.addColumns(child, path, def, rep)
+	}
+}
+
+// field returns the leaf columns of the i-th top-level field.
+func (pw *parquetWriter) field(i int) []*parquetColumn {
+	return pw.fields[i]
+}
+
+func (pw *parquetWriter) write(data []byte) error {
+	n, err := pw.w.Write(data)
+	pw.offset += int64(n)
+	return err
+}
+
+// EndRow completes a row, writing the row group once it is full.
+func (pw *parquetWriter) EndRow() error {
+	pw.rows++
+	pw.numRows++
+	if pw.rows == parquetRowGroupRows {
+		return pw.flush()
+	}
+	return nil
+}
+
+func (pw *parquetWriter) flush() error {
+	if pw.rows == 0 {
+		return nil
+	}
+	group := parquetRowGroup{numRows: int64(pw.rows)}
+	for _, c := range pw.columns {
+		page := c.page()
+		var header thriftWriter
+		header.begin()
+		header.i32(1, 0) // DATA_PAGE
+		header.i32(2, int32(len(page)))
+		header.i32(3, int32(len(page)))
+		header.beginStruct(5)
+		header.i32(1, int32(len(c.defs)))
+		header.i32(2, parquetPlain)
+		header.i32(3, parquetRLE)
+		header.i32(4, parquetRLE)
+		header.end()
+		header.end()
+
+		chunk := parquetChunk{offset: pw.offset, size: int64(header.buf.Len() + len(page)), numValues: int64(len(c.defs))}
+		if err := pw.write(header.buf.Bytes()); err != nil {
+			return err
+		}
+		if err := pw.write(page); err != nil {
+			return err
+		}
+		group.chunks = append(group.chunks, chunk)
+		group.size += chunk.size
+		c.reset()
+	}
+	pw.rowGroups = append(pw.rowGroups, group)
+	pw.rows = 0
+	return nil
+}
+
+// Close writes the last row group and the footer.
+func (pw *parquetWriter) Close() error {
+	if err := pw.flush(); err != nil {
+		return err
+	}
+
+	var meta thriftWriter
+	meta.begin()
+	meta.i32(1, 1)
+	var schema []*parquetNode
+	var walk func(n *parquetNode)
+	walk = func(n *parquetNode) {
+		schema = append(schema, n)
+		for _, child := range n.children {
+			walk(child)
+		}
+	}
+	walk(pw.root)
+	meta.list(2, thriftStruct, len(schema))
+	for _, n := range schema {
+		meta.begin()
+		if n.isLeaf() {
+			meta.i32(1, n.physical)
+		}
+		if n != pw.root {
+			meta.i32(3, n.repetition)
+		}
+		meta.binary(4, n.name)
+		if !n.isLeaf() {
+			meta.i32(5, int32(len(n.children)))
+		}
+		if ids, ok := parquetAnnotationIDs[n.annotation]; ok {
+			meta.i32(6, ids.converted)
+			if n.annotation == parquetDecimal {
+				meta.i32(7, 2)
+				meta.i32(8, 18)
+			}
+			meta.beginStruct(10)
+			meta.beginStruct(int16(ids.logical))
+			if n.annotation == parquetDecimal {
+				meta.i32(1, 2)
+				meta.i32(2, 18)
+			}
+			meta.end()
+			meta.end()
+		}
+		meta.end()
+	}
+	meta.i64(3, pw.numRows)
+	meta.list(4, thriftStruct, len(pw.rowGroups))
+	for _, group := range pw.rowGroups {
+		meta.begin()
+		meta.list(1, thriftStruct, len(group.chunks))
+		for i, chunk := range group.chunks {
+			c := pw.columns[i]
+			meta.begin()
+			meta.i64(2, chunk.offset)
+			meta.beginStruct(3)
+			meta.i32(1, c.node.physical)
+			meta.list(2, thriftI32, 2)
+			meta.element32(parquetPlain)
+			meta.element32(parquetRLE)
+			meta.list(3, thriftBinary, len(c.path))
+			for _, name := range c.path {
+				meta.elementBinary(name)
+			}
+			meta.i32(4, 0) // UNCOMPRESSED
+			meta.i64(5, chunk.numValues)
+			meta.i64(6, chunk.size)
+			meta.i64(7, chunk.size)
+			meta.i64(9, chunk.offset)
+			meta.end()
+			meta.end()
+		}
+		meta.i64(2, group.size)
+		meta.i64(3, group.numRows)
+		meta.end()
+	}
+	meta.binary(6, "usaspending-scraper")
+	meta.end()
+
+	if err := pw.write(meta.buf.Bytes()); err != nil {
+		return err
+	}
+	var trailer [4]byte
+	binary.LittleEndian.PutUint32(trailer[:], uint32(meta.buf.Len()))
+	if err := pw.write(trailer[:]); err != nil {
+		return err
+	}
+	return pw.write([]byte(parquetMagic))
+}
+
+// Thrift compact protocol types, as the Parquet metadata is encoded
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes structs in the Thrift compact protocol. Every begin
+// or beginStruct is matched by an end, which writes the struct's stop byte;
+// begin starts the outermost struct or a list element.
+type thriftWriter struct {
+	buf  bytes.Buffer
+	last []int16 // Last field ID of each open struct
+}
+
+func (t *thriftWriter) begin() {
+	t.last = append(t.last, 0)
+}
+
+func (t *thriftWriter) beginStruct(id int16) {
+	t.field(id, thriftStruct)
+	t.begin()
+}
+
+func (t *thriftWriter) end() {
+	t.buf.WriteByte(0)
+	t.last = t.last[:len(t.last)-1]
+}
+
+func (t *thriftWriter) field(id int16, typ byte) {
+	last := &t.last[len(t.last)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.varint(zigzag(int64(id)))
+	}
+	*last = id
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.varint(zigzag(int64(v)))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.varint(zigzag(v))
+}
+
+func (t *thriftWriter) binary(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.elementBinary(s)
+}
+
+func (t *thriftWriter) list(id int16, elem byte, n int) {
+	t.field(id, thriftList)
+	if n < 15 {
+		t.buf.WriteByte(byte(n)<<4 | elem)
+	} else {
+		t.buf.WriteByte(0xf0 | elem)
+		t.varint(uint64(n))
+	}
+}
+
+func (t *thriftWriter) element32(v int32) {
+	t.varint(zigzag(int64(v)))
+}
+
+func (t *thriftWriter) elementBinary(s string) {
+	t.varint(uint64(len(s)))
+	t.buf.WriteString(s)
+}
+
+func (t *thriftWriter) varint(v uint64) {
+	t.buf.Write(binary.AppendUvarint(nil, v))
+}
+
+func zigzag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
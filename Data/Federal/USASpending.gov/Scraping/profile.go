@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Name of the built-in profile that reproduces the original UC-wide search
+const defaultProfileName = "uc-all"
+
+// File written at the output root recording which profile produced the data
+const profileRecordFile = "search_profile.json"
+
+// SearchProfile is a named set of search filters, per-group overrides and an
+// output directory, loaded from a profiles file.
+type SearchProfile struct {
+	Name           string                 `json:"-"`
+	Description    string                 `json:"description,omitempty"`
+	Groups         []string               `json:"groups,omitempty"`          // Default award groups; empty means all
+	Filters        Filters                `json:"filters"`                   // AwardTypeCodes is filled in per group
+	GroupOverrides map[string]GroupConfig `json:"group_overrides,omitempty"` // Replaces SortField/Fields from awardTypeConfigs
+	OutputDir      string                 `json:"output_dir,omitempty"`
+}
+
+// ProfileFile is the on-disk layout of a profiles file.
+type ProfileFile struct {
+	Profiles map[string]*SearchProfile `json:"profiles"`
+}
+
+// ProfileRecord is saved next to the output so a data tree can be traced back
+// to the profile that produced it.
+type ProfileRecord struct {
+	Name        string         `json:"name"`
+	Hash        string         `json:"hash"`
+	ConfigFile  string         `json:"config_file,omitempty"`
+	GeneratedAt string         `json:"generated_at"`
+	Profile     *SearchProfile `json:"profile"`
+}
+
+func defaultSearchProfile() *SearchProfile {
+	return &SearchProfile{
+		Name:        defaultProfileName,
+		Description: "All University of California awards performed in California",
+		Filters: Filters{
+			Keywords: []string{"University of California"},
+			TimePeriod: []TimePeriod{
+				{
+					StartDate: "2007-10-01",
+					EndDate:   "2025-09-30",
+				},
+			},
+			RecipientTypeNames: []string{
+				"higher_education",
+				"public_institution_of_higher_education",
+				"private_institution_of_higher_education",
+				"minority_serving_institution_of_higher_education",
+				"school_of_forestry",
+				"veterinary_college",
+				"government",
+			},
+			PlaceOfPerformanceLocations: []PlaceOfPerformance{
+				{
+					Country: "USA",
+					State:   "CA",
+				},
+			},
+		},
+	}
+}
+
+// loadProfileFile reads and validates every profile in a JSON profiles file.
+func loadProfileFile(filename string) (map[string]*SearchProfile, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error reading profiles file: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var file ProfileFile
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("error decoding profiles file %s: %w", filename, err)
+	}
+
+	for name, profile := range file.Profiles {
+		if profile == nil {
+			return nil, fmt.Errorf("profile %q is empty", name)
+		}
+		profile.Name = name
+		if err := profile.validate(); err != nil {
+			return nil, fmt.Errorf("profile %q: %w", name, err)
+		}
+	}
+	return file.Profiles, nil
+}
+
+// resolveSearchProfile picks a profile by name. Without a config file only
+// the built-in uc-all profile is available.
+func resolveSearchProfile(configFile, name string) (*SearchProfile, error) {
+	if name == "" {
+		name = defaultProfileName
+	}
+
+	if configFile == "" {
+		if name != defaultProfileName {
+			return nil, fmt.Errorf("profile %q requested but no -config file given", name)
+		}
+		return defaultSearchProfile(), nil
+	}
+
+	profiles, err := loadProfileFile(configFile)
+	if err != nil {
+		return nil, err
+	}
+	if profile, ok := profiles[name]; ok {
+		return profile, nil
+	}
+	if name == defaultProfileName {
+		return defaultSearchProfile(), nil
+	}
+
+	names := make([]string, 0, len(profiles))
+	for n := range profiles {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return nil, fmt.Errorf("profile %q not found in %s (available: %v)", name, configFile, names)
+}
+
+func (p *SearchProfile) validate() error {
+	for _, group := range p.Groups {
+		if _, ok := awardTypeGroups[group]; !ok {
+			return fmt.Errorf("unknown award group %q", group)
+		}
+	}
+	for group := range p.GroupOverrides {
+		if _, ok := awardTypeGroups[group]; !ok {
+			return fmt.Errorf("override for unknown award group %q", group)
+		}
+	}
+	for _, period := range p.Filters.TimePeriod {
+		for _, date := range []string{period.StartDate, period.EndDate} {
+			if _, err := time.Parse("2006-01-02", date); err != nil {
+				return fmt.Errorf("invalid time_period date %q", date)
+			}
+		}
+	}
+	if len(p.Filters.AwardTypeCodes) > 0 {
+		return fmt.Errorf("award_type_codes is set per group and must not appear in filters")
+	}
+	return nil
+}
+
+// groupConfig returns the sort field and fields for a group, applying any
+// profile overrides on top of awardTypeConfigs.
+func (p *SearchProfile) groupConfig(groupName string) GroupConfig {
+	config := awardTypeConfigs[groupName]
+	if override, ok := p.GroupOverrides[groupName]; ok {
+		if override.SortField != "" {
+			config.SortField = override.SortField
+		}
+		if len(override.Fields) > 0 {
+			config.Fields = override.Fields
+		}
+	}
+	return config
+}
+
+// Hash is the SHA-256 of the profile's canonical JSON encoding.
+func (p *SearchProfile) Hash() string {
+	data, _ := json.Marshal(p)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// writeProfileRecord saves the profile name, hash and body at the output root.
+func writeProfileRecord(outputRoot, configFile string, profile *SearchProfile) error {
+	if err := ensureDirectoryExists(outputRoot); err != nil {
+		return fmt.Errorf("error creating directory: %w", err)
+	}
+
+	record := ProfileRecord{
+		Name:        profile.Name,
+		Hash:        profile.Hash(),
+		ConfigFile:  configFile,
+		GeneratedAt: time.Now().Format(time.RFC3339),
+		Profile:     profile,
+	}
+
+	return writeJSONFile(filepath.Join(outputRoot, profileRecordFile), record)
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeProfiles writes a profiles file into a temp directory.
+func writeProfiles(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "profiles.json")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadProfileFile(t *testing.T) {
+	profiles, err := loadProfileFile("profiles.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, groups := range map[string][]string{
+		"uc-all":             nil,
+		"ucsf-nih-grants":    {"grants"},
+		"lbnl-doe-contracts": {"contracts", "idvs"},
+	} {
+		profile := profiles[name]
+		if profile == nil {
+			t.Errorf("profile %s missing", name)
+			continue
+		}
+		if profile.Name != name || !reflect.DeepEqual(profile.Groups, groups) {
+			t.Errorf("profile %s: name %q, groups %v; want groups %v", name, profile.Name, profile.Groups, groups)
+		}
+	}
+
+	// The shipped uc-all must search exactly like the built-in one
+	if got, want := profiles["uc-all"].Hash(), defaultSearchProfile().Hash(); got != want {
+		t.Errorf("profiles.json uc-all hash %.12s, built-in %.12s", got, want)
+	}
+}
+
+func TestLoadProfileFileRejectsInvalidProfiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"unknown key", `{"profiles": {"p": {"filters": {}, "sort": "Award ID"}}}`, `unknown field "sort"`},
+		{"unknown filter", `{"profiles": {"p": {"filters": {"keyword": ["UC"]}}}}`, `unknown field "keyword"`},
+		{"empty profile", `{"profiles": {"p": null}}`, `profile "p" is empty`},
+		{"unknown group", `{"profiles": {"p": {"groups": ["grants", "leases"], "filters": {}}}}`, `unknown award group "leases"`},
+		{"unknown override", `{"profiles": {"p": {"filters": {}, "group_overrides": {"leases": {"sort_field": "Award ID"}}}}}`,
+			`override for unknown award group "leases"`},
+		{"bad date", `{"profiles": {"p": {"filters": {"time_period": [{"start_date": "2020-13-01", "end_date": "2021-01-01"}]}}}}`,
+			`invalid time_period date "2020-13-01"`},
+		{"award type codes", `{"profiles": {"p": {"filters": {"award_type_codes": ["A"]}}}}`, "award_type_codes is set per group"},
+		{"malformed", `{"profiles": {"p": {"filters": {}}}`, "error decoding profiles file"},
+	}
+	for _, tt := range tests {
+		_, err := loadProfileFile(writeProfiles(t, tt.content))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+
+	if _, err := loadProfileFile(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("loading a missing file succeeded")
+	}
+}
+
+func TestResolveSearchProfile(t *testing.T) {
+	withoutDefault := writeProfiles(t, `{"profiles": {"ucla": {"description": "UCLA only", "filters": {"keywords": ["UCLA"]}}}}`)
+	tests := []struct {
+		configFile, name string
+		want             string // Description of the resolved profile
+		err              string
+	}{
+		{"", "", "All University of California awards performed in California", ""},
+		{"", "uc-all", "All University of California awards performed in California", ""},
+		{"", "ucla", "", `profile "ucla" requested but no -config file given`},
+		{"profiles.json", "ucsf-nih-grants", "NIH grants to UC San Francisco", ""},
+		{"profiles.json", "", "All University of California awards performed in California", ""},
+		{withoutDefault, "ucla", "UCLA only", ""},
+		{withoutDefault, "", "All University of California awards performed in California", ""},
+		{withoutDefault, "ucsd", "", `profile "ucsd" not found in ` + withoutDefault + " (available: [ucla])"},
+	}
+	for _, tt := range tests {
+		profile, err := resolveSearchProfile(tt.configFile, tt.name)
+		switch {
+		case tt.err != "":
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("resolveSearchProfile(%q, %q) err = %v, want %q", tt.configFile, tt.name, err, tt.err)
+			}
+		case err != nil:
+			t.Errorf("resolveSearchProfile(%q, %q): %v", tt.configFile, tt.name, err)
+		case profile.Description != tt.want:
+			t.Errorf("resolveSearchProfile(%q, %q) = %q, want %q", tt.configFile, tt.name, profile.Description, tt.want)
+		}
+	}
+}
+
+func TestGroupConfigOverrides(t *testing.T) {
+	profiles, err := loadProfileFile(writeProfiles(t, `{"profiles": {"p": {"filters": {}, "group_overrides": {
+		"grants": {"sort_field": "Start Date"},
+		"contracts": {"fields": ["Award ID", "Recipient Name"]},
+		"loans": {"sort_field": "Issued Date", "fields": ["Award ID", "Loan Value"]}
+	}}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewScraper(ScraperOptions{OutputRoot: "unused", Profile: profiles["p"]})
+
+	tests := []struct {
+		group     string
+		sortField string
+		fields    []string
+	}{
+		{"grants", "Start Date", awardTypeConfigs["grants"].Fields},
+		{"contracts", awardTypeConfigs["contracts"].SortField, []string{"Award ID", "Recipient Name"}},
+		{"loans", "Issued Date", []string{"Award ID", "Loan Value"}},
+		{"idvs", awardTypeConfigs["idvs"].SortField, awardTypeConfigs["idvs"].Fields},
+	}
+	for _, tt := range tests {
+		request := s.createRequest(tt.group, awardTypeGroups[tt.group])
+		if request.Sort != tt.sortField || !reflect.DeepEqual(request.Fields, tt.fields) {
+			t.Errorf("%s: sort %q, fields %v; want %q, %v", tt.group, request.Sort, request.Fields, tt.sortField, tt.fields)
+		}
+	}
+
+	// Overrides never leak into the shared defaults
+	if defaults := awardTypeConfigs["loans"]; defaults.SortField != "Loan Value" || reflect.DeepEqual(defaults.Fields, []string{"Award ID", "Loan Value"}) {
+		t.Errorf("override changed awardTypeConfigs: %+v", defaults)
+	}
+}
+
+func TestWriteProfileRecord(t *testing.T) {
+	profile, err := resolveSearchProfile("profiles.json", "ucsf-nih-grants")
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputRoot := filepath.Join(t.TempDir(), "out")
+	if err := writeProfileRecord(outputRoot, "profiles.json", profile); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(outputRoot, profileRecordFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var record ProfileRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatal(err)
+	}
+	if record.Name != "ucsf-nih-grants" || record.ConfigFile != "profiles.json" || record.GeneratedAt == "" {
+		t.Errorf("record = %+v", record)
+	}
+	if record.Hash != profile.Hash() || record.Profile.Hash() != profile.Hash() {
+		t.Errorf("record hash %.12s, saved profile hash %.12s, want %.12s", record.Hash, record.Profile.Hash(), profile.Hash())
+	}
+	if !strings.HasSuffix(string(data), "}\n") {
+		t.Error("record does not end with a newline")
+	}
+}
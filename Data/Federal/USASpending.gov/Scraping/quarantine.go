@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// File at the output root listing awards kept out of the tree as not UC
+const quarantineFile = "quarantine.json"
+
+// QuarantinedAward is an award the keyword search returned whose recipient
+// did not resolve to a UC entity.
+type QuarantinedAward struct {
+	Group               string `json:"group"`
+	GeneratedInternalID string `json:"generated_internal_id"`
+	AwardID             string `json:"award_id"`
+	RecipientName       string `json:"recipient_name"`
+	RecipientUEI        string `json:"recipient_uei,omitempty"`
+	ParentRecipientUEI  string `json:"parent_recipient_uei,omitempty"`
+	City                string `json:"city,omitempty"`
+	Amount              Money  `json:"amount"`
+	Rule                string `json:"rule"`
+	Reason              string `json:"reason"`
+	Path                string `json:"path,omitempty"` // Saved file, for awards found in the tree
+}
+
+// QuarantineReport is the layout of quarantine.json.
+type QuarantineReport = AwardReport[QuarantinedAward]
+
+func (a QuarantinedAward) reportKey() (string, string) {
+	return a.Group, a.GeneratedInternalID
+}
+
+func newQuarantinedAward(groupName string, award *EnhancedAward, resolution Resolution) QuarantinedAward {
+	ref := awardRecipient(award)
+	amount := award.BasicData.AwardAmount
+	if !amount.Valid() {
+		amount = award.BasicData.LoanValue
+	}
+	return QuarantinedAward{
+		Group:               groupName,
+		GeneratedInternalID: award.BasicData.GeneratedInternalID,
+		AwardID:             award.BasicData.AwardID,
+		RecipientName:       ref.name,
+		RecipientUEI:        ref.uei,
+		ParentRecipientUEI:  ref.parentUEI,
+		City:                ref.city,
+		Amount:              amount,
+		Rule:                resolution.Rule,
+		Reason:              resolution.Reason,
+	}
+}
+
+// AwardReport is the layout of the award lists kept at the output root,
+// such as quarantine.json and undated.json.
+type AwardReport[T reportEntry] struct {
+	UpdatedAt string `json:"updated_at"`
+	Awards    []T    `json:"awards"`
+}
+
+// reportEntry is an award listed in an AwardReport.
+type reportEntry interface {
+	reportKey() (group, generatedInternalID string)
+}
+
+func readAwardReport[T reportEntry](path string) (*AwardReport[T], error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &AwardReport[T]{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", filepath.Base(path), err)
+	}
+	var report AwardReport[T]
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("error decoding %s: %w", path, err)
+	}
+	return &report, nil
+}
+
+func readQuarantineReport(path string) (*QuarantineReport, error) {
+	return readAwardReport[QuarantinedAward](path)
+}
+
+// awardReportWriter keeps an AwardReport file up to date as awards are
+// recorded. Entries from earlier runs are kept; an award recorded again
+// replaces its entry. It is safe for concurrent use by the workers.
+type awardReportWriter[T reportEntry] struct {
+	mu     sync.Mutex
+	path   string
+	awards map[[2]string]T // Keyed by group and generated_internal_id
+}
+
+// quarantine keeps quarantine.json up to date as awards are excluded.
+type quarantine = awardReportWriter[QuarantinedAward]
+
+func newQuarantine(outputRoot string) *quarantine {
+	return &quarantine{path: filepath.Join(outputRoot, quarantineFile)}
+}
+
+// add records awards and rewrites the report.
+func (w *awardReportWriter[T]) add(entries ...T) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.awards == nil {
+		report, err := readAwardReport[T](w.path)
+		if err != nil {
+			return err
+		}
+		w.awards = make(map[[2]string]T, len(report.Awards))
+		for _, entry := range report.Awards {
+			group, id := entry.reportKey()
+			w.awards[[2]string{group, id}] = entry
+		}
+	}
+	for _, entry := range entries {
+		group, id := entry.reportKey()
+		w.awards[[2]string{group, id}] = entry
+	}
+
+	keys := make([][2]string, 0, len(w.awards))
+	for key := range w.awards {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+	report := AwardReport[T]{UpdatedAt: time.Now().Format(time.RFC3339)}
+	for _, key := range keys {
+		report.Awards = append(report.Awards, w.awards[key])
+	}
+
+	if err := ensureDirectoryExists(filepath.Dir(w.path)); err != nil {
+		return fmt.Errorf("error creating directory: %w", err)
+	}
+	return writeJSONFile(w.path, report)
+}
+
+// entityTotals counts the awards and award amount of one entity.
+type entityTotals struct {
+	awards int
+	cents  int64
+	names  map[string]int // Recipient names that resolved to the entity
+}
+
+// printRecipients resolves every saved award and lists the awards and money
+// per canonical entity, with the recipient spellings behind each. With
+// record, awards that are not UC are added to quarantine.json.
+func printRecipients(w io.Writer, outputRoot string, groups []string, resolver *RecipientResolver, record bool) error {
+	totals := make(map[string]*entityTotals)
+	var quarantined []QuarantinedAward
+
+	for _, groupName := range groups {
+		err := walkEnhancedAwards(outputRoot, groupName, func(path string, award *EnhancedAward) error {
+			resolution := resolver.Resolve(award)
+			key := resolution.Entity.Key
+			if totals[key] == nil {
+				totals[key] = &entityTotals{names: make(map[string]int)}
+			}
+			t := totals[key]
+			t.awards++
+			if cents, ok := award.BasicData.AwardAmount.Cents(); ok {
+				t.cents += cents
+			}
+			t.names[award.BasicData.RecipientName]++
+
+			if !resolution.Entity.IsUC() {
+				entry := newQuarantinedAward(groupName, award, resolution)
+				entry.Path = path
+				quarantined = append(quarantined, entry)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, entity := range canonicalEntities {
+		t := totals[entity.Key]
+		if t == nil {
+			continue
+		}
+		fmt.Fprintf(w, "%-24s %6d awards  $%s\n", entity.Key, t.awards, MoneyFromCents(t.cents))
+		for _, rc := range topRecipients(t.names, len(t.names)) {
+			fmt.Fprintf(w, "    %6d  %s\n", rc.count, rc.name)
+		}
+	}
+
+	if len(quarantined) == 0 {
+		return nil
+	}
+	fmt.Fprintf(w, "\n%d saved awards are not UC:\n", len(quarantined))
+	for _, entry := range quarantined {
+		fmt.Fprintf(w, "  %s (%s): %s\n", entry.Path, entry.RecipientName, entry.Reason)
+	}
+	if !record {
+		return nil
+	}
+	if err := newQuarantine(outputRoot).add(quarantined...); err != nil {
+		return fmt.Errorf("error writing quarantine report: %w", err)
+	}
+	fmt.Fprintf(w, "Recorded in %s\n", filepath.Join(outputRoot, quarantineFile))
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterAllowsBurstThenPaces(t *testing.T) {
+	limiter := NewRateLimiter(10, 3)
+	now := limiter.last
+
+	for i := 0; i < 3; i++ {
+		if wait := limiter.reserve(now); wait != 0 {
+			t.Fatalf("request %d within burst waited %v", i+1, wait)
+		}
+	}
+	if wait := limiter.reserve(now); wait != 100*time.Millisecond {
+		t.Errorf("first request past burst waited %v, want 100ms", wait)
+	}
+	if wait := limiter.reserve(now); wait != 200*time.Millisecond {
+		t.Errorf("second request past burst waited %v, want 200ms", wait)
+	}
+
+	// After a full second the debt is repaid and the bucket refills to burst
+	later := now.Add(time.Second)
+	if wait := limiter.reserve(later); wait != 0 {
+		t.Errorf("request after refill waited %v", wait)
+	}
+}
+
+func TestRateLimiterWaitHonorsContext(t *testing.T) {
+	limiter := NewRateLimiter(0.001, 1)
+	if err := limiter.Wait(context.Background()); err != nil {
+		t.Fatalf("first Wait: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := limiter.Wait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Wait = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestNilRateLimiterNeverBlocks(t *testing.T) {
+	var limiter *RateLimiter
+	if NewRateLimiter(0, 5) != nil {
+		t.Fatal("rate 0 should disable the limiter")
+	}
+	if err := limiter.Wait(context.Background()); err != nil {
+		t.Errorf("nil limiter Wait: %v", err)
+	}
+}
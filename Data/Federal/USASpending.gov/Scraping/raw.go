@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+)
+
+// RawPayload is the verbatim API output behind a saved award, kept next to
+// the typed fields so keys the structs do not declare are not lost.
+type RawPayload struct {
+	SearchRow json.RawMessage `json:"search_row,omitempty"` // Row from spending_by_award
+	Detail    json.RawMessage `json:"detail,omitempty"`     // Body of awards/{id}
+
+	Transactions []json.RawMessage `json:"transactions,omitempty"` // Rows from transactions
+	Subawards    []json.RawMessage `json:"subawards,omitempty"`    // Rows from subawards
+	Funding      []json.RawMessage `json:"funding,omitempty"`      // Rows from awards/funding
+	IDVChildren  []json.RawMessage `json:"idv_children,omitempty"` // Rows from idvs/awards
+	BulkRows     []json.RawMessage `json:"bulk_rows,omitempty"`    // CSV rows from a bulk download
+}
+
+// RawJSON returns the JSON the award was decoded from, or nil for an award
+// built in code.
+func (a *Award) RawJSON() json.RawMessage {
+	return a.raw
+}
+
+// RawJSON returns the JSON the detail was decoded from.
+func (d *DetailedAwardResponse) RawJSON() json.RawMessage {
+	return d.raw
+}
+
+// newRawPayload collects the raw search row, detail and list rows of an
+// award, or returns nil when none was captured.
+func newRawPayload(award *EnhancedAward) *RawPayload {
+	raw := &RawPayload{SearchRow: award.BasicData.RawJSON()}
+	if award.DetailedData != nil {
+		raw.Detail = award.DetailedData.Common().RawJSON()
+	}
+	for i := range award.Transactions {
+		if row := award.Transactions[i].RawJSON(); row != nil {
+			raw.Transactions = append(raw.Transactions, row)
+		}
+	}
+	for i := range award.Subawards {
+		if row := award.Subawards[i].RawJSON(); row != nil {
+			raw.Subawards = append(raw.Subawards, row)
+		}
+	}
+	for i := range award.Funding {
+		if row := award.Funding[i].RawJSON(); row != nil {
+			raw.Funding = append(raw.Funding, row)
+		}
+	}
+	if award.IDVChildren != nil {
+		for _, child := range award.IDVChildren.rows() {
+			if row := child.RawJSON(); row != nil {
+				raw.IDVChildren = append(raw.IDVChildren, row)
+			}
+		}
+	}
+	if raw.SearchRow == nil && raw.Detail == nil && raw.Transactions == nil && raw.Subawards == nil && raw.Funding == nil && raw.IDVChildren == nil {
+		return nil
+	}
+	return raw
+}
+
+// rawRows returns the search rows as received, falling back to the typed
+// encoding for awards without a captured row.
+func rawRows(awards []Award) ([]json.RawMessage, error) {
+	rows := make([]json.RawMessage, len(awards))
+	for i := range awards {
+		row, err := awardRow(&awards[i])
+		if err != nil {
+			return nil, err
+		}
+		rows[i] = row
+	}
+	return rows, nil
+}
+
+// awardRow returns one search row as compact JSON.
+func awardRow(award *Award) (json.RawMessage, error) {
+	if raw := award.RawJSON(); raw != nil {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, raw); err == nil {
+			return buf.Bytes(), nil
+		}
+	}
+	return json.Marshal(award)
+}
+
+// tolerateTypeError lets a decode succeed when the only problem is a value
+// whose JSON type no longer matches its field. encoding/json still fills every
+// other field, and the verbatim payload keeps the odd value for schema-drift.
+// The mismatch is stored in *mismatch: API responses log it with
+// warnTypeMismatch, while saved files, which are written from the structs,
+// reject it (see EnhancedAward.UnmarshalJSON).
+func tolerateTypeError(err error, what string, mismatch *error) error {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		*mismatch = fmt.Errorf("%s: %w", what, err)
+		return nil
+	}
+	return err
+}
+
+// warnTypeMismatch logs a value an API response sent with an unexpected JSON
+// type.
+func warnTypeMismatch(mismatch error) {
+	if mismatch != nil {
+		log.Printf("Warning: %v; the value is kept only in the raw payload (see schema-drift)", mismatch)
+	}
+}
+
+// typeMismatch returns the first value of a saved award whose JSON type does
+// not match its field, or nil.
+func (e *EnhancedAward) typeMismatch() error {
+	if e.BasicData.mismatch != nil {
+		return e.BasicData.mismatch
+	}
+	if e.DetailedData != nil && e.DetailedData.Common().mismatch != nil {
+		return e.DetailedData.Common().mismatch
+	}
+	for i := range e.Transactions {
+		if e.Transactions[i].mismatch != nil {
+			return e.Transactions[i].mismatch
+		}
+	}
+	for i := range e.Subawards {
+		if e.Subawards[i].mismatch != nil {
+			return e.Subawards[i].mismatch
+		}
+	}
+	for i := range e.Funding {
+		if e.Funding[i].mismatch != nil {
+			return e.Funding[i].mismatch
+		}
+	}
+	if e.IDVChildren != nil {
+		for _, child := range e.IDVChildren.rows() {
+			if child.mismatch != nil {
+				return child.mismatch
+			}
+		}
+	}
+	return nil
+}
+
+// captureRaw keeps a private copy of data, which encoding/json may reuse.
+func captureRaw(data []byte) json.RawMessage {
+	return append(json.RawMessage(nil), bytes.TrimSpace(data)...)
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// File at the output root mapping every path relayout moved to where the
+// award is now
+const relayoutManifestFile = "relayout_manifest.json"
+
+// Directory at the output root that holds award files relayout found to be
+// not UC, one subdirectory per group
+const quarantineDir = "Quarantine"
+
+// RelayoutManifest maps old award paths to their current ones. Paths are
+// relative to the output root and slash-separated. A path moved twice maps
+// straight to its latest location.
+type RelayoutManifest struct {
+	Layout    string            `json:"layout"`
+	UpdatedAt string            `json:"updated_at"`
+	Moves     map[string]string `json:"moves"`
+}
+
+func readRelayoutManifest(outputRoot string) (*RelayoutManifest, error) {
+	data, err := os.ReadFile(filepath.Join(outputRoot, relayoutManifestFile))
+	if os.IsNotExist(err) {
+		return &RelayoutManifest{Moves: make(map[string]string)}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", relayoutManifestFile, err)
+	}
+
+	var manifest RelayoutManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("error decoding %s: %w", relayoutManifestFile, err)
+	}
+	if manifest.Moves == nil {
+		manifest.Moves = make(map[string]string)
+	}
+	return &manifest, nil
+}
+
+// record notes that the file at from now lives at to, and repoints older
+// entries that led to from.
+func (m *RelayoutManifest) record(from, to string) {
+	for old, current := range m.Moves {
+		if current == from {
+			m.Moves[old] = to
+		}
+	}
+	if from != to {
+		m.Moves[from] = to
+	}
+	delete(m.Moves, to) // A path that is current again is no longer an old path
+}
+
+// Kinds of relayout changes
+const (
+	changeMove       = "move"
+	changeQuarantine = "quarantine"
+	changeDuplicate  = "duplicate"
+)
+
+// relayoutChange is one award file and where relayout puts it.
+type relayoutChange struct {
+	kind   string
+	from   string
+	to     string // For a duplicate, the path of the copy that is kept
+	reason string
+	entry  QuarantinedAward
+}
+
+// relayoutCandidate is a saved file that lands on a given target path.
+type relayoutCandidate struct {
+	path         string
+	hasDetail    bool
+	lastModified string
+	resolution   Resolution
+	entry        QuarantinedAward
+}
+
+// better reports whether c should be kept over other when both are copies of
+// the same award: a copy with detail wins, then the most recently modified.
+func (c *relayoutCandidate) better(other *relayoutCandidate) bool {
+	if c.hasDetail != other.hasDetail {
+		return c.hasDetail
+	}
+	if c.lastModified != other.lastModified {
+		return c.lastModified > other.lastModified
+	}
+	return c.path < other.path
+}
+
+// relayoutTarget is a destination path and every saved copy that maps to it.
+type relayoutTarget struct {
+	group      string
+	to         string
+	candidates []*relayoutCandidate
+}
+
+// planRelayout reads every award file of the given groups and works out
+// where the layout puts it. Awards that do not resolve to a UC entity go
+// to Quarantine/<Group>/<id>.json. Awards the layout cannot date stay where
+// they are and are returned separately.
+func planRelayout(outputRoot string, groups []string, layout *Layout, resolver *RecipientResolver) ([]*relayoutTarget, []UndatedAward, error) {
+	targets := make(map[string]*relayoutTarget)
+	var order []string
+	var undated []UndatedAward
+
+	for _, groupName := range groups {
+		err := walkEnhancedAwards(outputRoot, groupName, func(path string, award *EnhancedAward) error {
+			if award.BasicData.GeneratedInternalID == "" {
+				log.Printf("Warning: leaving %s in place: missing generated_internal_id", path)
+				return nil
+			}
+
+			resolution := resolver.Resolve(award)
+			candidate := &relayoutCandidate{
+				path:       path,
+				hasDetail:  award.DetailedData != nil,
+				resolution: resolution,
+			}
+			if award.DetailedData != nil {
+				candidate.lastModified = award.DetailedData.Common().PeriodOfPerformance.LastModifiedDate
+			}
+
+			var to string
+			if resolution.Entity.IsUC() {
+				var err error
+				to, err = layout.awardPath(outputRoot, groupName, award, resolution.Entity)
+				if err != nil {
+					entry := newUndatedAward(groupName, award, err)
+					entry.Path = path
+					undated = append(undated, entry)
+					return nil
+				}
+			} else {
+				to = filepath.Join(outputRoot, quarantineDir, directoryMapping[groupName], sanitizeFileName(award.BasicData.GeneratedInternalID)+".json")
+				candidate.entry = newQuarantinedAward(groupName, award, resolution)
+				candidate.entry.Path = to
+			}
+
+			target := targets[to]
+			if target == nil {
+				target = &relayoutTarget{group: groupName, to: to}
+				targets[to] = target
+				order = append(order, to)
+			}
+			target.candidates = append(target.candidates, candidate)
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	sort.Strings(order)
+	plan := make([]*relayoutTarget, len(order))
+	for i, to := range order {
+		target := targets[to]
+		sort.Slice(target.candidates, func(a, b int) bool {
+			return target.candidates[a].better(target.candidates[b])
+		})
+		plan[i] = target
+	}
+	return plan, undated, nil
+}
+
+// changes lists what applying the target does: the kept copy is moved or
+// quarantined, and the other copies are dropped as duplicates.
+func (t *relayoutTarget) changes() []relayoutChange {
+	kept := t.candidates[0]
+	var changes []relayoutChange
+	switch {
+	case !kept.resolution.Entity.IsUC():
+		changes = append(changes, relayoutChange{kind: changeQuarantine, from: kept.path, to: t.to, reason: kept.resolution.Reason, entry: kept.entry})
+	case kept.path != t.to:
+		changes = append(changes, relayoutChange{kind: changeMove, from: kept.path, to: t.to})
+	}
+	for _, other := range t.candidates[1:] {
+		if other.path != t.to {
+			changes = append(changes, relayoutChange{kind: changeDuplicate, from: other.path, to: t.to})
+		}
+	}
+	return changes
+}
+
+// relayoutTree moves every award file of the given groups to its path in the
+// layout. With dryRun it only writes the changes to w. Otherwise it applies
+// them, records old paths in the manifest and the layout at the output root.
+func relayoutTree(ctx context.Context, w io.Writer, outputRoot string, groups []string, layout *Layout, resolver *RecipientResolver, dryRun bool) error {
+	plan, undated, err := planRelayout(outputRoot, groups, layout, resolver)
+	if err != nil {
+		return err
+	}
+
+	rel := func(path string) string {
+		if r, err := filepath.Rel(outputRoot, path); err == nil {
+			return filepath.ToSlash(r)
+		}
+		return filepath.ToSlash(path)
+	}
+
+	counts := make(map[string]int)
+	files := 0
+	for _, target := range plan {
+		files += len(target.candidates)
+		for _, change := range target.changes() {
+			counts[change.kind]++
+			if !dryRun {
+				continue
+			}
+			switch change.kind {
+			case changeMove:
+				fmt.Fprintf(w, "~ %s -> %s\n", rel(change.from), rel(change.to))
+			case changeQuarantine:
+				fmt.Fprintf(w, "! %s -> %s (%s)\n", rel(change.from), rel(change.to), change.reason)
+			case changeDuplicate:
+				fmt.Fprintf(w, "- %s (duplicate of %s)\n", rel(change.from), rel(change.to))
+			}
+		}
+	}
+	if dryRun {
+		for _, entry := range undated {
+			fmt.Fprintf(w, "? %s (%s)\n", rel(entry.Path), entry.Reason)
+		}
+	}
+	unchanged := files - counts[changeMove] - counts[changeQuarantine] - counts[changeDuplicate]
+	log.Printf("Layout %s with %s years: %d files, %d to move, %d to quarantine, %d duplicates to drop, %d already in place, %d without a usable date",
+		layout.Template, layout.Years, files+len(undated), counts[changeMove], counts[changeQuarantine], counts[changeDuplicate], unchanged, len(undated))
+	if dryRun {
+		return nil
+	}
+
+	if len(undated) > 0 {
+		if err := newUndatedAwards(outputRoot).add(undated...); err != nil {
+			return fmt.Errorf("error writing undated report: %w", err)
+		}
+		log.Printf("%d awards without a usable date were left in place and listed in %s", len(undated), filepath.Join(outputRoot, undatedFile))
+	}
+
+	manifest, err := readRelayoutManifest(outputRoot)
+	if err != nil {
+		return err
+	}
+	quarantined := newQuarantine(outputRoot)
+	var conflicts []string
+	var runErr error
+
+apply:
+	for _, target := range plan {
+		if err := ctx.Err(); err != nil {
+			runErr = err
+			break
+		}
+
+		sources := make(map[string]bool, len(target.candidates))
+		for _, candidate := range target.candidates {
+			sources[candidate.path] = true
+		}
+		for _, change := range target.changes() {
+			switch change.kind {
+			case changeMove, changeQuarantine:
+				if _, err := os.Stat(change.to); err == nil && !sources[change.to] {
+					conflicts = append(conflicts, fmt.Sprintf("%s: %s is another award's file", rel(change.from), rel(change.to)))
+					continue apply
+				}
+				if err := ensureDirectoryExists(filepath.Dir(change.to)); err != nil {
+					runErr = fmt.Errorf("error creating directory: %w", err)
+					break apply
+				}
+				if err := os.Rename(change.from, change.to); err != nil {
+					runErr = fmt.Errorf("error moving %s: %w", change.from, err)
+					break apply
+				}
+				if change.kind == changeQuarantine {
+					if err := quarantined.add(change.entry); err != nil {
+						runErr = fmt.Errorf("error writing quarantine report: %w", err)
+						break apply
+					}
+				}
+			case changeDuplicate:
+				if err := os.Remove(change.from); err != nil && !os.IsNotExist(err) {
+					runErr = fmt.Errorf("error removing duplicate %s: %w", change.from, err)
+					break apply
+				}
+			}
+			manifest.record(rel(change.from), rel(change.to))
+			removeEmptyDirs(filepath.Dir(change.from), outputRoot)
+		}
+	}
+
+	manifest.Layout = layout.Template
+	manifest.UpdatedAt = time.Now().Format(time.RFC3339)
+	if err := writeJSONFile(filepath.Join(outputRoot, relayoutManifestFile), manifest); err != nil {
+		return fmt.Errorf("error writing %s: %w", relayoutManifestFile, err)
+	}
+	if runErr != nil {
+		return runErr
+	}
+	if err := writeLayout(outputRoot, layout); err != nil {
+		return fmt.Errorf("error recording layout: %w", err)
+	}
+
+	for _, conflict := range conflicts {
+		fmt.Fprintf(w, "conflict: %s\n", conflict)
+	}
+	log.Printf("Old paths are mapped to new ones in %s", filepath.Join(outputRoot, relayoutManifestFile))
+	if len(conflicts) > 0 {
+		return fmt.Errorf("%d files were left in place because their new path is taken", len(conflicts))
+	}
+	return nil
+}
+
+// removeEmptyDirs removes dir and its parents while they are empty, stopping
+// below root.
+func removeEmptyDirs(dir, root string) {
+	root = filepath.Clean(root)
+	for dir = filepath.Clean(dir); dir != root && filepath.Dir(dir) != root; dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			return
+		}
+	}
+}
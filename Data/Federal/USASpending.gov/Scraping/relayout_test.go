@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRelayoutMovesAwardsAndKeepsOldPaths(t *testing.T) {
+	root := t.TempDir()
+	write := func(rel, content string) {
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	const davis = `{"basic_data":{"generated_internal_id":"ASST_NON_DAVIS","Recipient Name":"UNIVERSITY OF CALIFORNIA, DAVIS","Start Date":"2023-11-01","Awarding Agency":"National Science Foundation"},` +
+		`"detailed_data":{"category":"grant","awarding_agency":{"toptier_agency":{"name":"National Science Foundation","code":"049"}},"period_of_performance":{"last_modified_date":"%s"}}}`
+	write("Grants/UNIVERSITY_OF_CALIFORNIA,_DAVIS/2023/National_Science_Foundation/ASST_NON_DAVIS.json", strings.Replace(davis, "%s", "2024-05-01", 1))
+	write("Grants/REGENTS_OF_THE_UNIVERSITY_OF_CALIFORNIA/2023/National_Science_Foundation/ASST_NON_DAVIS.json", strings.Replace(davis, "%s", "2024-01-01", 1))
+	write("Grants/SAN_DIEGO_STATE_UNIVERSITY/2022/National_Science_Foundation/ASST_NON_SDSU.json",
+		`{"basic_data":{"generated_internal_id":"ASST_NON_SDSU","Recipient Name":"SAN DIEGO STATE UNIVERSITY","recipient_uei":"T1MZN1RCNP33","Start Date":"2022-03-01"}}`)
+	write("Grants/uc_grants_awards.json", `[]`)
+
+	write("Grants/UNIVERSITY_OF_CALIFORNIA,_DAVIS/unknown/National_Science_Foundation/ASST_NON_UNDATED.json",
+		`{"basic_data":{"generated_internal_id":"ASST_NON_UNDATED","Recipient Name":"UNIVERSITY OF CALIFORNIA, DAVIS"},"detailed_data":{"category":"grant","date_signed":"2023-02-30"}}`)
+
+	layout, err := ParseLayout("{group}/{campus}/{fiscal_year}/{agency_code}/{id}.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	layout.Years = yearsFederal
+	resolver := defaultRecipientResolver()
+
+	var diff bytes.Buffer
+	if err := relayoutTree(context.Background(), &diff, root, []string{"grants"}, layout, resolver, true); err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	for _, want := range []string{
+		"~ Grants/UNIVERSITY_OF_CALIFORNIA,_DAVIS/2023/National_Science_Foundation/ASST_NON_DAVIS.json -> Grants/UC_DAVIS/FY2024/049/ASST_NON_DAVIS.json",
+		"- Grants/REGENTS_OF_THE_UNIVERSITY_OF_CALIFORNIA/2023/National_Science_Foundation/ASST_NON_DAVIS.json (duplicate of Grants/UC_DAVIS/FY2024/049/ASST_NON_DAVIS.json)",
+		"! Grants/SAN_DIEGO_STATE_UNIVERSITY/2022/National_Science_Foundation/ASST_NON_SDSU.json -> Quarantine/Grants/ASST_NON_SDSU.json",
+		"? Grants/UNIVERSITY_OF_CALIFORNIA,_DAVIS/unknown/National_Science_Foundation/ASST_NON_UNDATED.json (no start date, and the signing date is unusable: invalid date \"2023-02-30\")",
+	} {
+		if !strings.Contains(diff.String(), want) {
+			t.Errorf("dry run output lacks %q:\n%s", want, diff.String())
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "Grants", "UC_DAVIS")); !os.IsNotExist(err) {
+		t.Fatalf("dry run changed the tree")
+	}
+
+	if err := relayoutTree(context.Background(), &diff, root, []string{"grants"}, layout, resolver, false); err != nil {
+		t.Fatalf("relayout: %v", err)
+	}
+
+	moved := filepath.Join(root, "Grants", "UC_DAVIS", "FY2024", "049", "ASST_NON_DAVIS.json")
+	data, err := os.ReadFile(moved)
+	if err != nil {
+		t.Fatalf("award was not moved: %v", err)
+	}
+	if !strings.Contains(string(data), "2024-05-01") {
+		t.Errorf("kept the older duplicate: %s", data)
+	}
+	entries, _ := os.ReadDir(filepath.Join(root, "Grants"))
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if strings.Join(names, ",") != "UC_DAVIS,UNIVERSITY_OF_CALIFORNIA,_DAVIS,uc_grants_awards.json" {
+		t.Errorf("Grants/ holds %v, want the emptied directories removed", names)
+	}
+	if _, err := os.Stat(filepath.Join(root, quarantineDir, "Grants", "ASST_NON_SDSU.json")); err != nil {
+		t.Errorf("not-UC award was not quarantined: %v", err)
+	}
+	report, err := readQuarantineReport(filepath.Join(root, quarantineFile))
+	if err != nil || len(report.Awards) != 1 || report.Awards[0].GeneratedInternalID != "ASST_NON_SDSU" {
+		t.Errorf("quarantine report = %+v, %v", report, err)
+	}
+
+	undated, err := readUndatedReport(filepath.Join(root, undatedFile))
+	if err != nil || len(undated.Awards) != 1 || undated.Awards[0].DateSigned != "2023-02-30" {
+		t.Errorf("undated report = %+v, %v", undated, err)
+	}
+
+	manifest, err := readRelayoutManifest(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := manifest.Moves["Grants/REGENTS_OF_THE_UNIVERSITY_OF_CALIFORNIA/2023/National_Science_Foundation/ASST_NON_DAVIS.json"]; got != "Grants/UC_DAVIS/FY2024/049/ASST_NON_DAVIS.json" {
+		t.Errorf("duplicate maps to %q", got)
+	}
+	recorded, err := readLayout(root)
+	if err != nil || recorded.Template != layout.Template || recorded.Years != yearsFederal {
+		t.Errorf("recorded layout = %+v, %v", recorded, err)
+	}
+
+	// Moving again keeps the first paths resolvable
+	if err := relayoutTree(context.Background(), &diff, root, []string{"grants"}, defaultLayout(), resolver, false); err != nil {
+		t.Fatalf("second relayout: %v", err)
+	}
+	manifest, _ = readRelayoutManifest(root)
+	const current = "Grants/UC_DAVIS/2023/National_Science_Foundation/ASST_NON_DAVIS.json"
+	if got := manifest.Moves["Grants/UNIVERSITY_OF_CALIFORNIA,_DAVIS/2023/National_Science_Foundation/ASST_NON_DAVIS.json"]; got != current {
+		t.Errorf("original path maps to %q, want %q", got, current)
+	}
+	if _, ok := manifest.Moves[current]; ok {
+		t.Errorf("current path %s is listed as an old one", current)
+	}
+}
+
+func TestParseLayoutRejectsBadTemplates(t *testing.T) {
+	for _, template := range []string{
+		"{campus}/{group}/{id}.json",
+		"{group}/{campus}/{id}",
+		"{group}/{campus}/{month}/{id}.json",
+		"{group}//{id}.json",
+		"{group}/../{id}.json",
+		"{group}/{campus/{id}.json",
+	} {
+		if _, err := ParseLayout(template); err == nil {
+			t.Errorf("ParseLayout(%q) succeeded", template)
+		}
+	}
+	if _, err := ParseLayout("{group}/{campus}/{fiscal_year}/{agency_code}/{id}.json"); err != nil {
+		t.Errorf("ParseLayout: %v", err)
+	}
+}
+
+func TestYearBucketing(t *testing.T) {
+	tests := []struct {
+		date                  Date
+		federal, uc, calendar int
+	}{
+		{Date{2023, time.November, 1}, 2024, 2024, 2023},
+		{Date{2023, time.August, 15}, 2023, 2024, 2023},
+		{Date{2024, time.June, 30}, 2024, 2024, 2024},
+		{Date{2024, time.September, 30}, 2024, 2025, 2024},
+		{Date{2024, time.October, 1}, 2025, 2025, 2024},
+	}
+	for _, tt := range tests {
+		for b, want := range map[YearBucketing]int{yearsFederal: tt.federal, yearsUC: tt.uc, yearsCalendar: tt.calendar} {
+			if got := b.Year(tt.date); got != want {
+				t.Errorf("%s year of %s = %d, want %d", b, tt.date, got, want)
+			}
+		}
+	}
+	if got := yearsUC.Label(2024); got != "UCFY2024" {
+		t.Errorf("UC label = %q", got)
+	}
+
+	for _, award := range []EnhancedAward{
+		{},
+		{DetailedData: &DetailedAwardResponse{DateSigned: "11/01/2023"}},
+		{BasicData: Award{StartDate: Date{1, time.January, 1}}},
+	} {
+		if date, err := awardDate(&award); err == nil {
+			t.Errorf("awardDate(%+v) = %s, want an error", award, date)
+		}
+	}
+
+	// A loan is bucketed by its issued date, not the earlier signing date
+	loan := EnhancedAward{
+		BasicData:    Award{IssuedDate: Date{2020, time.October, 5}},
+		DetailedData: &DetailedAwardResponse{DateSigned: "2020-09-20"},
+	}
+	if date, err := awardDate(&loan); err != nil || yearsFederal.Year(date) != 2021 {
+		t.Errorf("loan awardDate = %s, %v; want 2020-10-05 in FY2021", date, err)
+	}
+}
+
+func TestTargetLayoutKeepsRecordedYears(t *testing.T) {
+	outputRoot := t.TempDir()
+	template := "{group}/{campus}/{fiscal_year}/{id}.json"
+
+	tests := []struct {
+		recorded YearBucketing // Written to layout.json first; empty writes none
+		years    string
+		want     YearBucketing
+	}{
+		{"", "", yearsCalendar},
+		{"", "federal", yearsFederal},
+		{yearsUC, "", yearsUC},
+		{yearsUC, "calendar", yearsCalendar},
+	}
+	for _, tt := range tests {
+		if tt.recorded != "" {
+			if err := writeLayout(outputRoot, &Layout{Template: defaultLayoutTemplate, Years: tt.recorded}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		layout, err := targetLayout(outputRoot, template, tt.years)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if layout.Template != template || layout.Years != tt.want {
+			t.Errorf("recorded %q, -years %q: layout %+v, want %s years", tt.recorded, tt.years, layout, tt.want)
+		}
+	}
+
+	if _, err := targetLayout(outputRoot, template, "academic"); err == nil {
+		t.Error("unknown bucketing accepted")
+	}
+}
+
+func TestRunLayoutPicksYearsForNewTree(t *testing.T) {
+	// A dry run does not record the choice
+	fresh := t.TempDir()
+	if layout, err := runLayout(fresh, "federal", false); err != nil || layout.Years != yearsFederal {
+		t.Fatalf("dry run layout = %+v, %v", layout, err)
+	}
+	if _, err := os.Stat(filepath.Join(fresh, layoutFile)); !os.IsNotExist(err) {
+		t.Errorf("dry run wrote %s: %v", layoutFile, err)
+	}
+
+	// The first run records it, and later runs keep it without -years
+	if _, err := runLayout(fresh, "uc", true); err != nil {
+		t.Fatal(err)
+	}
+	for _, years := range []string{"", "uc"} {
+		if layout, err := runLayout(fresh, years, true); err != nil || layout.Years != yearsUC {
+			t.Errorf("-years %q after recording uc: %+v, %v", years, layout, err)
+		}
+	}
+	if _, err := runLayout(fresh, "federal", true); err == nil || !strings.Contains(err.Error(), "run relayout -years federal") {
+		t.Errorf("switching a recorded tree: err = %v", err)
+	}
+
+	// A tree saved before layout.json existed has calendar years
+	legacy := t.TempDir()
+	path := filepath.Join(groupDirectory(legacy, "grants"), "UC_DAVIS", "2023", "ASST_NON_DAVIS.json")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(`{"basic_data":{"generated_internal_id":"ASST_NON_DAVIS","Start Date":"2023-11-01"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runLayout(legacy, "federal", true); err == nil {
+		t.Error("-years federal accepted for a tree saved with calendar years")
+	}
+	if layout, err := runLayout(legacy, "calendar", true); err != nil || layout.Years != yearsCalendar {
+		t.Errorf("-years calendar on a calendar tree: %+v, %v", layout, err)
+	}
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Entity kinds
+const (
+	entityCampus = "campus"
+	entitySystem = "system"
+	entityLab    = "national_lab"
+	entityHealth = "health"
+	entityNotUC  = "not_uc"
+)
+
+// Key of the entity for awards that do not belong to UC
+const notUCKey = "NOT_UC"
+
+// Entity is a canonical UC recipient. Its key names the recipient directory
+// in the output tree.
+type Entity struct {
+	Key  string `json:"key"`
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+}
+
+// IsUC reports whether the entity is part of the University of California.
+func (e Entity) IsUC() bool {
+	return e.Kind != entityNotUC
+}
+
+// campusNames maps the campus part of a recipient name, as in "UNIVERSITY OF
+// CALIFORNIA, DAVIS", to the campus key.
+var campusNames = map[string]string{
+	"BERKELEY":      "UC_BERKELEY",
+	"DAVIS":         "UC_DAVIS",
+	"IRVINE":        "UC_IRVINE",
+	"LOS ANGELES":   "UC_LOS_ANGELES",
+	"MERCED":        "UC_MERCED",
+	"RIVERSIDE":     "UC_RIVERSIDE",
+	"SAN DIEGO":     "UC_SAN_DIEGO",
+	"SAN FRANCISCO": "UC_SAN_FRANCISCO",
+	"SANTA BARBARA": "UC_SANTA_BARBARA",
+	"SANTA CRUZ":    "UC_SANTA_CRUZ",
+}
+
+// healthSystems maps a campus key to the key of its academic health system.
+var healthSystems = map[string]string{
+	"UC_DAVIS":         "UC_HEALTH_DAVIS",
+	"UC_IRVINE":        "UC_HEALTH_IRVINE",
+	"UC_LOS_ANGELES":   "UC_HEALTH_LOS_ANGELES",
+	"UC_RIVERSIDE":     "UC_HEALTH_RIVERSIDE",
+	"UC_SAN_DIEGO":     "UC_HEALTH_SAN_DIEGO",
+	"UC_SAN_FRANCISCO": "UC_HEALTH_SAN_FRANCISCO",
+}
+
+// canonicalEntities lists every entity an award can resolve to, in report order.
+var canonicalEntities = []Entity{
+	{"UC_BERKELEY", "University of California, Berkeley", entityCampus},
+	{"UC_DAVIS", "University of California, Davis", entityCampus},
+	{"UC_IRVINE", "University of California, Irvine", entityCampus},
+	{"UC_LOS_ANGELES", "University of California, Los Angeles", entityCampus},
+	{"UC_MERCED", "University of California, Merced", entityCampus},
+	{"UC_RIVERSIDE", "University of California, Riverside", entityCampus},
+	{"UC_SAN_DIEGO", "University of California, San Diego", entityCampus},
+	{"UC_SAN_FRANCISCO", "University of California, San Francisco", entityCampus},
+	{"UC_SANTA_BARBARA", "University of California, Santa Barbara", entityCampus},
+	{"UC_SANTA_CRUZ", "University of California, Santa Cruz", entityCampus},
+	{"UCOP", "University of California Office of the President", entitySystem},
+	{"LBNL", "Lawrence Berkeley National Laboratory", entityLab},
+	{"LLNL", "Lawrence Livermore National Laboratory", entityLab},
+	{"LANL", "Los Alamos National Laboratory", entityLab},
+	{"UC_HEALTH_DAVIS", "UC Davis Health", entityHealth},
+	{"UC_HEALTH_IRVINE", "UC Irvine Health", entityHealth},
+	{"UC_HEALTH_LOS_ANGELES", "UCLA Health", entityHealth},
+	{"UC_HEALTH_RIVERSIDE", "UCR Health", entityHealth},
+	{"UC_HEALTH_SAN_DIEGO", "UC San Diego Health", entityHealth},
+	{"UC_HEALTH_SAN_FRANCISCO", "UCSF Health", entityHealth},
+	{notUCKey, "Not University of California", entityNotUC},
+}
+
+var entityByKey = func() map[string]Entity {
+	entities := make(map[string]Entity, len(canonicalEntities))
+	for _, entity := range canonicalEntities {
+		entities[entity.Key] = entity
+	}
+	return entities
+}()
+
+// National laboratories recognized anywhere in a recipient name
+var labNames = map[string]string{
+	"LAWRENCE BERKELEY NATIONAL":  "LBNL",
+	"LAWRENCE LIVERMORE NATIONAL": "LLNL",
+	"LOS ALAMOS NATIONAL":         "LANL",
+}
+
+// Words after the campus that mark the health system rather than the campus
+var healthWords = []string{"HEALTH", "MEDICAL CENTER", "HOSPITAL"}
+
+// Rules that can resolve an award, as reported in Resolution.Rule
+const (
+	ruleAwardID   = "award_id"
+	ruleUEI       = "recipient_uei"
+	ruleAlias     = "name_alias"
+	ruleName      = "name"
+	ruleParentUEI = "parent_uei"
+	ruleCity      = "city"
+	ruleUnmatched = "unmatched"
+)
+
+// Resolution is the canonical entity of an award and how it was found.
+type Resolution struct {
+	Entity Entity
+	Rule   string
+	Reason string // Why the award was placed, for the quarantine report
+}
+
+// RecipientAliases is the on-disk alias table. Every value is an entity key.
+type RecipientAliases struct {
+	AwardIDs map[string]string `json:"award_ids"` // Awards made to the Regents for a lab, e.g. its operating contract
+	UEIs     map[string]string `json:"ueis"`      // Recipient or parent UEI
+	Names    map[string]string `json:"names"`     // Recipient names the name rules get wrong
+	Cities   map[string]string `json:"cities"`    // Recipient city for names without a campus
+}
+
+//go:embed recipient_aliases.json
+var defaultAliasData []byte
+
+// RecipientResolver maps awards to canonical entities using recipient UEIs,
+// parent UEIs, an alias table and the campus in the recipient name.
+type RecipientResolver struct {
+	awardIDs map[string]string
+	ueis     map[string]string
+	names    map[string]string
+	cities   map[string]string
+}
+
+// defaultRecipientResolver uses the alias table built into the binary.
+func defaultRecipientResolver() *RecipientResolver {
+	resolver, err := parseRecipientAliases(defaultAliasData, "built-in alias table")
+	if err != nil {
+		panic(err)
+	}
+	return resolver
+}
+
+// loadRecipientResolver reads an alias table, or uses the built-in one when
+// filename is empty.
+func loadRecipientResolver(filename string) (*RecipientResolver, error) {
+	if filename == "" {
+		return defaultRecipientResolver(), nil
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error reading alias table: %w", err)
+	}
+	return parseRecipientAliases(data, filename)
+}
+
+func parseRecipientAliases(data []byte, source string) (*RecipientResolver, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var aliases RecipientAliases
+	if err := decoder.Decode(&aliases); err != nil {
+		return nil, fmt.Errorf("error decoding alias table %s: %w", source, err)
+	}
+
+	resolver := &RecipientResolver{
+		awardIDs: make(map[string]string),
+		ueis:     make(map[string]string),
+		names:    make(map[string]string),
+		cities:   make(map[string]string),
+	}
+	tables := []struct {
+		name string
+		from map[string]string
+		to   map[string]string
+	}{
+		{"award_ids", aliases.AwardIDs, resolver.awardIDs},
+		{"ueis", aliases.UEIs, resolver.ueis},
+		{"names", aliases.Names, resolver.names},
+		{"cities", aliases.Cities, resolver.cities},
+	}
+	for _, table := range tables {
+		for alias, key := range table.from {
+			if _, ok := entityByKey[key]; !ok {
+				return nil, fmt.Errorf("alias table %s: %s entry %q maps to unknown entity %q", source, table.name, alias, key)
+			}
+			normalized := normalizeRecipientName(alias)
+			if previous, ok := table.to[normalized]; ok && previous != key {
+				return nil, fmt.Errorf("alias table %s: %s entry %q conflicts with another spelling", source, table.name, alias)
+			}
+			table.to[normalized] = key
+		}
+	}
+	return resolver, nil
+}
+
+// normalizeRecipientName upper-cases a name and reduces punctuation and runs
+// of spaces to single spaces, so "UNIVERSITY OF CALIFORNIA, SAN DIEGO" and
+// "UNIVERSITY OF CALIFORNIA-SAN DIEGO" compare equal.
+func normalizeRecipientName(name string) string {
+	fields := strings.FieldsFunc(strings.ToUpper(name), func(r rune) bool {
+		return !(r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '&')
+	})
+	return strings.Join(fields, " ")
+}
+
+// recipientRef is what the resolver reads from an award.
+type recipientRef struct {
+	awardID   string
+	name      string
+	uei       string
+	parentUEI string
+	city      string
+}
+
+func awardRecipient(award *EnhancedAward) recipientRef {
+	basic := &award.BasicData
+	ref := recipientRef{
+		awardID: basic.AwardID,
+		name:    basic.RecipientName,
+		uei:     basic.RecipientUEI,
+		city:    basic.RecipientLocation.CityName,
+	}
+	if award.DetailedData != nil {
+		recipient := award.DetailedData.Common().Recipient
+		if ref.name == "" {
+			ref.name = recipient.RecipientName
+		}
+		if ref.uei == "" {
+			ref.uei = recipient.RecipientUEI
+		}
+		if ref.city == "" {
+			ref.city = recipient.Location.CityName
+		}
+		ref.parentUEI = recipient.ParentRecipientUEI
+	}
+	return ref
+}
+
+// Resolve maps an award to its canonical entity. The rules are tried in
+// order: the award ID, the recipient UEI, the name alias table, the campus in the name,
+// the parent UEI, and for names that say UC without a campus, the city.
+// Anything else is not UC.
+func (r *RecipientResolver) Resolve(award *EnhancedAward) Resolution {
+	return r.resolve(awardRecipient(award))
+}
+
+func (r *RecipientResolver) resolve(ref recipientRef) Resolution {
+	found := func(key, rule, reason string) Resolution {
+		return Resolution{Entity: entityByKey[key], Rule: rule, Reason: reason}
+	}
+
+	if key, ok := r.awardIDs[normalizeRecipientName(ref.awardID)]; ok && ref.awardID != "" {
+		return found(key, ruleAwardID, "award "+ref.awardID+" is in the alias table")
+	}
+
+	uei := strings.ToUpper(strings.TrimSpace(ref.uei))
+	if key, ok := r.ueis[uei]; ok && uei != "" {
+		return found(key, ruleUEI, "recipient UEI "+uei+" is in the alias table")
+	}
+
+	name := normalizeRecipientName(ref.name)
+	if key, ok := r.names[name]; ok {
+		return found(key, ruleAlias, "recipient name is in the alias table")
+	}
+
+	key, generic := nameEntity(name)
+	if key != "" {
+		return found(key, ruleName, "recipient name names the entity")
+	}
+
+	parentUEI := strings.ToUpper(strings.TrimSpace(ref.parentUEI))
+	parentKey, parentKnown := r.ueis[parentUEI]
+	if parentKnown && parentUEI != "" {
+		// The Regents' own UEI is the parent of campus units, so a UCOP
+		// parent only says the award is UC; the city may still name a campus
+		if parentKey != "UCOP" {
+			return found(parentKey, ruleParentUEI, "parent UEI "+parentUEI+" is in the alias table")
+		}
+		generic = true
+	}
+
+	if generic {
+		city := normalizeRecipientName(ref.city)
+		if key, ok := r.cities[city]; ok {
+			return found(key, ruleCity, "UC recipient without a campus in its name, located in "+city)
+		}
+		if parentKey == "UCOP" {
+			return found(parentKey, ruleParentUEI, "parent UEI "+parentUEI+" is the UC Office of the President")
+		}
+		return found(notUCKey, ruleUnmatched, fmt.Sprintf("UC-like name without a campus and unknown city %q; add the UEI to the alias table", ref.city))
+	}
+
+	if parentUEI != "" {
+		return found(notUCKey, ruleUnmatched, "name is not a known UC entity and parent UEI "+parentUEI+" is not in the alias table")
+	}
+	return found(notUCKey, ruleUnmatched, "name is not a known UC entity")
+}
+
+// nameEntity reads the entity from a normalized recipient name. It returns
+// the key, or "" with generic set when the name is UC's but names no campus,
+// as in "THE REGENTS OF THE UNIVERSITY OF CALIFORNIA".
+func nameEntity(name string) (key string, generic bool) {
+	for lab, key := range labNames {
+		if strings.Contains(name, lab) {
+			return key, false
+		}
+	}
+
+	name = strings.TrimSuffix(name, " THE")
+	for _, prefix := range []string{"THE ", "REGENTS OF THE ", "REGENTS OF ", "REGENTS "} {
+		name = strings.TrimPrefix(name, prefix)
+	}
+
+	var rest string
+	switch {
+	case name == "UNIVERSITY OF CALIFORNIA" || strings.HasPrefix(name, "UNIVERSITY OF CALIFORNIA "):
+		rest = strings.TrimPrefix(name, "UNIVERSITY OF CALIFORNIA")
+	case strings.HasPrefix(name, "UC "):
+		rest = strings.TrimPrefix(name, "UC")
+	default:
+		return "", false
+	}
+	rest = strings.TrimPrefix(strings.TrimSpace(rest), "AT ")
+	if strings.TrimFunc(rest, func(r rune) bool { return r >= '0' && r <= '9' || r == ' ' }) == "" {
+		return "", true // Nothing but a unit number
+	}
+	if strings.HasPrefix(rest, "OFFICE OF THE PRESIDENT") {
+		return "UCOP", false
+	}
+
+	campus, tail := "", ""
+	for campusName, campusKey := range campusNames {
+		if rest == campusName || strings.HasPrefix(rest, campusName+" ") {
+			campus, tail = campusKey, strings.TrimPrefix(rest, campusName)
+			break
+		}
+	}
+	if campus == "" {
+		// Names cut off by the source system, like "UNIVERSITY OF CALIFORNIA, IRVI"
+		var matches []string
+		for campusName, campusKey := range campusNames {
+			if len(rest) >= 3 && strings.HasPrefix(campusName, rest) {
+				matches = append(matches, campusKey)
+			}
+		}
+		if len(matches) != 1 {
+			return "", len(matches) > 1
+		}
+		campus = matches[0]
+	}
+
+	if health, ok := healthSystems[campus]; ok {
+		for _, word := range healthWords {
+			if strings.Contains(tail, word) {
+				return health, false
+			}
+		}
+	}
+	return campus, false
+}
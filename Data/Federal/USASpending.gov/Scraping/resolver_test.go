@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveRecipients(t *testing.T) {
+	resolver := defaultRecipientResolver()
+	tests := []struct {
+		ref  recipientRef
+		want string
+		rule string
+	}{
+		// Keyword false positives
+		{recipientRef{name: "SAN DIEGO STATE UNIVERSITY", uei: "T1MZN1RCNP33", parentUEI: "NUDGYLBB4S99"}, notUCKey, ruleUEI},
+		{recipientRef{name: "DOMINICAN UNIVERSITY OF CALIFORNIA"}, notUCKey, ruleAlias},
+		{recipientRef{name: "CHULA VISTA, CITY OF", city: "CHULA VISTA"}, notUCKey, ruleUnmatched},
+		{recipientRef{name: "CSU FULLERTON AUXILIARY SERVICES CORPORATION", parentUEI: "JW7YN4NDAHC1"}, notUCKey, ruleUnmatched},
+		{recipientRef{name: "FOOD & AGRICULTURE, CALIFORNIA DEPARTMENT OF", parentUEI: "NUDGYLBB4S99"}, notUCKey, ruleUnmatched},
+		{recipientRef{name: "UNIVERSITY OF SOUTHERN CALIFORNIA"}, notUCKey, ruleAlias},
+
+		// Spelling variants of one campus
+		{recipientRef{name: "UNIVERSITY OF CALIFORNIA, IRVI"}, "UC_IRVINE", ruleName},
+		{recipientRef{name: "UNIVERSITY OF CALIFORNIA IRVINE"}, "UC_IRVINE", ruleName},
+		{recipientRef{name: "UNIVERSITY OF CALIFORNIA-SAN DIEGO"}, "UC_SAN_DIEGO", ruleName},
+		{recipientRef{name: "REGENTS OF THE UNIVERSITY OF CALIFORNIA, SAN FRANCISCO, THE"}, "UC_SAN_FRANCISCO", ruleName},
+		{recipientRef{name: "REGENTS OF THE UNIVERSITY OF CALIFORNIA AT RIVERSIDE"}, "UC_RIVERSIDE", ruleName},
+		{recipientRef{name: "UC-DAVIS", city: "WINTERS"}, "UC_DAVIS", ruleName},
+		{recipientRef{name: "DAVIS UNIVERSITY OF CALIFORNIA AT"}, "UC_DAVIS", ruleAlias},
+		{recipientRef{name: "REGENTS OF THE UNIVERSITY OF CALIFORNIA - SB"}, "UC_SANTA_BARBARA", ruleAlias},
+		{recipientRef{name: "UNIVERSITY OF CALIFORNIA OFFICE OF THE PRESIDENT"}, "UCOP", ruleName},
+		{recipientRef{name: "UC SAN DIEGO HEALTH"}, "UC_HEALTH_SAN_DIEGO", ruleName},
+		{recipientRef{name: "LAWRENCE BERKELEY NATIONAL LABORATORY"}, "LBNL", ruleName},
+
+		// Names without a campus
+		{recipientRef{name: "REGENTS OF THE UNIVERSITY OF CALIFORNIA, THE", uei: "GS3YEVSS12N6"}, "UC_BERKELEY", ruleUEI},
+		{recipientRef{name: "REGENTS OF THE UNIVERSITY OF CALIFORNIA", city: "Davis"}, "UC_DAVIS", ruleCity},
+		{recipientRef{name: "UNIVERSITY OF CALIFORNIA, SAN", city: "SAN FRANCISCO"}, "UC_SAN_FRANCISCO", ruleCity},
+		{recipientRef{name: "THE REGENTS OF THE UNIVERSITY OF CALIFORNIA", city: "FRESNO"}, notUCKey, ruleUnmatched},
+		{recipientRef{name: "THE REGENTS OF THE UNIVERSITY OF CALIFORNIA", parentUEI: "PKK5TD16N4H1", city: "FRESNO"}, "UCOP", ruleParentUEI},
+		{recipientRef{awardID: "DEAC0205CH11231", name: "THE REGENTS OF THE UNIVERSITY OF CALIFORNIA", uei: "PKK5TD16N4H1"}, "LBNL", ruleAwardID},
+
+		// A unit with its own UEI under a campus
+		{recipientRef{name: "SCRIPPS INSTITUTION OF OCEANOGRAPHY", parentUEI: "UYTTZT6G9DT1"}, "UC_SAN_DIEGO", ruleParentUEI},
+	}
+
+	for _, tt := range tests {
+		got := resolver.resolve(tt.ref)
+		if got.Entity.Key != tt.want || got.Rule != tt.rule {
+			t.Errorf("resolve(%+v) = %s by %s, want %s by %s", tt.ref, got.Entity.Key, got.Rule, tt.want, tt.rule)
+		}
+		if got.Reason == "" {
+			t.Errorf("resolve(%+v) gave no reason", tt.ref)
+		}
+	}
+}
+
+func TestAliasTableRejectsUnknownEntity(t *testing.T) {
+	_, err := parseRecipientAliases([]byte(`{"ueis":{"ABC123":"UC_FRESNO"}}`), "test")
+	if err == nil || !strings.Contains(err.Error(), "UC_FRESNO") {
+		t.Errorf("err = %v, want an unknown entity error", err)
+	}
+}
+
+func TestEnrichQuarantinesNonUCAwards(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/v2/awards/"), "/")
+		fmt.Fprintf(w, `{"generated_unique_award_id":%q,"date_signed":"2021-07-01"}`, id)
+	}))
+	defer server.Close()
+
+	s := newTestScraper(server.URL, 1)
+	s.outputRoot = t.TempDir()
+	s.quarantine = newQuarantine(s.outputRoot)
+
+	awards := []Award{
+		{GeneratedInternalID: "ASST_NON_UCSD", RecipientName: "UNIVERSITY OF CALIFORNIA, SAN DIEGO", AwardingAgency: "National Science Foundation"},
+		{GeneratedInternalID: "ASST_NON_SDSU", RecipientName: "SAN DIEGO STATE UNIVERSITY", RecipientUEI: "T1MZN1RCNP33", AwardAmount: MoneyFromCents(150000)},
+	}
+	saved, err := s.enrichGroupAwards(context.Background(), "grants", awards)
+	if err != nil {
+		t.Fatalf("enrichGroupAwards: %v", err)
+	}
+	if saved != 1 {
+		t.Errorf("saved %d awards, want 1", saved)
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(s.outputRoot, "Grants", "*", "*", "*", "*.json"))
+	if len(matches) != 1 || !strings.Contains(matches[0], filepath.Join("Grants", "UC_SAN_DIEGO", "2021", "National_Science_Foundation")) {
+		t.Errorf("saved files = %v, want one under Grants/UC_SAN_DIEGO", matches)
+	}
+
+	report, err := readQuarantineReport(filepath.Join(s.outputRoot, quarantineFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Awards) != 1 {
+		t.Fatalf("quarantine report has %d awards, want 1", len(report.Awards))
+	}
+	entry := report.Awards[0]
+	if entry.GeneratedInternalID != "ASST_NON_SDSU" || entry.Rule != ruleUEI || entry.Amount.String() != "1500.00" {
+		t.Errorf("quarantined %+v", entry)
+	}
+	if s.summary.excluded["grants"] != 1 {
+		t.Errorf("summary counts %d quarantined awards, want 1", s.summary.excluded["grants"])
+	}
+
+	// A second exclusion of the same award replaces its entry
+	if err := s.quarantine.add(entry); err != nil {
+		t.Fatal(err)
+	}
+	if report, _ := readQuarantineReport(filepath.Join(s.outputRoot, quarantineFile)); len(report.Awards) != 1 {
+		t.Errorf("re-quarantined award was listed %d times", len(report.Awards))
+	}
+	if _, err := os.Stat(filepath.Join(s.outputRoot, "Grants", "SAN_DIEGO_STATE_UNIVERSITY")); !os.IsNotExist(err) {
+		t.Errorf("not-UC award was saved into the tree")
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how API calls are retried on transient failures.
+type RetryPolicy struct {
+	MaxAttempts int           // Total attempts including the first; 1 disables retries
+	BaseDelay   time.Duration // Backoff before the second attempt, doubled after each failure
+	MaxDelay    time.Duration // Upper bound on any single wait, including Retry-After
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 5,
+		BaseDelay:   2 * time.Second,
+		MaxDelay:    2 * time.Minute,
+	}
+}
+
+// HTTPError is a non-200 response from the API.
+type HTTPError struct {
+	StatusCode int
+	Body       string
+	RetryAfter time.Duration // Parsed Retry-After header, zero if absent
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("API returned status %d: %s", e.StatusCode, e.Body)
+}
+
+// isTransient reports whether a failed call is worth retrying: rate limiting,
+// gateway errors and network timeouts. Other 4xx/5xx responses fail fast.
+func isTransient(err error) bool {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		switch httpErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway,
+			http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// backoff returns the wait before the given retry (1 for the first retry),
+// using exponential backoff with jitter in [d/2, d].
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < retry && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// wait returns how long to pause after err before the given retry. A
+// Retry-After header takes precedence over the computed backoff.
+func (p RetryPolicy) wait(err error, retry int) time.Duration {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) && httpErr.RetryAfter > 0 {
+		if p.MaxDelay > 0 && httpErr.RetryAfter > p.MaxDelay {
+			return p.MaxDelay
+		}
+		return httpErr.RetryAfter
+	}
+	return p.backoff(retry)
+}
+
+// parseRetryAfter accepts either delay-seconds or an HTTP date.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if d := date.Sub(now); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// doWithRetry sends the request built by newRequest until it succeeds, fails
+// with a non-transient error or runs out of attempts, and returns the body of
+// the 200 response. newRequest is called once per attempt so bodies can be
+// replayed.
+func (s *Scraper) doWithRetry(ctx context.Context, newRequest func() (*http.Request, error)) ([]byte, error) {
+	maxAttempts := s.retry.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if err := s.limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+
+		req, err := newRequest()
+		if err != nil {
+			return nil, fmt.Errorf("error creating request: %w", err)
+		}
+
+		body, err := s.doOnce(req)
+		if err == nil {
+			return body, nil
+		}
+		lastErr = err
+
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if !isTransient(err) || attempt == maxAttempts {
+			break
+		}
+
+		wait := s.retry.wait(err, attempt)
+		log.Printf("Retrying %s %s in %v (attempt %d/%d): %v",
+			req.Method, req.URL, wait.Round(time.Millisecond), attempt+1, maxAttempts, err)
+
+		if err := sleepContext(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, lastErr
+}
+
+// doOnce performs a single HTTP round trip and returns the body of a 200
+// response or an *HTTPError.
+func (s *Scraper) doOnce(req *http.Request) ([]byte, error) {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error making request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &HTTPError{
+			StatusCode: resp.StatusCode,
+			Body:       string(body),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
+	}
+
+	return body, nil
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// scriptedResponse is one canned reply from a scriptedServer.
+type scriptedResponse struct {
+	status     int
+	body       string
+	retryAfter string
+	delay      time.Duration
+}
+
+// scriptedServer replies with each scripted response in turn and repeats
+// the last one once the script runs out.
+type scriptedServer struct {
+	*httptest.Server
+	mu     sync.Mutex
+	script []scriptedResponse
+	calls  int
+}
+
+func newScriptedServer(t *testing.T, script ...scriptedResponse) *scriptedServer {
+	t.Helper()
+	s := &scriptedServer{script: script}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		step := s.script[len(s.script)-1]
+		if s.calls < len(s.script) {
+			step = s.script[s.calls]
+		}
+		s.calls++
+		s.mu.Unlock()
+
+		if step.delay > 0 {
+			time.Sleep(step.delay)
+		}
+		if step.retryAfter != "" {
+			w.Header().Set("Retry-After", step.retryAfter)
+		}
+		w.WriteHeader(step.status)
+		w.Write([]byte(step.body))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *scriptedServer) callCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls
+}
+
+func newTestScraper(serverURL string, maxAttempts int) *Scraper {
+	return NewScraper(ScraperOptions{
+		OutputRoot: "unused",
+		APIBase:    serverURL,
+		Retry: RetryPolicy{
+			MaxAttempts: maxAttempts,
+			BaseDelay:   time.Millisecond,
+			MaxDelay:    10 * time.Millisecond,
+		},
+	})
+}
+
+func TestMakeRequestRetriesTransientStatuses(t *testing.T) {
+	server := newScriptedServer(t,
+		scriptedResponse{status: http.StatusServiceUnavailable},
+		scriptedResponse{status: http.StatusTooManyRequests, retryAfter: "0"},
+		scriptedResponse{status: http.StatusBadGateway},
+		scriptedResponse{status: http.StatusOK, body: `{"results":[{"Award ID":"R01"}],"page_metadata":{"page":1}}`},
+	)
+	s := newTestScraper(server.URL, 5)
+
+	response, err := s.makeRequest(context.Background(), s.createRequest("grants", awardTypeGroups["grants"]))
+	if err != nil {
+		t.Fatalf("makeRequest: %v", err)
+	}
+	if len(response.Results) != 1 || response.Results[0].AwardID != "R01" {
+		t.Errorf("unexpected results: %+v", response.Results)
+	}
+	if got := server.callCount(); got != 4 {
+		t.Errorf("server saw %d calls, want 4", got)
+	}
+}
+
+func TestMakeRequestFailsFastOnValidationError(t *testing.T) {
+	server := newScriptedServer(t,
+		scriptedResponse{status: http.StatusUnprocessableEntity, body: `{"detail":"Field 'filters' is missing"}`},
+		scriptedResponse{status: http.StatusOK, body: `{"results":[]}`},
+	)
+	s := newTestScraper(server.URL, 5)
+
+	_, err := s.makeRequest(context.Background(), s.createRequest("grants", awardTypeGroups["grants"]))
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 HTTPError, got %v", err)
+	}
+	if got := server.callCount(); got != 1 {
+		t.Errorf("server saw %d calls, want 1", got)
+	}
+}
+
+func TestMakeRequestGivesUpAfterMaxAttempts(t *testing.T) {
+	server := newScriptedServer(t, scriptedResponse{status: http.StatusGatewayTimeout})
+	s := newTestScraper(server.URL, 3)
+
+	_, err := s.makeRequest(context.Background(), s.createRequest("loans", awardTypeGroups["loans"]))
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusGatewayTimeout {
+		t.Fatalf("expected 504 HTTPError, got %v", err)
+	}
+	if got := server.callCount(); got != 3 {
+		t.Errorf("server saw %d calls, want 3", got)
+	}
+}
+
+func TestFetchDetailedAwardRetriesTimeouts(t *testing.T) {
+	server := newScriptedServer(t,
+		scriptedResponse{status: http.StatusOK, body: `{}`, delay: 200 * time.Millisecond},
+		scriptedResponse{status: http.StatusOK, body: `{"generated_unique_award_id":"ASST_NON_R01_075","category":"grant"}`},
+	)
+	s := newTestScraper(server.URL, 2)
+	s.client.Timeout = 50 * time.Millisecond
+
+	detail, err := s.fetchDetailedAward(context.Background(), "ASST_NON_R01_075")
+	if err != nil {
+		t.Fatalf("fetchDetailedAward: %v", err)
+	}
+	if detail.Common().GeneratedUniqueAwardID != "ASST_NON_R01_075" {
+		t.Errorf("unexpected detail: %+v", detail)
+	}
+	if got := server.callCount(); got != 2 {
+		t.Errorf("server saw %d calls, want 2", got)
+	}
+}
+
+func TestFetchDetailedAwardDoesNotRetryNotFound(t *testing.T) {
+	server := newScriptedServer(t, scriptedResponse{status: http.StatusNotFound, body: `{"detail":"not found"}`})
+	s := newTestScraper(server.URL, 4)
+
+	if _, err := s.fetchDetailedAward(context.Background(), "CONT_AWD_MISSING"); err == nil {
+		t.Fatal("expected an error for 404")
+	}
+	if got := server.callCount(); got != 1 {
+		t.Errorf("server saw %d calls, want 1", got)
+	}
+}
+
+func TestRetryStopsWhenContextCancelled(t *testing.T) {
+	server := newScriptedServer(t, scriptedResponse{status: http.StatusTooManyRequests, retryAfter: "30"})
+	s := newTestScraper(server.URL, 5)
+	s.retry.MaxDelay = time.Minute
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := s.fetchDetailedAward(ctx, "CONT_AWD_SLOW")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context deadline, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("retry wait ignored cancellation (took %v)", elapsed)
+	}
+}
+
+func TestRetryWaitHonorsRetryAfter(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Minute}
+
+	err := &HTTPError{StatusCode: http.StatusTooManyRequests, RetryAfter: 7 * time.Second}
+	if got := policy.wait(err, 1); got != 7*time.Second {
+		t.Errorf("wait = %v, want 7s", got)
+	}
+
+	policy.MaxDelay = 2 * time.Second
+	if got := policy.wait(err, 1); got != 2*time.Second {
+		t.Errorf("wait = %v, want capped 2s", got)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 9, 23, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"5", 5 * time.Second},
+		{"-1", 0},
+		{"Tue, 23 Sep 2025 12:00:30 GMT", 30 * time.Second},
+		{"Tue, 23 Sep 2025 11:59:00 GMT", 0},
+		{"soon", 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestBackoffGrowsAndIsCapped(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 10, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for retry := 1; retry <= 8; retry++ {
+		want := 100 * time.Millisecond << (retry - 1)
+		if want > time.Second {
+			want = time.Second
+		}
+		got := policy.backoff(retry)
+		if got < want/2 || got > want {
+			t.Errorf("backoff(%d) = %v, want within [%v, %v]", retry, got, want/2, want)
+		}
+	}
+}
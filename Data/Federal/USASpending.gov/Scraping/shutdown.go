@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// errInterrupted is returned by run when SIGINT or SIGTERM stopped a command.
+var errInterrupted = errors.New("interrupted")
+
+// signalContext returns a context cancelled by the first SIGINT or SIGTERM.
+// After that the default handlers are restored, so a second Ctrl-C exits
+// immediately instead of waiting for in-flight writes.
+func signalContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx, stop
+}
+
+// sleepContext pauses for d or until ctx is cancelled, whichever comes first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// runSummary counts what a run wrote so it can be reported on exit, including
+// after an interruption. It is safe for concurrent use by the workers.
+type runSummary struct {
+	mu        sync.Mutex
+	saved     map[string]int // Award files written per group
+	basicOnly map[string]int // Of those, files saved without detail
+	excluded  map[string]int // Awards quarantined as not UC per group
+	undated   map[string]int // Awards left out for want of a usable date per group
+	failed    int            // Awards whose file could not be written
+	dumps     []string       // Search dump files written
+}
+
+func newRunSummary() *runSummary {
+	return &runSummary{
+		saved:     make(map[string]int),
+		basicOnly: make(map[string]int),
+		excluded:  make(map[string]int),
+		undated:   make(map[string]int),
+	}
+}
+
+func (r *runSummary) recordSaved(groupName string, withDetail bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.saved[groupName]++
+	if !withDetail {
+		r.basicOnly[groupName]++
+	}
+}
+
+func (r *runSummary) recordQuarantined(groupName string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.excluded[groupName]++
+}
+
+func (r *runSummary) recordUndated(groupName string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.undated[groupName]++
+}
+
+func (r *runSummary) recordFailed() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failed++
+}
+
+func (r *runSummary) recordDump(path string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.dumps = append(r.dumps, path)
+}
+
+// printSummary logs what was saved. Called once the command returns, whether
+// it finished, failed or was interrupted.
+func (s *Scraper) printSummary(ctx context.Context) {
+	if s.dryRun {
+		return
+	}
+	r := s.summary
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if ctx.Err() != nil {
+		log.Printf("Interrupted; all in-flight writes have finished")
+	}
+	log.Printf("Run summary:")
+	total, excluded, undated := 0, 0, 0
+	for _, groupName := range awardTypeGroupOrder {
+		saved := r.saved[groupName]
+		if saved == 0 && r.excluded[groupName] == 0 && r.undated[groupName] == 0 {
+			continue
+		}
+		total += saved
+		excluded += r.excluded[groupName]
+		undated += r.undated[groupName]
+		log.Printf("  %s: %d award files saved (%d without detail), %d not UC, %d without a usable date",
+			groupName, saved, r.basicOnly[groupName], r.excluded[groupName], r.undated[groupName])
+	}
+	log.Printf("  Total award files saved: %d", total)
+	if excluded > 0 {
+		log.Printf("  Awards quarantined as not UC: %d (see %s)", excluded, s.quarantine.path)
+	}
+	if undated > 0 {
+		log.Printf("  Awards left out without a usable date: %d (see %s)", undated, s.undated.path)
+	}
+	for _, path := range r.dumps {
+		log.Printf("  Search dump: %s", path)
+	}
+	if r.failed > 0 {
+		log.Printf("  Awards that could not be written: %d", r.failed)
+	}
+	if cache, ok := s.client.Transport.(*ResponseCache); ok {
+		cache.logStats()
+	}
+	if ctx.Err() != nil && s.checkpoint != nil {
+		log.Printf("Progress is kept in %s; rerun with -resume to continue", s.checkpoint.dir)
+	}
+}
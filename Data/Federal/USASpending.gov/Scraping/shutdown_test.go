@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSearchDelayStopsOnCancel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/spending_by_award_count/") {
+			fmt.Fprint(w, `{"results":{"grants":3}}`)
+			return
+		}
+		fmt.Fprint(w, `{"results":[{"generated_internal_id":"ASST_NON_01"}],"page_metadata":{"page":1,"hasNext":true,"last_record_unique_id":1,"last_record_sort_value":"1"}}`)
+	}))
+	defer server.Close()
+
+	s := newTestScraper(server.URL, 1)
+	s.delay = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := s.scrapeGroupData(ctx, "grants", awardTypeGroups["grants"])
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("page delay ignored cancellation (took %v)", elapsed)
+	}
+}
+
+func TestCancelledEnrichmentKeepsCompleteFiles(t *testing.T) {
+	var served int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&served, 1) > 3 {
+			<-r.Context().Done()
+			return
+		}
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/v2/awards/"), "/")
+		fmt.Fprintf(w, `{"generated_unique_award_id":%q,"date_signed":"2021-07-01"}`, id)
+	}))
+	defer server.Close()
+
+	s := newTestScraper(server.URL, 1)
+	s.outputRoot = t.TempDir()
+	s.workers = 2
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var written int32
+	onSaved := func(string, *EnhancedAward, string) {
+		if atomic.AddInt32(&written, 1) == 3 {
+			cancel()
+		}
+	}
+
+	awards := make([]Award, 20)
+	for i := range awards {
+		awards[i] = Award{GeneratedInternalID: fmt.Sprintf("ASST_NON_%02d", i), RecipientName: "UNIVERSITY OF CALIFORNIA, IRVINE"}
+	}
+
+	saved, err := s.enrichGroupAwardsFunc(ctx, "grants", awards, onSaved)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+
+	files := 0
+	if err := walkEnhancedAwards(s.outputRoot, "grants", func(path string, award *EnhancedAward) error {
+		files++
+		if award.DetailedData == nil {
+			t.Errorf("%s was saved without detail after cancellation", path)
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("saved files do not parse: %v", err)
+	}
+
+	if files != saved || files != 3 {
+		t.Errorf("%d files on disk, enrichGroupAwardsFunc reported %d, want 3", files, saved)
+	}
+	if got := s.summary.saved["grants"]; got != files {
+		t.Errorf("summary counts %d saved awards, want %d", got, files)
+	}
+}
@@ -0,0 +1,498 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Default database file at the output root
+const defaultSQLiteFile = "awards.sqlite"
+
+// sqliteTables are the tables the export owns, dropped and rebuilt on every
+// export. Other tables and views in the database are left alone.
+var sqliteTables = []string{"defc_amounts", "contract_data", "awards", "naics", "psc", "locations", "agencies", "recipients"}
+
+// sqliteSchema creates the export tables. Amounts are whole cents, since
+// SQLite has no decimal type; dates are ISO 8601 text.
+const sqliteSchema = `
+CREATE TABLE recipients (
+  recipient_id INTEGER PRIMARY KEY,
+  recipient_hash TEXT,
+  uei TEXT,
+  duns TEXT,
+  name TEXT,
+  parent_hash TEXT,
+  parent_uei TEXT,
+  parent_name TEXT,
+  business_categories TEXT
+);
+CREATE TABLE agencies (
+  agency_id INTEGER PRIMARY KEY,
+  toptier_code TEXT,
+  toptier_name TEXT,
+  toptier_abbreviation TEXT,
+  subtier_code TEXT,
+  subtier_name TEXT,
+  subtier_abbreviation TEXT
+);
+CREATE TABLE locations (
+  location_id INTEGER PRIMARY KEY,
+  address_line1 TEXT,
+  city_name TEXT,
+  county_name TEXT,
+  state_code TEXT,
+  state_name TEXT,
+  zip5 TEXT,
+  congressional_code TEXT,
+  country_code TEXT,
+  country_name TEXT
+);
+CREATE TABLE naics (
+  code TEXT PRIMARY KEY,
+  description TEXT
+);
+CREATE TABLE psc (
+  code TEXT PRIMARY KEY,
+  description TEXT
+);
+CREATE TABLE awards (
+  generated_internal_id TEXT PRIMARY KEY,
+  award_group TEXT NOT NULL,
+  award_id TEXT,
+  category TEXT,
+  type TEXT,
+  type_description TEXT,
+  contract_award_type TEXT,
+  description TEXT,
+  campus TEXT,
+  campus_name TEXT,
+  recipient_id INTEGER REFERENCES recipients,
+  awarding_agency_id INTEGER REFERENCES agencies,
+  funding_agency_id INTEGER REFERENCES agencies,
+  recipient_location_id INTEGER REFERENCES locations,
+  place_of_performance_id INTEGER REFERENCES locations,
+  naics_code TEXT REFERENCES naics,
+  psc_code TEXT REFERENCES psc,
+  parent_award_id TEXT,
+  award_amount_cents INTEGER,
+  total_outlays_cents INTEGER,
+  total_obligation_cents INTEGER,
+  base_and_all_options_cents INTEGER,
+  covid19_obligations_cents INTEGER,
+  covid19_outlays_cents INTEGER,
+  infrastructure_obligations_cents INTEGER,
+  infrastructure_outlays_cents INTEGER,
+  loan_value_cents INTEGER,
+  subsidy_cost_cents INTEGER,
+  start_date TEXT,
+  end_date TEXT,
+  date_signed TEXT,
+  last_modified_date TEXT,
+  fiscal_year INTEGER,
+  subaward_count INTEGER,
+  path TEXT
+);
+CREATE TABLE defc_amounts (
+  generated_internal_id TEXT NOT NULL REFERENCES awards,
+  kind TEXT NOT NULL,
+  code TEXT NOT NULL,
+  amount_cents INTEGER,
+  PRIMARY KEY (generated_internal_id, kind, code)
+);
+`
+
+// sqliteIndexes are created after the rows are loaded.
+const sqliteIndexes = `
+CREATE INDEX awards_campus ON awards (campus);
+CREATE INDEX awards_awarding_agency ON awards (awarding_agency_id);
+CREATE INDEX awards_funding_agency ON awards (funding_agency_id);
+CREATE INDEX awards_fiscal_year ON awards (fiscal_year);
+CREATE INDEX awards_recipient ON awards (recipient_id);
+CREATE INDEX recipients_uei ON recipients (uei);
+CREATE INDEX recipients_parent_uei ON recipients (parent_uei);
+`
+
+// contractDataColumns are the contract_data columns after
+// generated_internal_id: one per ContractData field, named by its JSON key.
+func contractDataColumns() []string {
+	t := reflect.TypeOf(ContractData{})
+	columns := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		columns = append(columns, strings.Split(t.Field(i).Tag.Get("json"), ",")[0])
+	}
+	return columns
+}
+
+func contractDataSchema() string {
+	var b strings.Builder
+	b.WriteString("CREATE TABLE contract_data (\n  generated_internal_id TEXT PRIMARY KEY REFERENCES awards")
+	t := reflect.TypeOf(ContractData{})
+	for i, column := range contractDataColumns() {
+		kind := "TEXT"
+		if t.Field(i).Type.Kind() == reflect.Bool {
+			kind = "INTEGER"
+		}
+		fmt.Fprintf(&b, ",\n  %s %s", column, kind)
+	}
+	b.WriteString("\n);\n")
+	return b.String()
+}
+
+// sqlLiteral renders a value as an SQL literal. Empty strings, nil pointers,
+// null amounts and zero dates are NULL.
+func sqlLiteral(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		if v == "" {
+			return "NULL"
+		}
+		return "'" + strings.ReplaceAll(strings.ReplaceAll(v, "\x00", ""), "'", "''") + "'"
+	case *string:
+		if v == nil {
+			return "NULL"
+		}
+		return sqlLiteral(*v)
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case Money:
+		cents, ok := v.Cents()
+		if !ok {
+			return "NULL"
+		}
+		return strconv.FormatInt(cents, 10)
+	case Date:
+		if v.IsZero() {
+			return "NULL"
+		}
+		return sqlLiteral(v.String())
+	}
+	panic(fmt.Sprintf("sqlLiteral: unsupported type %T", v))
+}
+
+// sqlRows assigns row IDs to the distinct values of a lookup table and keeps
+// the values until they are written, so later awards can fill in fields
+// that earlier ones left empty.
+type sqlRows struct {
+	keys []string
+	ids  map[string]int
+	rows map[string][]string
+}
+
+// id returns the row ID for key, adding the row or merging its empty
+// fields. An empty key has no row and gets ID 0, which is written as NULL.
+func (t *sqlRows) id(key string, values ...string) int {
+	if key == "" {
+		return 0
+	}
+	if t.rows == nil {
+		t.ids = make(map[string]int)
+		t.rows = make(map[string][]string)
+	}
+	row, ok := t.rows[key]
+	if !ok {
+		t.keys = append(t.keys, key)
+		t.ids[key] = len(t.keys)
+		t.rows[key] = values
+		return len(t.keys)
+	}
+	for i, value := range values {
+		if row[i] == "" {
+			row[i] = value
+		}
+	}
+	return t.ids[key]
+}
+
+// sqlExport accumulates the lookup tables while the awards stream through.
+type sqlExport struct {
+	w          *bufio.Writer
+	outputRoot string
+	resolver   *RecipientResolver
+
+	recipients, agencies, locations sqlRows
+	naics, psc                      map[string]string
+	awards                          int
+}
+
+// insert writes one INSERT statement.
+func (e *sqlExport) insert(table string, columns []string, values []string) {
+	fmt.Fprintf(e.w, "INSERT INTO %s (%s) VALUES (%s);\n", table, strings.Join(columns, ", "), strings.Join(values, ", "))
+}
+
+func nullID(id int) string {
+	if id == 0 {
+		return "NULL"
+	}
+	return strconv.Itoa(id)
+}
+
+func (e *sqlExport) locationID(l Location) int {
+	if locationIsEmpty(l) {
+		return 0
+	}
+	values := []string{l.AddressLine1, l.CityName, l.CountyName, l.StateCode, l.StateName, l.Zip5, l.CongressionalCode, l.LocationCountryCode, l.CountryName}
+	return e.locations.id(strings.Join(values, "\x1f"), values...)
+}
+
+func (e *sqlExport) agencyID(info AgencyInfo) int {
+	if info.ToptierAgency.Name == "" {
+		return 0
+	}
+	key := strings.ToUpper(info.ToptierAgency.Name) + "\x1f" + strings.ToUpper(info.SubtierAgency.Name)
+	return e.agencies.id(key,
+		info.ToptierAgency.Code, info.ToptierAgency.Name, info.ToptierAgency.Abbreviation,
+		info.SubtierAgency.Code, info.SubtierAgency.Name, info.SubtierAgency.Abbreviation)
+}
+
+func (e *sqlExport) recipientID(recipient RecipientDetail) int {
+	key := recipient.RecipientHash
+	if key == "" && recipient.RecipientUEI != "" {
+		key = "uei:" + recipient.RecipientUEI
+	}
+	if key == "" && recipient.RecipientName != "" {
+		key = "name:" + strings.ToUpper(recipient.RecipientName)
+	}
+	duns := ""
+	if recipient.RecipientUniqueID != nil {
+		duns = *recipient.RecipientUniqueID
+	}
+	return e.recipients.id(key,
+		recipient.RecipientHash, recipient.RecipientUEI, duns, recipient.RecipientName,
+		recipient.ParentRecipientHash, recipient.ParentRecipientUEI, recipient.ParentRecipientName,
+		strings.Join(recipient.BusinessCategories, ";"))
+}
+
+// code adds a NAICS or PSC code to its table and returns it.
+func (e *sqlExport) code(table map[string]string, code HierarchyCode) string {
+	if code.Code != "" && table[code.Code] == "" {
+		table[code.Code] = code.Description
+	}
+	return code.Code
+}
+
+// addAward writes the rows of one award.
+func (e *sqlExport) addAward(groupName, path string, award *EnhancedAward) {
+	a := newExportedAward(groupName, award, e.resolver)
+	basic, detail := a.basic, a.detail
+	id := basic.GeneratedInternalID
+
+	fiscalYear := "NULL"
+	if a.fiscalYear != 0 {
+		fiscalYear = strconv.Itoa(a.fiscalYear)
+	}
+	rel, err := filepath.Rel(e.outputRoot, path)
+	if err != nil {
+		rel = path
+	}
+
+	row := map[string]string{
+		"generated_internal_id":            sqlLiteral(id),
+		"award_group":                      sqlLiteral(groupName),
+		"award_id":                         sqlLiteral(basic.AwardID),
+		"contract_award_type":              sqlLiteral(basic.ContractAwardType),
+		"description":                      sqlLiteral(basic.Description),
+		"campus":                           sqlLiteral(a.entity.Key),
+		"campus_name":                      sqlLiteral(a.entity.Name),
+		"recipient_id":                     nullID(e.recipientID(a.recipient)),
+		"awarding_agency_id":               nullID(e.agencyID(a.awarding)),
+		"funding_agency_id":                nullID(e.agencyID(a.funding)),
+		"recipient_location_id":            nullID(e.locationID(a.recipientLocation)),
+		"place_of_performance_id":          nullID(e.locationID(a.placeOfPerformance)),
+		"naics_code":                       sqlLiteral(e.code(e.naics, a.naics)),
+		"psc_code":                         sqlLiteral(e.code(e.psc, a.psc)),
+		"award_amount_cents":               sqlLiteral(basic.AwardAmount),
+		"total_outlays_cents":              sqlLiteral(basic.TotalOutlays),
+		"covid19_obligations_cents":        sqlLiteral(basic.COVID19Obligations),
+		"covid19_outlays_cents":            sqlLiteral(basic.COVID19Outlays),
+		"infrastructure_obligations_cents": sqlLiteral(basic.InfrastructureObligations),
+		"infrastructure_outlays_cents":     sqlLiteral(basic.InfrastructureOutlays),
+		"loan_value_cents":                 sqlLiteral(basic.LoanValue),
+		"subsidy_cost_cents":               sqlLiteral(basic.SubsidyCost),
+		"start_date":                       sqlLiteral(basic.StartDate),
+		"end_date":                         sqlLiteral(basic.EndDate),
+		"fiscal_year":                      fiscalYear,
+		"path":                             sqlLiteral(filepath.ToSlash(rel)),
+	}
+	if detail != nil {
+		row["category"] = sqlLiteral(detail.Category)
+		row["type"] = sqlLiteral(detail.Type)
+		row["type_description"] = sqlLiteral(detail.TypeDescription)
+		if basic.Description == "" {
+			row["description"] = sqlLiteral(detail.Description)
+		}
+		row["total_obligation_cents"] = sqlLiteral(dollarCents(detail.TotalObligation))
+		row["base_and_all_options_cents"] = sqlLiteral(dollarCents(detail.BaseAndAllOptions))
+		row["date_signed"] = sqlLiteral(detail.DateSigned)
+		row["last_modified_date"] = sqlLiteral(detail.PeriodOfPerformance.LastModifiedDate)
+		row["subaward_count"] = strconv.Itoa(detail.SubawardCount)
+		row["parent_award_id"] = sqlLiteral(detail.ParentAward.ID())
+	}
+	columns := make([]string, 0, len(row))
+	for column := range row {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	values := make([]string, len(columns))
+	for i, column := range columns {
+		values[i] = row[column]
+	}
+	e.insert("awards", columns, values)
+
+	if detail != nil {
+		for _, kind := range []struct {
+			name    string
+			amounts []AccountObligation
+		}{{"obligation", detail.AccountObligationsByDEFC}, {"outlay", detail.AccountOutlaysByDEFC}} {
+			// Codes can repeat within a list; their amounts add up
+			totals := make(map[string]float64)
+			var codes []string
+			for _, amount := range kind.amounts {
+				if _, ok := totals[amount.Code]; !ok {
+					codes = append(codes, amount.Code)
+				}
+				totals[amount.Code] += amount.Amount
+			}
+			for _, code := range codes {
+				e.insert("defc_amounts", []string{"generated_internal_id", "kind", "code", "amount_cents"},
+					[]string{sqlLiteral(id), sqlLiteral(kind.name), sqlLiteral(code), sqlLiteral(dollarCents(totals[code]))})
+			}
+		}
+
+		if data := detail.LatestTransactionContractData; data != nil {
+			columns := append([]string{"generated_internal_id"}, contractDataColumns()...)
+			values := []string{sqlLiteral(id)}
+			v := reflect.ValueOf(*data)
+			for i := 0; i < v.NumField(); i++ {
+				values = append(values, sqlLiteral(v.Field(i).Interface()))
+			}
+			e.insert("contract_data", columns, values)
+		}
+	}
+	e.awards++
+}
+
+// finish writes the lookup tables.
+func (e *sqlExport) finish() {
+	for _, table := range []struct {
+		name    string
+		columns []string
+		rows    *sqlRows
+	}{
+		{"recipients", []string{"recipient_hash", "uei", "duns", "name", "parent_hash", "parent_uei", "parent_name", "business_categories"}, &e.recipients},
+		{"agencies", []string{"toptier_code", "toptier_name", "toptier_abbreviation", "subtier_code", "subtier_name", "subtier_abbreviation"}, &e.agencies},
+		{"locations", []string{"address_line1", "city_name", "county_name", "state_code", "state_name", "zip5", "congressional_code", "country_code", "country_name"}, &e.locations},
+	} {
+		idColumn := strings.TrimSuffix(table.name, "s") + "_id"
+		if table.name == "agencies" {
+			idColumn = "agency_id"
+		}
+		for i, key := range table.rows.keys {
+			values := []string{strconv.Itoa(i + 1)}
+			for _, value := range table.rows.rows[key] {
+				values = append(values, sqlLiteral(value))
+			}
+			e.insert(table.name, append([]string{idColumn}, table.columns...), values)
+		}
+	}
+	for _, table := range []struct {
+		name  string
+		codes map[string]string
+	}{{"naics", e.naics}, {"psc", e.psc}} {
+		codes := make([]string, 0, len(table.codes))
+		for code := range table.codes {
+			codes = append(codes, code)
+		}
+		sort.Strings(codes)
+		for _, code := range codes {
+			e.insert(table.name, []string{"code", "description"}, []string{sqlLiteral(code), sqlLiteral(table.codes[code])})
+		}
+	}
+}
+
+// writeSQLiteScript writes the SQL that rebuilds the export tables from the
+// saved awards, in one transaction. It returns the number of awards.
+func writeSQLiteScript(w io.Writer, outputRoot string, groups []string, resolver *RecipientResolver) (int, error) {
+	e := &sqlExport{
+		w:          bufio.NewWriterSize(w, 1<<16),
+		outputRoot: outputRoot,
+		resolver:   resolver,
+		naics:      make(map[string]string),
+		psc:        make(map[string]string),
+	}
+	fmt.Fprintln(e.w, "BEGIN;")
+	for _, table := range sqliteTables {
+		fmt.Fprintf(e.w, "DROP TABLE IF EXISTS %s;\n", table)
+	}
+	e.w.WriteString(sqliteSchema)
+	e.w.WriteString(contractDataSchema())
+
+	for _, groupName := range groups {
+		err := walkEnhancedAwards(outputRoot, groupName, func(path string, award *EnhancedAward) error {
+			e.addAward(groupName, path, award)
+			return nil
+		})
+		if err != nil {
+			return e.awards, fmt.Errorf("error reading %s: %w", groupName, err)
+		}
+	}
+	e.finish()
+
+	e.w.WriteString(sqliteIndexes)
+	fmt.Fprintln(e.w, "COMMIT;")
+	return e.awards, e.w.Flush()
+}
+
+// exportSQLite loads the saved awards into a SQLite database through the
+// sqlite3 shell. Every export drops and rebuilds its tables in a single
+// transaction, so exporting again gives the same database, and a failed
+// export leaves the previous one in place.
+func exportSQLite(ctx context.Context, outputRoot string, groups []string, resolver *RecipientResolver, dbPath, sqlite3 string) error {
+	if _, err := exec.LookPath(sqlite3); err != nil {
+		return fmt.Errorf("the SQLite export needs the sqlite3 shell (https://sqlite.org/cli.html): %w", err)
+	}
+	if err := ensureDirectoryExists(filepath.Dir(dbPath)); err != nil {
+		return fmt.Errorf("error creating directory: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, sqlite3, "-bail", "-batch", dbPath)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	cmd.Stdout = &stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("error starting %s: %w", sqlite3, err)
+	}
+
+	count, writeErr := writeSQLiteScript(stdin, outputRoot, groups, resolver)
+	stdin.Close()
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("sqlite3 failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if writeErr != nil {
+		return writeErr
+	}
+	log.Printf("Exported %d awards to %s", count, dbPath)
+	return nil
+}
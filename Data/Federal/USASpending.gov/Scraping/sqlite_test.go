@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExportSQLite(t *testing.T) {
+	sqlite3, err := exec.LookPath("sqlite3")
+	if err != nil {
+		t.Skip("sqlite3 shell not installed")
+	}
+	_, s := scrapeFakeAPI(t, "fakeusaspending.json", ScraperOptions{}, 4, "contracts", "grants")
+
+	db := filepath.Join(t.TempDir(), "awards.sqlite")
+	query := func(sql string) string {
+		t.Helper()
+		out, err := exec.Command(sqlite3, "-batch", db, sql).CombinedOutput()
+		if err != nil {
+			t.Fatalf("%s: %v: %s", sql, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+
+	resolver := defaultRecipientResolver()
+	groups := []string{"contracts", "grants"}
+	if err := exportSQLite(context.Background(), s.outputRoot, groups, resolver, db, sqlite3); err != nil {
+		t.Fatal(err)
+	}
+	query("CREATE TABLE notes (award TEXT)")
+	counts := query("SELECT (SELECT count(*) FROM awards), (SELECT count(*) FROM recipients), (SELECT count(*) FROM agencies), (SELECT count(*) FROM contract_data)")
+
+	// Exporting again rebuilds the same tables and keeps other ones
+	if err := exportSQLite(context.Background(), s.outputRoot, groups, resolver, db, sqlite3); err != nil {
+		t.Fatal(err)
+	}
+	if again := query("SELECT (SELECT count(*) FROM awards), (SELECT count(*) FROM recipients), (SELECT count(*) FROM agencies), (SELECT count(*) FROM contract_data)"); again != counts {
+		t.Errorf("counts after re-export = %s, first export %s", again, counts)
+	}
+	if !strings.HasPrefix(counts, "4|") {
+		t.Errorf("counts = %s, want 4 awards", counts)
+	}
+	query("SELECT count(*) FROM notes")
+
+	got := query(`SELECT a.award_group, a.campus, a.fiscal_year, g.toptier_name
+		FROM awards a JOIN agencies g ON g.agency_id = a.awarding_agency_id
+		ORDER BY a.generated_internal_id`)
+	want := strings.Join([]string{
+		"grants|UC_DAVIS|2019|Department of Agriculture",
+		"grants|UC_LOS_ANGELES|2021|National Science Foundation",
+		"grants|UC_SANTA_BARBARA|2007|Department of Health and Human Services",
+		"contracts|UC_SAN_DIEGO|2020|Department of Defense",
+	}, "\n")
+	if got != want {
+		t.Errorf("awards:\n%s\nwant:\n%s", got, want)
+	}
+
+	if got := query("SELECT count(*) FROM awards WHERE recipient_id IS NULL OR awarding_agency_id IS NULL"); got != "0" {
+		t.Errorf("%s awards without a recipient or agency", got)
+	}
+	if got := query("SELECT count(*) FROM sqlite_master WHERE type = 'index' AND name LIKE 'awards_%'"); got != "5" {
+		t.Errorf("%s award indexes, want 5", got)
+	}
+}
+
+func TestSQLLiteral(t *testing.T) {
+	for _, tc := range []struct {
+		value interface{}
+		want  string
+	}{
+		{"", "NULL"},
+		{"O'Brien", "'O''Brien'"},
+		{(*string)(nil), "NULL"},
+		{true, "1"},
+		{MoneyFromCents(-1050), "-1050"},
+		{Money{}, "NULL"},
+		{Date{}, "NULL"},
+		{Date{2020, 3, 1}, "'2020-03-01'"},
+	} {
+		if got := sqlLiteral(tc.value); got != tc.want {
+			t.Errorf("sqlLiteral(%#v) = %s, want %s", tc.value, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"log"
+	"sort"
+)
+
+type recipientCount struct {
+	name  string
+	count int
+}
+
+// topRecipients returns the n recipients with the most awards.
+func topRecipients(counts map[string]int, n int) []recipientCount {
+	list := make([]recipientCount, 0, len(counts))
+	for name, count := range counts {
+		list = append(list, recipientCount{name, count})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].count != list[j].count {
+			return list[i].count > list[j].count
+		}
+		return list[i].name < list[j].name
+	})
+	if len(list) > n {
+		list = list[:n]
+	}
+	return list
+}
+
+// printStats logs award counts and top recipients for the saved award tree.
+func printStats(outputRoot string, groups []string) error {
+	totalAwards := 0
+	totalDetailed := 0
+	allRecipients := make(map[string]int)
+	assistanceListings := make(map[string]int)
+
+	for _, groupName := range groups {
+		groupAwards := 0
+		groupDetailed := 0
+		groupCents := int64(0)
+		recipients := make(map[string]int)
+		withTransactions := 0
+		obligations := make(map[int]int64) // Cents per federal fiscal year
+
+		err := walkEnhancedAwards(outputRoot, groupName, func(path string, award *EnhancedAward) error {
+			groupAwards++
+			if award.DetailedData != nil {
+				groupDetailed++
+			}
+			if cents, ok := award.BasicData.AwardAmount.Cents(); ok {
+				groupCents += cents
+			}
+			if assistance, ok := award.DetailedData.(*AssistanceAwardDetail); ok {
+				for _, number := range assistance.AssistanceListings() {
+					assistanceListings[number]++
+				}
+			}
+			if len(award.Transactions) > 0 {
+				withTransactions++
+				obligationsByYear(award.Transactions, yearsFederal, obligations)
+			}
+			recipients[award.BasicData.RecipientName]++
+			allRecipients[award.BasicData.RecipientName]++
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		log.Printf("[%s] Awards: %d (with details: %d), unique recipients: %d, total award amount: $%s",
+			groupName, groupAwards, groupDetailed, len(recipients), MoneyFromCents(groupCents))
+		if withTransactions > 0 {
+			log.Printf("[%s] Obligations by federal fiscal year, from the transactions of %d awards:", groupName, withTransactions)
+			years := make([]int, 0, len(obligations))
+			for year := range obligations {
+				years = append(years, year)
+			}
+			sort.Ints(years)
+			for _, year := range years {
+				log.Printf("  %s: $%s", yearsFederal.Label(year), MoneyFromCents(obligations[year]))
+			}
+		}
+
+		totalAwards += groupAwards
+		totalDetailed += groupDetailed
+	}
+
+	log.Printf("- Total awards: %d", totalAwards)
+	log.Printf("- Awards with details: %d", totalDetailed)
+	log.Printf("- Unique recipients: %d", len(allRecipients))
+	log.Printf("\nTop recipients by number of awards:")
+	for _, rc := range topRecipients(allRecipients, 5) {
+		log.Printf("  %s: %d awards", rc.name, rc.count)
+	}
+	if len(assistanceListings) > 0 {
+		log.Printf("\nTop Assistance Listings by number of awards:")
+		for _, rc := range topRecipients(assistanceListings, 5) {
+			log.Printf("  %s: %d awards", rc.name, rc.count)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Group dumps written by the search command are named uc_<group>_<date>.json
+// and sit at the top of each group directory.
+const groupDumpPrefix = "uc_"
+
+// latestGroupDump returns the newest search dump for a group, or "" if none exists.
+func latestGroupDump(outputRoot, groupName string) (string, error) {
+	pattern := filepath.Join(groupDirectory(outputRoot, groupName), groupDumpPrefix+groupName+"_*.json")
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return "", fmt.Errorf("error listing search dumps: %w", err)
+	}
+	if len(matches) == 0 {
+		return "", nil
+	}
+
+	// The date suffix is YYYY-MM-DD, so lexical order is chronological
+	sort.Strings(matches)
+	return matches[len(matches)-1], nil
+}
+
+func loadGroupDump(filename string) ([]Award, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", filename, err)
+	}
+
+	var awards []Award
+	if err := json.Unmarshal(data, &awards); err != nil {
+		return nil, fmt.Errorf("error decoding %s: %w", filename, err)
+	}
+	return awards, nil
+}
+
+func loadEnhancedAward(filename string) (*EnhancedAward, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", filename, err)
+	}
+
+	var award EnhancedAward
+	if err := json.Unmarshal(data, &award); err != nil {
+		return nil, fmt.Errorf("error decoding %s: %w", filename, err)
+	}
+	return &award, nil
+}
+
+// isGroupDump reports whether a file in the tree is a search dump rather
+// than a single EnhancedAward.
+func isGroupDump(filename string) bool {
+	return strings.HasPrefix(filepath.Base(filename), groupDumpPrefix)
+}
+
+// walkEnhancedAwards calls fn for every EnhancedAward file saved under a
+// group directory. A missing group directory is not an error.
+func walkEnhancedAwards(outputRoot, groupName string, fn func(path string, award *EnhancedAward) error) error {
+	root := groupDirectory(outputRoot, groupName)
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return nil
+	}
+
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".json" || isGroupDump(path) {
+			return nil
+		}
+
+		award, err := loadEnhancedAward(path)
+		if err != nil {
+			return err
+		}
+		return fn(path, award)
+	})
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Subawards requested per page; the most the endpoint allows
+const subawardPageSize = 100
+
+// File at the output root holding the last subaward flow report
+const subawardFlowFile = "subaward_flows.json"
+
+// Subaward is one subaward a prime recipient made under an award, from
+// /api/v2/subawards/.
+type Subaward struct {
+	ID             int    `json:"id"`
+	SubawardNumber string `json:"subaward_number"`
+	Description    string `json:"description"`
+	ActionDate     Date   `json:"action_date"`
+	Amount         Money  `json:"amount"`
+	RecipientName  string `json:"recipient_name"`
+	RecipientUEI   string `json:"recipient_uei"`
+
+	raw      json.RawMessage // Row as the API sent it
+	mismatch error           // Type mismatch tolerated while decoding
+}
+
+// UnmarshalJSON decodes a subaward row and keeps it verbatim.
+func (s *Subaward) UnmarshalJSON(data []byte) error {
+	type plain Subaward
+	if err := json.Unmarshal(data, (*plain)(s)); err != nil {
+		if err := tolerateTypeError(err, "subaward "+s.SubawardNumber, &s.mismatch); err != nil {
+			return err
+		}
+	}
+	s.raw = captureRaw(data)
+	return nil
+}
+
+// RawJSON returns the JSON the subaward was decoded from.
+func (s *Subaward) RawJSON() json.RawMessage {
+	return s.raw
+}
+
+func (s *Subaward) typeMismatch() error {
+	return s.mismatch
+}
+
+// fetchSubawards pages through the subawards made under a prime award.
+func (s *Scraper) fetchSubawards(ctx context.Context, generatedInternalID string) ([]Subaward, error) {
+	subawards, err := fetchAwardList[Subaward](ctx, s, s.subawardsURL, AwardListRequest{
+		AwardID: generatedInternalID,
+		Page:    1,
+		Limit:   subawardPageSize,
+		Sort:    "subaward_number",
+		Order:   "asc",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error fetching subawards: %w", err)
+	}
+	return subawards, nil
+}
+
+// SubawardRow is a result of the award search at the subaward spending
+// level: one subaward with the prime award and recipient it was made under.
+type SubawardRow struct {
+	SubawardID            string `json:"Sub-Award ID"`
+	SubawardeeName        string `json:"Sub-Awardee Name"`
+	SubrecipientUEI       string `json:"Sub-Recipient UEI"`
+	Date                  Date   `json:"Sub-Award Date"`
+	Amount                Money  `json:"Sub-Award Amount"`
+	Description           string `json:"Sub-Award Description"`
+	AwardingAgency        string `json:"Awarding Agency"`
+	PrimeAwardID          string `json:"Prime Award ID"`
+	PrimeRecipientName    string `json:"Prime Recipient Name"`
+	PrimeRecipientUEI     string `json:"Prime Award Recipient UEI"`
+	PrimeAwardInternalID  string `json:"prime_award_generated_internal_id"`
+	PrimeAwardRecipientID string `json:"prime_award_recipient_id"`
+}
+
+type SubawardSearchResponse struct {
+	Results      []SubawardRow `json:"results"`
+	PageMetadata PageMetadata  `json:"page_metadata"`
+}
+
+var subawardSearchFields = []string{
+	"Sub-Award ID", "Sub-Awardee Name", "Sub-Recipient UEI", "Sub-Award Date", "Sub-Award Amount",
+	"Sub-Award Description", "Awarding Agency", "Prime Award ID", "Prime Recipient Name",
+	"Prime Award Recipient UEI", "prime_award_generated_internal_id", "prime_award_recipient_id",
+}
+
+// Award groups that report subawards: FSRS covers contracts and grants
+var subawardGroups = []string{"contracts", "grants"}
+
+// searchSubawardsToUC finds subawards whose sub-recipient matches "University
+// of California", within the profile's time periods.
+func (s *Scraper) searchSubawardsToUC(ctx context.Context) ([]SubawardRow, error) {
+	var rows []SubawardRow
+	for _, groupName := range subawardGroups {
+		request := APIRequest{
+			Filters: Filters{
+				TimePeriod:          s.profile.Filters.TimePeriod,
+				AwardTypeCodes:      awardTypeGroups[groupName],
+				RecipientSearchText: []string{"University of California"},
+			},
+			Page:          1,
+			Limit:         100,
+			Sort:          "Sub-Award Amount",
+			Order:         "desc",
+			AuditTrail:    "Results Table - Spending by subaward search",
+			Fields:        subawardSearchFields,
+			SpendingLevel: "subawards",
+		}
+
+		periods := request.Filters.TimePeriod
+		if len(periods) == 0 {
+			periods = []TimePeriod{{}}
+		}
+		for _, period := range periods {
+			windowRequest := request
+			windowRequest.Filters.TimePeriod = nil
+			if period != (TimePeriod{}) {
+				windowRequest.Filters.TimePeriod = []TimePeriod{period}
+			}
+			found, err := s.searchSubawardWindow(ctx, groupName, windowRequest)
+			if err != nil {
+				return nil, err
+			}
+			rows = append(rows, found...)
+		}
+	}
+	return rows, nil
+}
+
+// searchSubawardWindow pages through one time window, splitting it in half
+// when it holds more rows than the search result window allows.
+func (s *Scraper) searchSubawardWindow(ctx context.Context, groupName string, request APIRequest) ([]SubawardRow, error) {
+	if s.dryRun {
+		return nil, s.printDryRunRequest(s.baseURL, request)
+	}
+
+	var rows []SubawardRow
+	for {
+		var response SubawardSearchResponse
+		if err := s.postJSON(ctx, s.baseURL, request, &response); err != nil {
+			return nil, fmt.Errorf("error searching %s subawards: %w", groupName, err)
+		}
+		rows = append(rows, response.Results...)
+		if !response.PageMetadata.HasNext || len(response.Results) == 0 {
+			return rows, nil
+		}
+		if (request.Page+1)*request.Limit > searchResultWindow {
+			break
+		}
+		request.Page++
+		if err := sleepContext(ctx, s.delay); err != nil {
+			return nil, err
+		}
+	}
+
+	var period *TimePeriod
+	if len(request.Filters.TimePeriod) == 1 {
+		period = &request.Filters.TimePeriod[0]
+	}
+	halves, ok := splitTimePeriod(period)
+	if !ok {
+		log.Printf("[%s] Warning: more than %d subawards to UC in one window; only the largest were read", groupName, searchResultWindow)
+		return rows, nil
+	}
+	log.Printf("[%s] Subawards to UC for %s..%s exceed the result window; splitting", groupName, period.StartDate, period.EndDate)
+	rows = nil
+	for _, half := range halves {
+		halfRequest := request
+		halfRequest.Page = 1
+		halfRequest.Filters.TimePeriod = []TimePeriod{half}
+		found, err := s.searchSubawardWindow(ctx, groupName, halfRequest)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, found...)
+	}
+	return rows, nil
+}
+
+// SubawardFlow is the money one prime recipient passed to one subrecipient.
+type SubawardFlow struct {
+	Prime           string `json:"prime"`                      // UC entity key, or the prime recipient's name
+	PrimeID         string `json:"prime_id,omitempty"`         // prime_award_recipient_id of a prime that is not UC
+	Subrecipient    string `json:"subrecipient"`               // Subrecipient name, or UC entity key
+	SubrecipientUEI string `json:"subrecipient_uei,omitempty"` // UEI of a subrecipient that is not UC
+	Internal        bool   `json:"internal,omitempty"`         // Both ends are UC
+	Subawards       int    `json:"subawards"`
+	Amount          Money  `json:"amount"`
+
+	cents int64
+}
+
+// SubawardFlowReport is the layout of subaward_flows.json.
+type SubawardFlowReport struct {
+	GeneratedAt string         `json:"generated_at"`
+	Outbound    []SubawardFlow `json:"uc_prime_to_subrecipient"`
+	Inbound     []SubawardFlow `json:"prime_to_uc_subrecipient,omitempty"`
+}
+
+// flowSet sums subawards into flows keyed by their two ends.
+type flowSet map[string]*SubawardFlow
+
+func (f flowSet) add(flow SubawardFlow, amount Money) {
+	key := flow.Prime + "\x00" + flow.PrimeID + "\x00" + flow.Subrecipient + "\x00" + flow.SubrecipientUEI
+	existing := f[key]
+	if existing == nil {
+		existing = &flow
+		f[key] = existing
+	}
+	existing.Subawards++
+	if cents, ok := amount.Cents(); ok {
+		existing.cents += cents
+	}
+}
+
+// list returns the flows, largest amount first.
+func (f flowSet) list() []SubawardFlow {
+	flows := make([]SubawardFlow, 0, len(f))
+	for _, flow := range f {
+		flow.Amount = MoneyFromCents(flow.cents)
+		flows = append(flows, *flow)
+	}
+	sort.Slice(flows, func(i, j int) bool {
+		if flows[i].cents != flows[j].cents {
+			return flows[i].cents > flows[j].cents
+		}
+		if flows[i].Prime != flows[j].Prime {
+			return flows[i].Prime < flows[j].Prime
+		}
+		return flows[i].Subrecipient < flows[j].Subrecipient
+	})
+	return flows
+}
+
+// outboundSubawardFlows sums the saved subawards of UC prime awards by
+// campus and subrecipient. It also returns how many awards had subawards.
+func outboundSubawardFlows(outputRoot string, groups []string, resolver *RecipientResolver) ([]SubawardFlow, int, error) {
+	flows := make(flowSet)
+	primes := 0
+	for _, groupName := range groups {
+		err := walkEnhancedAwards(outputRoot, groupName, func(path string, award *EnhancedAward) error {
+			if len(award.Subawards) == 0 {
+				return nil
+			}
+			prime := resolver.Resolve(award).Entity
+			if !prime.IsUC() {
+				return nil
+			}
+			primes++
+			for _, subaward := range award.Subawards {
+				flow := SubawardFlow{Prime: prime.Key, Subrecipient: subaward.RecipientName, SubrecipientUEI: subaward.RecipientUEI}
+				if sub := resolver.resolve(recipientRef{name: subaward.RecipientName, uei: subaward.RecipientUEI}).Entity; sub.IsUC() {
+					flow.Subrecipient, flow.SubrecipientUEI, flow.Internal = sub.Key, "", true
+				}
+				flows.add(flow, subaward.Amount)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	return flows.list(), primes, nil
+}
+
+// inboundSubawardFlows sums subawards made to UC by campus and prime
+// recipient. Rows whose sub-recipient does not resolve to UC are keyword
+// false positives and are left out.
+func inboundSubawardFlows(rows []SubawardRow, resolver *RecipientResolver) []SubawardFlow {
+	flows := make(flowSet)
+	for _, row := range rows {
+		sub := resolver.resolve(recipientRef{name: row.SubawardeeName, uei: row.SubrecipientUEI}).Entity
+		if !sub.IsUC() {
+			continue
+		}
+		flow := SubawardFlow{Prime: row.PrimeRecipientName, PrimeID: row.PrimeAwardRecipientID, Subrecipient: sub.Key}
+		if prime := resolver.resolve(recipientRef{name: row.PrimeRecipientName, uei: row.PrimeRecipientUEI}).Entity; prime.IsUC() {
+			flow.Prime, flow.PrimeID, flow.Internal = prime.Key, "", true
+		}
+		flows.add(flow, row.Amount)
+	}
+	return flows.list()
+}
+
+// printSubawardFlows writes the flow report to w and to subaward_flows.json.
+// Inbound flows need the API and are skipped when s is nil.
+func printSubawardFlows(ctx context.Context, w io.Writer, s *Scraper, outputRoot string, groups []string, resolver *RecipientResolver) error {
+	report := SubawardFlowReport{GeneratedAt: time.Now().Format(time.RFC3339)}
+
+	outbound, primes, err := outboundSubawardFlows(outputRoot, groups, resolver)
+	if err != nil {
+		return err
+	}
+	report.Outbound = outbound
+	fmt.Fprintf(w, "UC prime -> subrecipient (%d saved awards with subawards)\n", primes)
+	printFlows(w, outbound)
+	if primes == 0 {
+		fmt.Fprintf(w, "  none; scrape or update with -subawards to fetch them\n")
+	}
+
+	if s != nil {
+		rows, err := s.searchSubawardsToUC(ctx)
+		if err != nil {
+			return err
+		}
+		if s.dryRun {
+			return nil
+		}
+		report.Inbound = inboundSubawardFlows(rows, resolver)
+		fmt.Fprintf(w, "\nPrime -> UC subrecipient (%d subawards searched)\n", len(rows))
+		printFlows(w, report.Inbound)
+	}
+
+	path := filepath.Join(outputRoot, subawardFlowFile)
+	if err := writeJSONFile(path, report); err != nil {
+		return fmt.Errorf("error writing %s: %w", subawardFlowFile, err)
+	}
+	fmt.Fprintf(w, "\nWritten to %s\n", path)
+	return nil
+}
+
+func printFlows(w io.Writer, flows []SubawardFlow) {
+	for _, flow := range flows {
+		prime, sub := flow.Prime, flow.Subrecipient
+		if flow.PrimeID != "" {
+			prime += " (" + flow.PrimeID + ")"
+		}
+		if flow.SubrecipientUEI != "" {
+			sub += " (" + flow.SubrecipientUEI + ")"
+		}
+		internal := ""
+		if flow.Internal {
+			internal = "  [within UC]"
+		}
+		fmt.Fprintf(w, "  %s -> %s: %d subawards, $%s%s\n", prime, sub, flow.Subawards, flow.Amount, internal)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSubawardFlows(t *testing.T) {
+	server, s := scrapeFakeAPI(t, "fake_subawards.json", ScraperOptions{Subawards: true}, 2, "grants")
+	for _, req := range server.ListRequests() {
+		if req.AwardID != "ASST_NON_PRIME" {
+			t.Errorf("subawards fetched for %s", req.AwardID)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := printSubawardFlows(context.Background(), &out, s, s.outputRoot, []string{"grants"}, s.resolver); err != nil {
+		t.Fatalf("printSubawardFlows: %v", err)
+	}
+	var groups []string
+	for _, search := range server.Searches() {
+		if search.SpendingLevel != "subawards" {
+			continue
+		}
+		if fmt.Sprint(search.RecipientSearchText) != "[University of California]" {
+			t.Errorf("inbound search by recipient %v", search.RecipientSearchText)
+		}
+		groups = append(groups, search.AwardTypeCodes[0])
+	}
+	// Contracts find nothing; the four grant rows take two pages
+	if got, want := fmt.Sprint(groups), fmt.Sprint([]string{awardTypeGroups["contracts"][0], "02", "02"}); got != want {
+		t.Errorf("inbound searches by first type code %s, want %s", got, want)
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.outputRoot, subawardFlowFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report SubawardFlowReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+
+	flowLines := func(flows []SubawardFlow) string {
+		var lines []string
+		for _, f := range flows {
+			lines = append(lines, fmt.Sprintf("%s|%s -> %s|%s %d %s %v", f.Prime, f.PrimeID, f.Subrecipient, f.SubrecipientUEI, f.Subawards, f.Amount, f.Internal))
+		}
+		return strings.Join(lines, "\n")
+	}
+	wantOutbound := "UC_SAN_DIEGO| -> ACME RESEARCH LLC|ACME00000001 2 50000.50 false\n" +
+		"UC_SAN_DIEGO| -> UC_LOS_ANGELES| 1 5000.00 true"
+	if got := flowLines(report.Outbound); got != wantOutbound {
+		t.Errorf("outbound flows:\n%s\nwant:\n%s", got, wantOutbound)
+	}
+	wantInbound := "LELAND STANFORD JUNIOR UNIVERSITY|stanford-hash-C -> UC_DAVIS| 2 100000.00 false\n" +
+		"UC_SAN_DIEGO| -> UC_LOS_ANGELES| 1 8000.00 true"
+	if got := flowLines(report.Inbound); got != wantInbound {
+		t.Errorf("inbound flows:\n%s\nwant:\n%s", got, wantInbound)
+	}
+	if !strings.Contains(out.String(), "LELAND STANFORD JUNIOR UNIVERSITY (stanford-hash-C) -> UC_DAVIS: 2 subawards, $100000.00") {
+		t.Errorf("report output:\n%s", out.String())
+	}
+}
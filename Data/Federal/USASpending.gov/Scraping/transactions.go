@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Transactions requested per page; the most the endpoint allows
+const transactionPageSize = 5000
+
+// Transaction is one action on an award from /api/v2/transactions/: the base
+// award or a modification that added or removed obligations.
+type Transaction struct {
+	ID                      string `json:"id"`
+	Type                    string `json:"type"`
+	TypeDescription         string `json:"type_description"`
+	ActionDate              Date   `json:"action_date"`
+	ActionType              string `json:"action_type"`
+	ActionTypeDescription   string `json:"action_type_description"`
+	ModificationNumber      string `json:"modification_number"`
+	Description             string `json:"description"`
+	FederalActionObligation Money  `json:"federal_action_obligation"`
+	FaceValueLoanGuarantee  Money  `json:"face_value_loan_guarantee"`
+	OriginalLoanSubsidyCost Money  `json:"original_loan_subsidy_cost"`
+	AssistanceListingNumber string `json:"cfda_number"`
+	IsFPDS                  bool   `json:"is_fpds"`
+
+	raw      json.RawMessage // Row as the API sent it
+	mismatch error           // Type mismatch tolerated while decoding
+}
+
+// UnmarshalJSON decodes a transaction row and keeps it verbatim.
+func (t *Transaction) UnmarshalJSON(data []byte) error {
+	type plain Transaction
+	if err := json.Unmarshal(data, (*plain)(t)); err != nil {
+		if err := tolerateTypeError(err, "transaction "+t.ID, &t.mismatch); err != nil {
+			return err
+		}
+	}
+	t.raw = captureRaw(data)
+	return nil
+}
+
+// RawJSON returns the JSON the transaction was decoded from.
+func (t *Transaction) RawJSON() json.RawMessage {
+	return t.raw
+}
+
+func (t *Transaction) typeMismatch() error {
+	return t.mismatch
+}
+
+// fetchTransactions pages through the transaction history of an award,
+// oldest action first.
+func (s *Scraper) fetchTransactions(ctx context.Context, generatedInternalID string) ([]Transaction, error) {
+	transactions, err := fetchAwardList[Transaction](ctx, s, s.transactionsURL, AwardListRequest{
+		AwardID: generatedInternalID,
+		Page:    1,
+		Limit:   transactionPageSize,
+		Sort:    "action_date",
+		Order:   "asc",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error fetching transactions: %w", err)
+	}
+
+	// Keep the series in date order even if the API ties or reorders pages
+	sort.SliceStable(transactions, func(i, j int) bool {
+		return transactions[i].ActionDate.Before(transactions[j].ActionDate)
+	})
+	return transactions, nil
+}
+
+// obligationsByYear sums federal_action_obligation per bucket year, so
+// de-obligations reduce the year they were made in.
+func obligationsByYear(transactions []Transaction, years YearBucketing, totals map[int]int64) {
+	for _, transaction := range transactions {
+		if transaction.ActionDate.IsZero() {
+			continue
+		}
+		if cents, ok := transaction.FederalActionObligation.Cents(); ok {
+			totals[years.Year(transaction.ActionDate)] += cents
+		}
+	}
+}
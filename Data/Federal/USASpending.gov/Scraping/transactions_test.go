@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestEnrichFetchesTransactionPages(t *testing.T) {
+	server, s := scrapeFakeAPI(t, "fake_transactions.json", ScraperOptions{Transactions: true}, 1, "grants")
+
+	var pages []int
+	for _, req := range server.ListRequests() {
+		if req.Path != "/api/v2/transactions/" || req.AwardID != "ASST_NON_R01_075" || req.Sort != "action_date" {
+			t.Errorf("unexpected request %+v", req)
+		}
+		pages = append(pages, req.Page)
+	}
+	if fmt.Sprint(pages) != "[1 2]" {
+		t.Errorf("requested pages %v, want [1 2]", pages)
+	}
+
+	var saved *EnhancedAward
+	if err := walkEnhancedAwards(s.outputRoot, "grants", func(path string, award *EnhancedAward) error {
+		saved = award
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if saved == nil {
+		t.Fatal("no award saved")
+	}
+
+	var got []string
+	for _, tx := range saved.Transactions {
+		got = append(got, fmt.Sprintf("%s %s %s %s", tx.ActionDate, tx.ModificationNumber, tx.ActionType, tx.FederalActionObligation))
+	}
+	want := []string{"2022-09-15 0 A 500000", "2022-10-03 1 B 120000.25", "2023-11-01 2 C -25000.5"}
+	if strings.Join(got, "; ") != strings.Join(want, "; ") {
+		t.Errorf("transactions = %v, want %v", got, want)
+	}
+	if saved.Raw == nil || len(saved.Raw.Transactions) != 3 || !strings.Contains(string(saved.Raw.Transactions[0]), "future_key") {
+		t.Errorf("raw transactions not kept: %+v", saved.Raw)
+	}
+
+	totals := make(map[int]int64)
+	obligationsByYear(saved.Transactions, yearsFederal, totals)
+	if totals[2022] != 50000000 || totals[2023] != 12000025 || totals[2024] != -2500050 {
+		t.Errorf("obligations by federal year = %v", totals)
+	}
+}
@@ -0,0 +1,394 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+)
+
+// Money is a nullable dollar amount. It keeps the exact decimal the API sent,
+// so cents and sub-cent amounts survive a load and save unchanged.
+type Money struct {
+	decimal string // Canonical decimal text; "" for null
+}
+
+// MoneyFromCents returns the amount for a whole number of cents.
+func MoneyFromCents(cents int64) Money {
+	return Money{decimal: new(big.Rat).SetFrac64(cents, 100).FloatString(2)}
+}
+
+// ParseMoney parses a plain decimal such as "1250000" or "-17.5".
+func ParseMoney(s string) (Money, error) {
+	if _, ok := new(big.Rat).SetString(s); !ok || !isPlainDecimal(s) {
+		return Money{}, fmt.Errorf("invalid amount %q", s)
+	}
+	return Money{decimal: s}, nil
+}
+
+// isPlainDecimal accepts an optional sign, digits and an optional fraction,
+// which is the form the API and encoding/json use for amounts.
+func isPlainDecimal(s string) bool {
+	if s != "" && s[0] == '-' {
+		s = s[1:]
+	}
+	digits, dot := 0, false
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			digits++
+		case r == '.' && !dot:
+			dot = true
+		default:
+			return false
+		}
+	}
+	return digits > 0
+}
+
+// Valid reports whether the amount is present rather than null.
+func (m Money) Valid() bool {
+	return m.decimal != ""
+}
+
+// Rat returns the exact amount, or nil for null.
+func (m Money) Rat() *big.Rat {
+	if !m.Valid() {
+		return nil
+	}
+	r, _ := new(big.Rat).SetString(m.decimal)
+	return r
+}
+
+// Cents returns the amount rounded half away from zero to whole cents, and
+// false for null.
+func (m Money) Cents() (int64, bool) {
+	r := m.Rat()
+	if r == nil {
+		return 0, false
+	}
+	r.Mul(r, big.NewRat(100, 1))
+	num, den := r.Num(), r.Denom()
+	q, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if new(big.Int).Mul(new(big.Int).Abs(rem), big.NewInt(2)).Cmp(den) >= 0 {
+		q.Add(q, big.NewInt(int64(num.Sign())))
+	}
+	return q.Int64(), true
+}
+
+// Float64 returns the nearest float64, and 0 for null.
+func (m Money) Float64() float64 {
+	r := m.Rat()
+	if r == nil {
+		return 0
+	}
+	f, _ := r.Float64()
+	return f
+}
+
+func (m Money) String() string {
+	if !m.Valid() {
+		return "null"
+	}
+	return m.decimal
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	if !m.Valid() {
+		return []byte("null"), nil
+	}
+	return []byte(m.decimal), nil
+}
+
+// UnmarshalJSON accepts a JSON number, a numeric string or null.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if string(data) == "null" {
+		*m = Money{}
+		return nil
+	}
+
+	text := string(data)
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+		if text == "" {
+			*m = Money{}
+			return nil
+		}
+	}
+
+	if !isPlainDecimal(text) {
+		// Exponent form; normalize to a plain decimal without losing digits
+		r, ok := new(big.Rat).SetString(text)
+		if !ok {
+			return fmt.Errorf("invalid amount %s", data)
+		}
+		text = ratDecimal(r)
+	}
+	m.decimal = text
+	return nil
+}
+
+// ratDecimal formats r as a plain decimal with as many places as it needs,
+// up to 12.
+func ratDecimal(r *big.Rat) string {
+	if r.IsInt() {
+		return r.Num().String()
+	}
+	s := r.FloatString(12)
+	for s[len(s)-1] == '0' {
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+// Date is a calendar date without a time zone, as in "2024-07-01". The zero
+// Date stands for an empty date and is written as "".
+type Date struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+const dateLayout = "2006-01-02"
+
+// ParseDate parses YYYY-MM-DD. A timestamp that starts with a date, such as
+// "2024-07-01T00:00:00", is cut to its date.
+func ParseDate(s string) (Date, error) {
+	if s == "" {
+		return Date{}, nil
+	}
+	if len(s) > len(dateLayout) && s[len(dateLayout)] == 'T' {
+		s = s[:len(dateLayout)]
+	}
+	t, err := time.Parse(dateLayout, s)
+	if err != nil {
+		return Date{}, fmt.Errorf("invalid date %q", s)
+	}
+	return DateOf(t), nil
+}
+
+// DateOf returns the calendar date of t in its own location.
+func DateOf(t time.Time) Date {
+	year, month, day := t.Date()
+	return Date{year, month, day}
+}
+
+func (d Date) IsZero() bool {
+	return d == Date{}
+}
+
+// Time returns midnight UTC at the start of the date.
+func (d Date) Time() time.Time {
+	return time.Date(d.Year, d.Month, d.Day, 0, 0, 0, 0, time.UTC)
+}
+
+func (d Date) Before(other Date) bool {
+	return d.Time().Before(other.Time())
+}
+
+func (d Date) After(other Date) bool {
+	return d.Time().After(other.Time())
+}
+
+// String returns YYYY-MM-DD, or "" for the zero Date.
+func (d Date) String() string {
+	if d.IsZero() {
+		return ""
+	}
+	return d.Time().Format(dateLayout)
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON accepts a date string, "" or null.
+func (d *Date) UnmarshalJSON(data []byte) error {
+	if string(bytes.TrimSpace(data)) == "null" {
+		*d = Date{}
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid date %s", data)
+	}
+	parsed, err := ParseDate(s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// AwardLocation is the recipient or place-of-performance location of a search
+// result. Most award types send a Location object; loans send null and put
+// the location in flattened recipient_location_* and pop_* fields, which
+// Award copies into the typed view with Flattened set. The keys and nulls of
+// the original object are remembered so the location is written back
+// exactly as it was read.
+type AwardLocation struct {
+	Location
+
+	Flattened bool // Filled from a loan's flattened fields; written as null
+
+	present bool
+	keys    []string
+	nulls   map[string]bool
+	extra   map[string]*string // Keys Location has no field for
+}
+
+// Valid reports whether the location came from a Location object.
+func (l *AwardLocation) Valid() bool {
+	return l.present
+}
+
+// stringFields maps JSON keys to the non-nullable fields of a Location.
+func (l *Location) stringFields() map[string]*string {
+	return map[string]*string{
+		"location_country_code": &l.LocationCountryCode,
+		"country_name":          &l.CountryName,
+		"state_code":            &l.StateCode,
+		"state_name":            &l.StateName,
+		"city_name":             &l.CityName,
+		"county_code":           &l.CountyCode,
+		"county_name":           &l.CountyName,
+		"address_line1":         &l.AddressLine1,
+		"congressional_code":    &l.CongressionalCode,
+		"zip4":                  &l.Zip4,
+		"zip5":                  &l.Zip5,
+	}
+}
+
+// optionalFields maps JSON keys to the nullable fields of a Location.
+func (l *Location) optionalFields() map[string]**string {
+	return map[string]**string{
+		"address_line2":       &l.AddressLine2,
+		"address_line3":       &l.AddressLine3,
+		"foreign_postal_code": &l.ForeignPostalCode,
+		"foreign_province":    &l.ForeignProvince,
+	}
+}
+
+func (l *AwardLocation) UnmarshalJSON(data []byte) error {
+	*l = AwardLocation{}
+	if string(bytes.TrimSpace(data)) == "null" {
+		return nil
+	}
+
+	var object map[string]*string
+	if err := json.Unmarshal(data, &object); err != nil {
+		return fmt.Errorf("invalid location: %w", err)
+	}
+
+	l.present = true
+	l.nulls = make(map[string]bool)
+	text, optional := l.Location.stringFields(), l.Location.optionalFields()
+	for key, value := range object {
+		l.keys = append(l.keys, key)
+		switch {
+		case text[key] != nil:
+			if value == nil {
+				l.nulls[key] = true
+			} else {
+				*text[key] = *value
+			}
+		case optional[key] != nil:
+			*optional[key] = value
+		default:
+			if l.extra == nil {
+				l.extra = make(map[string]*string)
+			}
+			l.extra[key] = value
+		}
+	}
+	sort.Strings(l.keys)
+	return nil
+}
+
+func (l AwardLocation) MarshalJSON() ([]byte, error) {
+	if !l.present {
+		return []byte("null"), nil
+	}
+
+	object := make(map[string]*string, len(l.keys))
+	for _, key := range l.keys {
+		object[key] = nil
+	}
+	for key, field := range l.Location.stringFields() {
+		_, had := object[key]
+		switch {
+		case *field != "" || (had && !l.nulls[key]):
+			value := *field
+			object[key] = &value
+		case !had:
+			delete(object, key)
+		}
+	}
+	for key, field := range l.Location.optionalFields() {
+		if _, had := object[key]; had || *field != nil {
+			object[key] = *field
+		}
+	}
+	for key, value := range l.extra {
+		object[key] = value
+	}
+
+	// encoding/json writes map keys in sorted order, as the saved files have them
+	return json.Marshal(object)
+}
+
+// UnmarshalJSON decodes a search result and fills the location views of a
+// loan from its flattened fields.
+func (a *Award) UnmarshalJSON(data []byte) error {
+	type plain Award
+	if err := json.Unmarshal(data, (*plain)(a)); err != nil {
+		if err := tolerateTypeError(err, "search row "+a.GeneratedInternalID, &a.mismatch); err != nil {
+			return err
+		}
+	}
+	a.raw = captureRaw(data)
+
+	if !a.RecipientLocation.Valid() && (a.RecipientLocationCityName != "" || a.RecipientLocationStateCode != "" ||
+		a.RecipientLocationCountryName != "" || a.RecipientLocationAddressLine1 != "") {
+		a.RecipientLocation = AwardLocation{
+			Location: Location{
+				CityName:     a.RecipientLocationCityName,
+				StateCode:    a.RecipientLocationStateCode,
+				CountryName:  a.RecipientLocationCountryName,
+				AddressLine1: a.RecipientLocationAddressLine1,
+			},
+			Flattened: true,
+		}
+	}
+	if !a.PrimaryPlaceOfPerformance.Valid() && (a.POPCityName != "" || a.POPStateCode != "" || a.POPCountryName != "") {
+		a.PrimaryPlaceOfPerformance = AwardLocation{
+			Location: Location{
+				CityName:    a.POPCityName,
+				StateCode:   a.POPStateCode,
+				CountryName: a.POPCountryName,
+			},
+			Flattened: true,
+		}
+	}
+	return nil
+}
+
+// UnmarshalJSON accepts the {code, description} object or a bare code string.
+func (c *CodeDescription) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var code string
+		if err := json.Unmarshal(data, &code); err != nil {
+			return err
+		}
+		*c = CodeDescription{Code: &code}
+		return nil
+	}
+
+	type plain CodeDescription
+	return json.Unmarshal(data, (*plain)(c))
+}
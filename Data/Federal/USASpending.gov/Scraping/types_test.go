@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// Search rows as they appear in the saved tree: a contract with a sparse
+// place of performance and nullable location fields, and a loan with its
+// location in flattened fields.
+const contractRow = `{
+  "internal_id": 123318441,
+  "Award ID": "DEAC0205CH11231",
+  "Recipient Name": "THE REGENTS OF THE UNIVERSITY OF CALIFORNIA",
+  "Award Amount": 14391286534.43,
+  "Total Outlays": 1277532006,
+  "Description": "MANAGEMENT AND OPERATION OF LAWRENCE BERKELEY NATIONAL LABORATORY",
+  "Contract Award Type": "DEFINITIVE CONTRACT",
+  "Recipient UEI": "GS3YEVSS12N6",
+  "Recipient Location": {
+    "address_line1": "1 CYCLOTRON RD",
+    "address_line2": null,
+    "address_line3": null,
+    "city_name": "BERKELEY",
+    "congressional_code": "12",
+    "country_name": "UNITED STATES",
+    "county_code": null,
+    "county_name": null,
+    "foreign_postal_code": null,
+    "foreign_province": null,
+    "location_country_code": "USA",
+    "state_code": "CA",
+    "state_name": "California",
+    "zip4": "8099",
+    "zip5": "94720"
+  },
+  "Primary Place of Performance": {
+    "city_name": "BERKELEY",
+    "congressional_code": "12",
+    "country_name": "UNITED STATES",
+    "county_code": "001",
+    "county_name": "ALAMEDA",
+    "location_country_code": "USA",
+    "state_code": "CA",
+    "state_name": "California",
+    "zip4": null,
+    "zip5": "94720"
+  },
+  "def_codes": null,
+  "COVID-19 Obligations": 0,
+  "COVID-19 Outlays": 0.0000012345,
+  "Infrastructure Obligations": null,
+  "Infrastructure Outlays": null,
+  "Awarding Agency": "Department of Energy",
+  "Awarding Sub Agency": "Department of Energy",
+  "Start Date": "2005-06-01",
+  "End Date": "2025-05-31",
+  "NAICS": {
+    "code": "541715",
+    "description": null
+  },
+  "PSC": null,
+  "recipient_id": "",
+  "prime_award_recipient_id": "",
+  "generated_internal_id": "CONT_AWD_DEAC0205CH11231_8900_-NONE-_-NONE-",
+  "awarding_agency_id": 0,
+  "agency_slug": "",
+  "Loan Value": null,
+  "Subsidy Cost": null,
+  "Issued Date": "",
+  "Funding Agency": "",
+  "recipient_location_city_name": "",
+  "recipient_location_state_code": "",
+  "recipient_location_country_name": "",
+  "recipient_location_address_line1": "",
+  "pop_city_name": "",
+  "pop_state_code": "",
+  "pop_country_name": ""
+}
+`
+
+const loanRow = `{
+  "internal_id": 9001,
+  "Award ID": "SBAEIDL001",
+  "Recipient Name": "UNIVERSITY OF CALIFORNIA, SAN DIEGO",
+  "Award Amount": null,
+  "Total Outlays": null,
+  "Description": "",
+  "Contract Award Type": "",
+  "Recipient UEI": "",
+  "Recipient Location": null,
+  "Primary Place of Performance": null,
+  "def_codes": [
+    "N"
+  ],
+  "COVID-19 Obligations": null,
+  "COVID-19 Outlays": null,
+  "Infrastructure Obligations": null,
+  "Infrastructure Outlays": null,
+  "Awarding Agency": "Small Business Administration",
+  "Awarding Sub Agency": "",
+  "Start Date": "",
+  "End Date": "",
+  "NAICS": null,
+  "PSC": null,
+  "recipient_id": "",
+  "prime_award_recipient_id": "",
+  "generated_internal_id": "ASST_NON_SBAEIDL001_7300",
+  "awarding_agency_id": 0,
+  "agency_slug": "",
+  "Loan Value": 150000,
+  "Subsidy Cost": 1234.5,
+  "Issued Date": "2020-07-14",
+  "Funding Agency": "",
+  "recipient_location_city_name": "LA JOLLA",
+  "recipient_location_state_code": "CA",
+  "recipient_location_country_name": "UNITED STATES",
+  "recipient_location_address_line1": "9500 GILMAN DR",
+  "pop_city_name": "LA JOLLA",
+  "pop_state_code": "CA",
+  "pop_country_name": "UNITED STATES"
+}
+`
+
+func encodeIndented(t *testing.T, v interface{}) string {
+	t.Helper()
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestAwardRoundTripsSavedJSON(t *testing.T) {
+	for name, row := range map[string]string{"contract": contractRow, "loan": loanRow} {
+		var award Award
+		if err := json.Unmarshal([]byte(row), &award); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got := encodeIndented(t, award); got != row {
+			t.Errorf("%s does not round-trip:\n%s", name, got)
+		}
+	}
+}
+
+func TestAwardTypedFields(t *testing.T) {
+	var contract, loan Award
+	if err := json.Unmarshal([]byte(contractRow), &contract); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(loanRow), &loan); err != nil {
+		t.Fatal(err)
+	}
+
+	if cents, ok := contract.AwardAmount.Cents(); !ok || cents != 1439128653443 {
+		t.Errorf("AwardAmount cents = %d, %v", cents, ok)
+	}
+	if contract.InfrastructureOutlays.Valid() {
+		t.Error("null amount is valid")
+	}
+	if contract.StartDate != (Date{2005, time.June, 1}) || !contract.EndDate.After(contract.StartDate) {
+		t.Errorf("dates = %v, %v", contract.StartDate, contract.EndDate)
+	}
+	if contract.RecipientLocation.CityName != "BERKELEY" || contract.RecipientLocation.CountyName != "" {
+		t.Errorf("recipient location = %+v", contract.RecipientLocation.Location)
+	}
+	if contract.NAICS == nil || *contract.NAICS.Code != "541715" || contract.NAICS.Description != nil {
+		t.Errorf("NAICS = %+v", contract.NAICS)
+	}
+
+	if !loan.RecipientLocation.Flattened || loan.RecipientLocation.CityName != "LA JOLLA" || loan.RecipientLocation.AddressLine1 != "9500 GILMAN DR" {
+		t.Errorf("loan recipient location = %+v", loan.RecipientLocation)
+	}
+	if loan.PrimaryPlaceOfPerformance.StateCode != "CA" {
+		t.Errorf("loan place of performance = %+v", loan.PrimaryPlaceOfPerformance)
+	}
+	if loan.IssuedDate.String() != "2020-07-14" || !loan.StartDate.IsZero() {
+		t.Errorf("loan dates = %q, %q", loan.IssuedDate, loan.StartDate)
+	}
+}
+
+func TestMoneyDecoding(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+		cents int64
+	}{
+		{`null`, "null", 0},
+		{`""`, "null", 0},
+		{`0`, "0", 0},
+		{`1234.5`, "1234.5", 123450},
+		{`"-17.125"`, "-17.125", -1713},
+		{`0.0000012345`, "0.0000012345", 0},
+		{`1.5e+21`, "1500000000000000000000", 0},
+	}
+	for _, tt := range tests {
+		var m Money
+		if err := json.Unmarshal([]byte(tt.input), &m); err != nil {
+			t.Errorf("%s: %v", tt.input, err)
+			continue
+		}
+		if m.String() != tt.want {
+			t.Errorf("%s decoded as %s, want %s", tt.input, m, tt.want)
+		}
+		if cents, _ := m.Cents(); tt.cents != 0 && cents != tt.cents {
+			t.Errorf("%s cents = %d, want %d", tt.input, cents, tt.cents)
+		}
+	}
+
+	var m Money
+	if err := json.Unmarshal([]byte(`"twelve"`), &m); err == nil {
+		t.Error("expected an error for a non-numeric amount")
+	}
+	if got := MoneyFromCents(-505).String(); got != "-5.05" {
+		t.Errorf("MoneyFromCents(-505) = %s", got)
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	if d, err := ParseDate("2024-07-01T00:00:00"); err != nil || d != (Date{2024, time.July, 1}) {
+		t.Errorf("timestamp parsed as %v, %v", d, err)
+	}
+	if _, err := ParseDate("07/01/2024"); err == nil {
+		t.Error("expected an error for a non-ISO date")
+	}
+	var d Date
+	if err := json.Unmarshal([]byte(`null`), &d); err != nil || !d.IsZero() {
+		t.Errorf("null decoded as %v, %v", d, err)
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// File at the output root recording the last run that completed successfully
+const lastRunFile = "last_run.json"
+
+// LastRun is the start date of the most recent successful scrape or update.
+// Awards modified on or after that date are refetched by the next update.
+type LastRun struct {
+	Date        string `json:"date"`
+	Command     string `json:"command"`
+	ProfileHash string `json:"profile_hash"`
+}
+
+func readLastRun(outputRoot string) (*LastRun, error) {
+	data, err := os.ReadFile(filepath.Join(outputRoot, lastRunFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", lastRunFile, err)
+	}
+
+	var run LastRun
+	if err := json.Unmarshal(data, &run); err != nil {
+		return nil, fmt.Errorf("error decoding %s: %w", lastRunFile, err)
+	}
+	return &run, nil
+}
+
+func writeLastRun(outputRoot string, run LastRun) error {
+	return writeJSONFile(filepath.Join(outputRoot, lastRunFile), run)
+}
+
+// localAward is what an update needs to know about an award already on disk.
+type localAward struct {
+	paths        []string // More than one when the recipient was spelled differently over time
+	lastModified string
+}
+
+// loadLocalIndex maps generated_internal_id to the saved files of a group.
+func loadLocalIndex(outputRoot, groupName string) (map[string]*localAward, error) {
+	index := make(map[string]*localAward)
+	err := walkEnhancedAwards(outputRoot, groupName, func(path string, award *EnhancedAward) error {
+		id := award.BasicData.GeneratedInternalID
+		if id == "" {
+			return nil
+		}
+
+		entry := index[id]
+		if entry == nil {
+			entry = &localAward{}
+			index[id] = entry
+		}
+		entry.paths = append(entry.paths, path)
+		if award.DetailedData != nil {
+			if modified := award.DetailedData.Common().PeriodOfPerformance.LastModifiedDate; modified > entry.lastModified {
+				entry.lastModified = modified
+			}
+		}
+		return nil
+	})
+	return index, err
+}
+
+// latestModification returns the newest last_modified_date in an index.
+func latestModification(index map[string]*localAward) string {
+	latest := ""
+	for _, entry := range index {
+		if entry.lastModified > latest {
+			latest = entry.lastModified
+		}
+	}
+	if len(latest) > len("2006-01-02") {
+		latest = latest[:len("2006-01-02")]
+	}
+	return latest
+}
+
+// GroupChanges lists the award IDs an update added, modified or no longer
+// found for one group. Vanished is only looked for with -vanished;
+// VanishedChecked tells an empty list from one that was never checked.
+type GroupChanges struct {
+	Group           string   `json:"group"`
+	Since           string   `json:"since"`
+	Added           []string `json:"added"`
+	Modified        []string `json:"modified"`
+	Vanished        []string `json:"vanished"`
+	VanishedChecked bool     `json:"vanished_checked"`
+}
+
+// updateGroup searches for awards modified since the given date (or since
+// the newest last_modified_date on disk), fetches detail for the new and
+// modified ones, and with checkVanished lists saved awards the search no
+// longer returns.
+func (s *Scraper) updateGroup(ctx context.Context, groupName, since string, checkVanished bool) (*GroupChanges, error) {
+	index, err := loadLocalIndex(s.outputRoot, groupName)
+	if err != nil {
+		return nil, err
+	}
+	if since == "" {
+		since = latestModification(index)
+	}
+	if since == "" {
+		return nil, fmt.Errorf("no saved %s awards and no previous run to update from; run scrape first", groupName)
+	}
+
+	log.Printf("[%s] Updating %d saved awards with changes since %s", groupName, len(index), since)
+
+	// Ask only for awards modified in the window
+	request := s.createRequest(groupName, awardTypeGroups[groupName])
+	request.Filters.TimePeriod = []TimePeriod{{
+		StartDate: since,
+		EndDate:   time.Now().Format("2006-01-02"),
+		DateType:  "last_modified_date",
+	}}
+	changed, err := s.scrapeGroupRequest(ctx, groupName, request)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := &GroupChanges{Group: groupName, Since: since}
+	var mu sync.Mutex
+
+	onSaved := func(groupName string, award *EnhancedAward, path string) {
+		id := award.BasicData.GeneratedInternalID
+		lastModified := ""
+		if award.DetailedData != nil {
+			lastModified = award.DetailedData.Common().PeriodOfPerformance.LastModifiedDate
+		}
+
+		mu.Lock()
+		previous := index[id]
+		switch {
+		case previous == nil:
+			changes.Added = append(changes.Added, id)
+		case previous.lastModified != lastModified:
+			changes.Modified = append(changes.Modified, id)
+		}
+		mu.Unlock()
+
+		// Drop copies saved under an older path, e.g. a renamed recipient
+		if previous != nil {
+			for _, oldPath := range previous.paths {
+				if oldPath == path {
+					continue
+				}
+				if err := os.Remove(oldPath); err != nil {
+					log.Printf("Warning: could not remove old copy %s: %v", oldPath, err)
+				}
+			}
+		}
+	}
+	if _, err := s.enrichGroupAwardsFunc(ctx, groupName, changed, onSaved); err != nil {
+		return nil, err
+	}
+
+	changes.VanishedChecked = checkVanished
+	if checkVanished {
+		log.Printf("[%s] Listing all awards to find vanished ones...", groupName)
+		current, err := s.scrapeGroupData(ctx, groupName, awardTypeGroups[groupName])
+		if err != nil {
+			return nil, err
+		}
+
+		present := make(map[string]bool, len(current)+len(changed))
+		for _, award := range append(current, changed...) {
+			present[award.GeneratedInternalID] = true
+		}
+		for id := range index {
+			if !present[id] {
+				changes.Vanished = append(changes.Vanished, id)
+			}
+		}
+	}
+
+	sort.Strings(changes.Added)
+	sort.Strings(changes.Modified)
+	sort.Strings(changes.Vanished)
+	return changes, nil
+}
+
+// printChangelog writes a human-readable changelog.
+func printChangelog(w io.Writer, changes []*GroupChanges) {
+	for _, group := range changes {
+		fmt.Fprintf(w, "[%s] changes since %s: %d added, %d modified", group.Group, group.Since, len(group.Added), len(group.Modified))
+		if group.VanishedChecked {
+			fmt.Fprintf(w, ", %d vanished", len(group.Vanished))
+		} else {
+			fmt.Fprintf(w, ", vanished not checked (run with -vanished)")
+		}
+		fmt.Fprintln(w)
+
+		for _, id := range group.Added {
+			fmt.Fprintf(w, "  + %s\n", id)
+		}
+		for _, id := range group.Modified {
+			fmt.Fprintf(w, "  ~ %s\n", id)
+		}
+		for _, id := range group.Vanished {
+			fmt.Fprintf(w, "  - %s\n", id)
+		}
+	}
+}
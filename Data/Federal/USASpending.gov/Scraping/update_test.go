@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUpdateGroupReportsChanges(t *testing.T) {
+	outputRoot := t.TempDir()
+	saved := func(id, recipient, lastModified string) string {
+		award := EnhancedAward{
+			BasicData: Award{GeneratedInternalID: id, RecipientName: recipient, AwardingAgency: "Department of Energy", StartDate: Date{2019, time.January, 1}},
+			DetailedData: &DetailedAwardResponse{
+				GeneratedUniqueAwardID: id,
+				PeriodOfPerformance:    PeriodOfPerformance{LastModifiedDate: lastModified},
+			},
+		}
+		// Saved under the recipient name, as before canonical entities
+		path, err := enhancedAwardPath(outputRoot, "contracts", award, Entity{Key: recipient})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := saveEnhancedAwardToJSON(award, path); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	oldPath := saved("CONT_AWD_MODIFIED", "REGENTS OF THE UNIVERSITY OF CALIFORNIA, THE", "2025-01-10")
+	saved("CONT_AWD_UNCHANGED", "REGENTS OF THE UNIVERSITY OF CALIFORNIA, THE", "2025-03-01")
+	saved("CONT_AWD_GONE", "REGENTS OF THE UNIVERSITY OF CALIFORNIA, THE", "2024-06-30")
+
+	var searchedSince []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/spending_by_award_count/"):
+			fmt.Fprint(w, `{"results":{}}`)
+		case strings.HasSuffix(r.URL.Path, "/spending_by_award/"):
+			var req APIRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			period := req.Filters.TimePeriod[0]
+
+			ids := []string{"CONT_AWD_MODIFIED", "CONT_AWD_UNCHANGED", "CONT_AWD_NEW"}
+			if period.DateType == "last_modified_date" {
+				searchedSince = append(searchedSince, period.StartDate)
+				ids = []string{"CONT_AWD_MODIFIED", "CONT_AWD_NEW"}
+			}
+
+			var resp APIResponse
+			for _, id := range ids {
+				resp.Results = append(resp.Results, Award{
+					GeneratedInternalID: id,
+					RecipientName:       "THE REGENTS OF THE UNIVERSITY OF CALIFORNIA",
+					RecipientUEI:        "GS3YEVSS12N6",
+					AwardingAgency:      "Department of Energy",
+					StartDate:           Date{2019, time.January, 1},
+				})
+			}
+			json.NewEncoder(w).Encode(resp)
+		default:
+			id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/v2/awards/"), "/")
+			fmt.Fprintf(w, `{"generated_unique_award_id":%q,"period_of_performance":{"last_modified_date":"2025-09-29"}}`, id)
+		}
+	}))
+	defer server.Close()
+
+	s := newTestScraper(server.URL, 1)
+	s.outputRoot = outputRoot
+
+	changes, err := s.updateGroup(context.Background(), "contracts", "", true)
+	if err != nil {
+		t.Fatalf("updateGroup: %v", err)
+	}
+
+	if fmt.Sprint(searchedSince) != "[2025-03-01]" {
+		t.Errorf("searched since %v, want the newest saved last_modified_date 2025-03-01", searchedSince)
+	}
+	if fmt.Sprint(changes.Added) != "[CONT_AWD_NEW]" {
+		t.Errorf("added = %v", changes.Added)
+	}
+	if fmt.Sprint(changes.Modified) != "[CONT_AWD_MODIFIED]" {
+		t.Errorf("modified = %v", changes.Modified)
+	}
+	if fmt.Sprint(changes.Vanished) != "[CONT_AWD_GONE]" {
+		t.Errorf("vanished = %v", changes.Vanished)
+	}
+	if _, err := os.Stat(oldPath); !os.IsNotExist(err) {
+		t.Errorf("copy under the old recipient name was not removed: %v", err)
+	}
+}
+
+func TestPrintChangelogMarksUncheckedVanished(t *testing.T) {
+	var out bytes.Buffer
+	printChangelog(&out, []*GroupChanges{
+		{Group: "contracts", Since: "2025-03-01", Added: []string{"CONT_AWD_NEW"}, Vanished: []string{"CONT_AWD_GONE"}, VanishedChecked: true},
+		{Group: "grants", Since: "2025-03-01", Modified: []string{"ASST_NON_MODIFIED"}},
+	})
+	want := "[contracts] changes since 2025-03-01: 1 added, 0 modified, 1 vanished\n" +
+		"  + CONT_AWD_NEW\n" +
+		"  - CONT_AWD_GONE\n" +
+		"[grants] changes since 2025-03-01: 0 added, 1 modified, vanished not checked (run with -vanished)\n" +
+		"  ~ ASST_NON_MODIFIED\n"
+	if out.String() != want {
+		t.Errorf("changelog:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Suffix of the temp files writeJSONFile renames into place. One left behind
+// means a write was cut off before it was committed.
+const tempFileSuffix = ".tmp"
+
+// verifyProblem is a file in the output tree that is not a valid record.
+type verifyProblem struct {
+	path   string
+	reason string
+}
+
+// verifyGroup checks every file under a group directory: award files must
+// parse as EnhancedAward with a generated_internal_id, search dumps must
+// parse as a list of awards, and no temp files may be left over. It returns
+// the number of files checked.
+func verifyGroup(outputRoot, groupName string) (int, []verifyProblem, error) {
+	root := groupDirectory(outputRoot, groupName)
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return 0, nil, nil
+	}
+
+	checked := 0
+	var problems []verifyProblem
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		switch {
+		case strings.HasSuffix(path, tempFileSuffix):
+			checked++
+			problems = append(problems, verifyProblem{path, "leftover temp file from an interrupted write"})
+		case filepath.Ext(path) != ".json":
+			return nil
+		case isGroupDump(path):
+			checked++
+			if _, err := loadGroupDump(path); err != nil {
+				problems = append(problems, verifyProblem{path, err.Error()})
+			}
+		default:
+			checked++
+			award, err := loadEnhancedAward(path)
+			if err != nil {
+				problems = append(problems, verifyProblem{path, err.Error()})
+			} else if award.BasicData.GeneratedInternalID == "" {
+				problems = append(problems, verifyProblem{path, "missing basic_data.generated_internal_id"})
+			}
+		}
+		return nil
+	})
+	return checked, problems, err
+}
+
+// verifyTree checks the saved tree for the given groups, lists every problem
+// on w and fails if any were found.
+func verifyTree(w io.Writer, outputRoot string, groups []string) error {
+	totalChecked := 0
+	totalProblems := 0
+
+	for _, groupName := range groups {
+		checked, problems, err := verifyGroup(outputRoot, groupName)
+		if err != nil {
+			return fmt.Errorf("error walking %s: %w", groupDirectory(outputRoot, groupName), err)
+		}
+		for _, p := range problems {
+			fmt.Fprintf(w, "%s: %s\n", p.path, p.reason)
+		}
+
+		log.Printf("[%s] Checked %d files, %d problems", groupName, checked, len(problems))
+		totalChecked += checked
+		totalProblems += len(problems)
+	}
+
+	if totalProblems > 0 {
+		return fmt.Errorf("%d of the %d files checked are invalid", totalProblems, totalChecked)
+	}
+	log.Printf("All %d files are valid", totalChecked)
+	return nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteJSONFileReplacesAtomically(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "CONT_AWD_1.json")
+
+	for _, id := range []string{"CONT_AWD_1", "CONT_AWD_1_v2"} {
+		award := EnhancedAward{BasicData: Award{GeneratedInternalID: id}}
+		if err := saveEnhancedAwardToJSON(award, path); err != nil {
+			t.Fatalf("saveEnhancedAwardToJSON: %v", err)
+		}
+	}
+
+	award, err := loadEnhancedAward(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if award.BasicData.GeneratedInternalID != "CONT_AWD_1_v2" {
+		t.Errorf("file holds %q, want the second write", award.BasicData.GeneratedInternalID)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("directory holds %d entries, want only the award file", len(entries))
+	}
+}
+
+func TestWriteJSONFileKeepsOldFileOnError(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "uc_grants_2025-09-23.json")
+	if err := saveToJSON([]Award{{AwardID: "R01"}}, path); err != nil {
+		t.Fatal(err)
+	}
+
+	// Channels cannot be encoded, so the write fails after the temp file exists
+	if err := writeJSONFile(path, make(chan int)); err == nil {
+		t.Fatal("expected an encoding error")
+	}
+
+	awards, err := loadGroupDump(path)
+	if err != nil || len(awards) != 1 {
+		t.Errorf("old dump was damaged: %v, %v", awards, err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("directory holds %d entries, want the temp file removed", len(entries))
+	}
+}
+
+func TestVerifyTreeReportsBadFiles(t *testing.T) {
+	outputRoot := t.TempDir()
+	awardDir := filepath.Join(groupDirectory(outputRoot, "grants"), "UNIVERSITY_OF_CALIFORNIA,_DAVIS", "2020", "Department_of_Agriculture")
+	if err := ensureDirectoryExists(awardDir); err != nil {
+		t.Fatal(err)
+	}
+
+	good := EnhancedAward{BasicData: Award{GeneratedInternalID: "ASST_NON_GOOD"}}
+	if err := saveEnhancedAwardToJSON(good, filepath.Join(awardDir, "ASST_NON_GOOD.json")); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"ASST_NON_TRUNCATED.json":    `{"basic_data": {"generated_internal_id": "ASST_NON_TRUNC`,
+		"ASST_NON_EMPTY.json":        `{}`,
+		"ASST_NON_WRONGTYPE.json":    `{"basic_data": {"generated_internal_id": "ASST_NON_WRONGTYPE", "Description": 5}}`,
+		"ASST_NON_BADDETAIL.json":    `{"basic_data": {"generated_internal_id": "ASST_NON_BADDETAIL"}, "detailed_data": {"category": "grant", "description": ["not", "text"]}}`,
+		".ASST_NON_CUT.json.123.tmp": `{"basic_data"`,
+		"notes.txt":                  `not an award`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(awardDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := verifyTree(&out, outputRoot, []string{"grants", "loans"}); err == nil {
+		t.Fatal("expected verify to fail")
+	}
+
+	report := out.String()
+	for _, name := range []string{"ASST_NON_TRUNCATED.json", "ASST_NON_EMPTY.json", "ASST_NON_WRONGTYPE.json", "ASST_NON_BADDETAIL.json", ".ASST_NON_CUT.json.123.tmp"} {
+		if !strings.Contains(report, name) {
+			t.Errorf("report does not mention %s:\n%s", name, report)
+		}
+	}
+	for _, name := range []string{"ASST_NON_GOOD.json", "notes.txt"} {
+		if strings.Contains(report, name) {
+			t.Errorf("report flags valid file %s:\n%s", name, report)
+		}
+	}
+}